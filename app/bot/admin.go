@@ -4,16 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/Semior001/newsfeed/app/revisor"
 	"github.com/Semior001/newsfeed/app/store"
 	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/samber/lo"
 )
 
 type admin struct {
 	Store   store.Interface
 	Service *revisor.Service
+	Prompts *revisor.Prompts
 }
 
 func (c *admin) list(ctx context.Context, req botx.Request) ([]botx.Response, error) {
@@ -25,8 +28,8 @@ func (c *admin) list(ctx context.Context, req botx.Request) ([]botx.Response, er
 	sb := &strings.Builder{}
 	_, _ = sb.WriteString("Subscribers:\n")
 	for _, u := range users {
-		_, _ = sb.WriteString(fmt.Sprintf("id: %s, username: %s, authorized: %t, subscribed: %t\n",
-			u.ChatID, escapeMarkdown(u.Username), u.Authorized, u.Subscribed))
+		_, _ = sb.WriteString(fmt.Sprintf("id: %s, username: %s, platform: %s, authorized: %t, subscribed: %t\n",
+			u.ChatID, u.Username, u.Platform, u.Authorized, u.Subscribed))
 	}
 
 	return []botx.Response{{
@@ -53,10 +56,181 @@ func (c *admin) delete(ctx context.Context, req botx.Request) ([]botx.Response,
 }
 
 func (c *admin) cacheStats(_ context.Context, req botx.Request) ([]botx.Response, error) {
-	stats := c.Service.GPTCacheStat()
+	sb := &strings.Builder{}
+	for _, st := range c.Service.CacheStat() {
+		_, _ = sb.WriteString(fmt.Sprintf("%s: hits: %d, misses: %d, added: %d, evictions: %d, size: %d\n",
+			st.Name, st.Hits, st.Misses, st.Added, st.Evicted, st.Size))
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   sb.String(),
+	}}, nil
+}
+
+// prompts lists available prompts, or previews the prompt by name,
+// rendered for a sample article with the admin's preferences.
+func (c *admin) prompts(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	tokens := strings.Fields(req.Text)
+	if len(tokens) == 1 {
+		sb := &strings.Builder{}
+		_, _ = sb.WriteString("Prompts:\n")
+		for _, p := range c.Prompts.List() {
+			_, _ = sb.WriteString(fmt.Sprintf("%s, version: %s, source: %s\n",
+				p.Name, p.Version, p.Source))
+		}
+		_, _ = sb.WriteString("Use /prompts <name> to preview the prompt.")
+
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   sb.String(),
+		}}, nil
+	}
+
+	p, ok := c.Prompts.Get(tokens[1])
+	if !ok {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Prompt %s not found.", tokens[1]),
+		}}, nil
+	}
+
+	sample := store.Article{
+		Title:   "Example article",
+		Content: "Text of the article.",
+	}
+	if u, ok := userFromContext(ctx); ok {
+		sample.Preferences = u.Preferences
+	}
+
+	text, err := p.Execute(sample)
+	if err != nil {
+		return nil, fmt.Errorf("execute prompt %s: %w", p.Name, err)
+	}
+
 	return []botx.Response{{
 		ChatID: req.Chat.ID,
-		Text: fmt.Sprintf("hits: %d, misses: %d, evictions: %d, size: %d\n",
-			stats.Hits, stats.Misses, stats.Evicted, stats.Added),
+		Text: fmt.Sprintf("*%s* \\(version %s\\):\n\n%s",
+			escapeMarkdown(p.Name), escapeMarkdown(p.Version), escapeMarkdown(text)),
+		ParseMode: parseMode,
 	}}, nil
 }
+
+// maxQualityDomains is the number of the most rated domains in /quality.
+const maxQualityDomains = 10
+
+// approval is the share of positive ratings among the group of ratings.
+type approval struct {
+	Name     string
+	Positive int
+	Total    int
+}
+
+func (a approval) String() string {
+	return fmt.Sprintf("%s: %d%% of %d", a.Name, a.Positive*100/a.Total, a.Total)
+}
+
+// quality shows approval rates of summaries per prompt, per model and per source domain.
+func (c *admin) quality(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	ratings, err := c.Store.ListRatings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list ratings: %w", err)
+	}
+
+	if len(ratings) == 0 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Nobody has rated summaries yet.",
+		}}, nil
+	}
+
+	// ratings refer to prompts by version, current versions are shown with names
+	promptNames := map[string]string{}
+	for _, p := range c.Prompts.List() {
+		promptNames[p.Version] = p.Name + " (" + p.Version + ")"
+	}
+
+	promptName := func(r store.Rating) string {
+		switch v := r.PromptVersion(); {
+		case v == "":
+			return "no prompt"
+		case promptNames[v] != "":
+			return promptNames[v]
+		default:
+			return "outdated (" + v + ")"
+		}
+	}
+
+	byDomain := approvals(ratings, store.Rating.Domain)
+	if len(byDomain) > maxQualityDomains {
+		byDomain = byDomain[:maxQualityDomains]
+	}
+
+	reasonCounts := lo.CountValues(lo.FilterMap(ratings, func(r store.Rating, _ int) (string, bool) {
+		return r.Reason, !r.Positive && r.Reason != ""
+	}))
+
+	sb := &strings.Builder{}
+	total := approval{
+		Name:     "Approval rate",
+		Positive: lo.CountBy(ratings, func(r store.Rating) bool { return r.Positive }),
+		Total:    len(ratings),
+	}
+	_, _ = sb.WriteString(total.String() + "\n")
+
+	writeGroup := func(title string, group []approval) {
+		_, _ = sb.WriteString("\n" + title + ":\n")
+		for _, a := range group {
+			_, _ = sb.WriteString(a.String() + "\n")
+		}
+	}
+
+	writeGroup("By prompt", approvals(ratings, promptName))
+	writeGroup("By model", approvals(ratings, store.Rating.Model))
+	writeGroup("By domain", byDomain)
+
+	if len(reasonCounts) > 0 {
+		_, _ = sb.WriteString("\nReasons of negative ratings:\n")
+		for _, reason := range reasons {
+			if n := reasonCounts[reason.Code]; n > 0 {
+				_, _ = sb.WriteString(fmt.Sprintf("%s: %d\n", reason.Text, n))
+			}
+		}
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   sb.String(),
+	}}, nil
+}
+
+// approvals groups ratings by the key and returns approval rates
+// of groups, the most rated groups go first.
+func approvals(ratings []store.Rating, key func(store.Rating) string) []approval {
+	groups := map[string]*approval{}
+	for _, r := range ratings {
+		k := key(r)
+		if groups[k] == nil {
+			groups[k] = &approval{Name: k}
+		}
+
+		groups[k].Total++
+		if r.Positive {
+			groups[k].Positive++
+		}
+	}
+
+	result := make([]approval, 0, len(groups))
+	for _, a := range groups {
+		result = append(result, *a)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
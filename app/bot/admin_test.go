@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/revisor"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestAdmin(t *testing.T) {
+	c, _, s := newTestCtrl(t)
+	c.AdminIDs = []string{"42"}
+
+	var err error
+	c.Prompts, err = revisor.NewPrompts(slog.Default(), "")
+	require.NoError(t, err)
+
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	authorize(t, rtr, "1")
+	authorize(t, rtr, "42")
+
+	admin := botx.Chat{ID: "42"}
+
+	t.Run("not an admin", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: botx.Chat{ID: "1"}, Text: "/list"})
+		assert.Empty(t, resps)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: admin, Text: "/list"})
+		require.Len(t, resps, 1)
+		assert.Contains(t, resps[0].Text, "id: 1, username: user1")
+		assert.Contains(t, resps[0].Text, "id: 42, username: user42")
+	})
+
+	t.Run("no ratings", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: admin, Text: "/quality"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Nobody has rated summaries yet.", resps[0].Text)
+	})
+
+	t.Run("quality", func(t *testing.T) {
+		prompt, ok := c.Prompts.Get(revisor.DefaultPromptName)
+		require.True(t, ok)
+
+		current := "gpt-4:" + prompt.Version
+		for _, r := range []store.Rating{
+			{ChatID: "1", URL: "https://www.example.com/a", Summarizer: current, Positive: true},
+			{ChatID: "42", URL: "https://example.com/a", Summarizer: current, Reason: "long"},
+			{ChatID: "1", URL: "https://example.org/b", Summarizer: "gpt-4:0ld"},
+			{ChatID: "1", URL: "https://example.org/c", Summarizer: "extractive/3", Positive: true},
+		} {
+			r.RatedAt = time.Now()
+			require.NoError(t, s.PutRating(ctx, r))
+		}
+
+		resps := handle(t, rtr, botx.Request{Chat: admin, Text: "/quality"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Approval rate: 50% of 4\n"+
+			"\nBy prompt:\n"+
+			"default ("+prompt.Version+"): 50% of 2\n"+
+			"no prompt: 100% of 1\n"+
+			"outdated (0ld): 0% of 1\n"+
+			"\nBy model:\n"+
+			"gpt-4: 33% of 3\n"+
+			"extractive/3: 100% of 1\n"+
+			"\nBy domain:\n"+
+			"example.com: 50% of 2\n"+
+			"example.org: 50% of 2\n"+
+			"\nReasons of negative ratings:\n"+
+			"Too long: 1\n", resps[0].Text)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: admin, Text: "/delete 1"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "User with id 1 was deleted.", resps[0].Text)
+
+		_, err := s.Get(ctx, "1")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
@@ -4,68 +4,348 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/Semior001/newsfeed/app/revisor"
+	"github.com/Semior001/newsfeed/app/store"
 	"github.com/Semior001/newsfeed/pkg/botx"
+	"golang.org/x/exp/slog"
 )
 
+// maxConcurrentLinks is the number of articles from a single message summarized at the same time.
+const maxConcurrentLinks = 3
+
 type article struct {
+	Logger  *slog.Logger
 	API     botx.API
+	Store   store.Interface
 	Service *revisor.Service
 }
 
 var articleMessageTmpl = template.Must(template.New("articleMessage").
-	Funcs(template.FuncMap{"escapeMarkdown": escapeMarkdown}).
+	Funcs(template.FuncMap{"escapeMarkdown": escapeMarkdown, "escapeURL": escapeURL}).
 	Parse(`
 *{{.Title | escapeMarkdown}} by {{.Author | escapeMarkdown}}*
 
 {{.BulletPoints | escapeMarkdown}}
 
-[source]({{.URL}})
+[source]({{.URL | escapeURL}}){{if .Extractive}}, _extractive summary_{{end}}
 `))
 
 func (c *article) article(ctx context.Context, req botx.Request) ([]botx.Response, error) {
-	if _, err := url.ParseRequestURI(req.Text); err != nil {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	links := messageLinks(req)
+	if len(links) == 0 {
 		return []botx.Response{{
 			ChatID: req.Chat.ID,
-			Text: "Please, send me just a link without any other text.\n" +
+			Text: "Please, send me a link to an article, or forward me a post with links.\n" +
 				"You can send me a link to any article, in order to test my capability of shortening it.\n" +
 				"But do not overuse it, please, we don't have an unlimited amount of free API calls.",
 		}}, nil
 	}
 
-	err := c.API.SendMessage(ctx, botx.Response{
-		ChatID: req.Chat.ID,
-		Text:   "I'm working on it, please wait...",
-	})
+	return c.reply(ctx, req, u, links)
+}
+
+// autoSummarize summarizes links in the message posted to the group,
+// if the group has opted in, see Ctrl.autoSummarize.
+func (c *article) autoSummarize(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	if !u.AutoSummarize {
+		return nil, nil
+	}
+
+	links := messageLinks(req)
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	return c.reply(ctx, req, u, links)
+}
+
+// reply summarizes articles and sends summaries in a single response to the request,
+// in groups the response is a reply to the message with links.
+func (c *article) reply(ctx context.Context, req botx.Request, u store.User, links []string) ([]botx.Response, error) {
+	start := botx.Response{ChatID: req.Chat.ID, Text: "I'm working on it, please wait..."}
+	if req.Chat.IsGroup() {
+		start.ReplyToMessageID = req.MessageID
+	}
+
+	msgID, err := c.API.SendMessage(ctx, start)
 	if err != nil {
 		return nil, fmt.Errorf("send start message: %w", err)
 	}
 
-	article, err := c.Service.GetArticle(ctx, req.Text)
+	p := &placeholder{Logger: c.Logger, API: c.API, ChatID: req.Chat.ID, MessageID: msgID}
+
+	if len(links) > 1 {
+		return c.summarizeAll(ctx, p, links, u.Preferences)
+	}
+
+	article, resps, err := c.summarize(ctx, p, links[0], u.Preferences)
+	if err != nil || article.URL == "" {
+		return resps, err
+	}
+
+	// the user will receive the article anyway, so don't fail the request
+	if err = recordHistory(ctx, c.Store, req.Chat.ID, article, store.SourceRequest); err != nil {
+		c.Logger.WarnCtx(ctx, "failed to record history", slog.Any("err", err))
+	}
+
+	return resps, nil
+}
+
+// summarizeAll summarizes articles concurrently and replaces the placeholder
+// with all summaries, articles that couldn't be summarized are mentioned
+// in the response, the request fails only if none of articles is summarized.
+func (c *article) summarizeAll(
+	ctx context.Context,
+	p *placeholder,
+	links []string,
+	prefs store.Preferences,
+) ([]botx.Response, error) {
+	p.update(ctx, fmt.Sprintf("Summarizing %d articles, it may take a while...", len(links)))
+
+	texts := make([]string, len(links))
+	errs := make([]error, len(links))
+	sema := make(chan struct{}, maxConcurrentLinks)
+
+	wg := &sync.WaitGroup{}
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+
+			sema <- struct{}{}
+			defer func() { <-sema }()
+
+			article, err := getArticle(ctx, c.Store, c.Service, link, prefs)
+			if err != nil {
+				errs[i] = fmt.Errorf("get article %s: %w", link, err)
+				return
+			}
+
+			if texts[i], errs[i] = renderArticle(article); errs[i] != nil {
+				return
+			}
+
+			if err = recordHistory(ctx, c.Store, p.ChatID, article, store.SourceRequest); err != nil {
+				c.Logger.WarnCtx(ctx, "failed to record history", slog.Any("err", err))
+			}
+		}(i, link)
+	}
+	wg.Wait()
+
+	var failed []error
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, revisor.ErrTooManyTokens):
+			texts[i] = escapeMarkdown(fmt.Sprintf("%s\n%s", links[i], tooLongText(err)))
+		default:
+			failed = append(failed, err)
+			texts[i] = escapeMarkdown(fmt.Sprintf("%s\nI couldn't summarize this article.", links[i]))
+		}
+	}
+
+	if len(failed) == len(links) {
+		p.remove(ctx)
+		return nil, errors.Join(failed...)
+	}
+
+	if len(failed) > 0 {
+		c.Logger.WarnCtx(ctx, "failed to summarize some articles", slog.Any("err", errors.Join(failed...)))
+	}
+
+	return p.replace(ctx, botx.Response{
+		ChatID:    p.ChatID,
+		Text:      strings.Join(texts, "\n\n"),
+		ParseMode: parseMode,
+	}), nil
+}
+
+// summarize summarizes the article and replaces the placeholder with the summary,
+// the returned article is empty if the article couldn't be summarized.
+func (c *article) summarize(
+	ctx context.Context,
+	p *placeholder,
+	articleURL string,
+	prefs store.Preferences,
+) (store.Article, []botx.Response, error) {
+	ctx = revisor.ContextWithProgress(ctx, func(stage revisor.Stage) { p.update(ctx, stageMessages[stage]) })
+
+	article, err := getArticle(ctx, c.Store, c.Service, articleURL, prefs)
+	if err != nil {
+		resps, err := p.fail(ctx, err)
+		return store.Article{}, resps, err
+	}
+
+	resps, err := p.show(ctx, article, articleButtons(article, nil))
 	if err != nil {
-		if errors.Is(err, revisor.ErrTooManyTokens) {
-			return []botx.Response{{
-				ChatID: req.Chat.ID,
-				Text: "Article you provided is too long, I can't summarize it.\n" +
-					"Article content should be less than 4000 words.",
-			}}, nil
+		return store.Article{}, nil, err
+	}
+
+	return article, resps, nil
+}
+
+// tooLongText explains that the article is too long to summarize.
+func tooLongText(err error) string {
+	text := "Article you provided is too long, I can't summarize it."
+	var tmErr *revisor.TooManyTokensError
+	if errors.As(err, &tmErr) {
+		text += fmt.Sprintf("\nIt exceeds the limit by %d tokens (%d of %d).",
+			tmErr.Tokens-tmErr.Limit, tmErr.Tokens, tmErr.Limit)
+	}
+	return text
+}
+
+var stageMessages = map[revisor.Stage]string{
+	revisor.StageFetching:    "Fetching the article...",
+	revisor.StageExtracting:  "Extracting the text of the article...",
+	revisor.StageSummarizing: "Summarizing the article, it may take a while...",
+}
+
+// placeholder is a message, that shows the progress of the request
+// and is replaced by the result in the end.
+type placeholder struct {
+	Logger    *slog.Logger
+	API       botx.API
+	ChatID    string
+	MessageID string
+}
+
+// update replaces the text of the placeholder, failures are only logged,
+// as the progress is not essential.
+func (p *placeholder) update(ctx context.Context, text string) {
+	if err := p.API.EditMessage(ctx, p.MessageID, botx.Response{ChatID: p.ChatID, Text: text}); err != nil {
+		p.Logger.WarnCtx(ctx, "failed to update progress message", slog.Any("err", err))
+	}
+}
+
+// replace replaces the placeholder with the response,
+// the response is returned back if the placeholder couldn't be edited,
+// so that it is sent as a new message.
+func (p *placeholder) replace(ctx context.Context, resp botx.Response) []botx.Response {
+	if err := p.API.EditMessage(ctx, p.MessageID, resp); err != nil {
+		p.Logger.WarnCtx(ctx, "failed to replace progress message", slog.Any("err", err))
+		return []botx.Response{resp}
+	}
+	return nil
+}
+
+// show replaces the placeholder with the summary of the article.
+func (p *placeholder) show(ctx context.Context, a store.Article, buttons [][]botx.Button) ([]botx.Response, error) {
+	result, err := renderArticle(a)
+	if err != nil {
+		p.remove(ctx)
+		return nil, err
+	}
+
+	return p.replace(ctx, botx.Response{
+		ChatID:    p.ChatID,
+		Text:      result,
+		ParseMode: parseMode,
+		Buttons:   buttons,
+		Card:      articleCard(a),
+	}), nil
+}
+
+// fail replaces the placeholder with the explanation, if the article
+// is too long, otherwise removes it and returns the error.
+func (p *placeholder) fail(ctx context.Context, err error) ([]botx.Response, error) {
+	if errors.Is(err, revisor.ErrTooManyTokens) {
+		return p.replace(ctx, botx.Response{ChatID: p.ChatID, Text: tooLongText(err)}), nil
+	}
+
+	p.remove(ctx)
+	return nil, fmt.Errorf("get article: %w", err)
+}
+
+// remove deletes the placeholder.
+func (p *placeholder) remove(ctx context.Context) {
+	if err := p.API.DeleteMessage(ctx, p.ChatID, p.MessageID); err != nil {
+		p.Logger.WarnCtx(ctx, "failed to delete progress message", slog.Any("err", err))
+	}
+}
+
+// getArticle returns the previously summarized article from the store,
+// if it was summarized with the same preferences by the current summarizer,
+// otherwise summarizes it and saves the result to the store.
+func getArticle(
+	ctx context.Context,
+	s store.Interface,
+	svc *revisor.Service,
+	u string,
+	prefs store.Preferences,
+) (store.Article, error) {
+	stored, err := s.GetArticle(ctx, u)
+	switch {
+	// extractive summaries are stored only for the history,
+	// the language model may be available now
+	case err == nil && !stored.Extractive && stored.Preferences.Key() == prefs.Key() &&
+		stored.Summarizer == svc.SummarizerName():
+		return stored, nil
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		return store.Article{}, fmt.Errorf("get article from store: %w", err)
+	}
+
+	article, err := svc.GetArticle(ctx, u, prefs)
+	if err != nil {
+		// the previous summary is better than nothing, if it suits the user
+		if stored.URL != "" && stored.Preferences.Key() == prefs.Key() {
+			return stored, nil
 		}
-		return nil, fmt.Errorf("get article: %w", err)
+		return store.Article{}, err
+	}
+
+	if err = s.PutArticle(ctx, article); err != nil {
+		return store.Article{}, fmt.Errorf("save article: %w", err)
 	}
 
+	return article, nil
+}
+
+func recordHistory(ctx context.Context, s store.Interface, chatID string, a store.Article, src store.Source) error {
+	summary := a.Summary()
+	err := s.AddHistory(ctx, chatID, store.HistoryEntry{URL: a.URL, Source: src, ReceivedAt: time.Now(), Summary: &summary})
+	if err != nil {
+		return fmt.Errorf("add article %s to history: %w", a.URL, err)
+	}
+	return nil
+}
+
+func renderArticle(article store.Article) (string, error) {
 	sb := &strings.Builder{}
-	if err = articleMessageTmpl.Execute(sb, article); err != nil {
-		return nil, fmt.Errorf("execute article message template: %w", err)
+	if err := articleMessageTmpl.Execute(sb, article); err != nil {
+		return "", fmt.Errorf("execute article message template: %w", err)
 	}
 
-	result := strings.TrimSpace(sb.String())
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// articleCard returns the summary of the article for platforms with rich messages.
+func articleCard(article store.Article) *botx.Card {
+	card := &botx.Card{
+		Title:       article.Title,
+		URL:         article.URL,
+		Author:      article.Author,
+		Description: article.BulletPoints,
+	}
+	if article.Extractive {
+		card.Footer = "extractive summary"
+	}
 
-	return []botx.Response{{
-		ChatID: req.Chat.ID,
-		Text:   result,
-	}}, nil
+	return card
 }
@@ -5,9 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/Semior001/newsfeed/app/feed"
 	"github.com/Semior001/newsfeed/app/revisor"
 	"github.com/Semior001/newsfeed/app/store"
 	"github.com/Semior001/newsfeed/pkg/botx"
@@ -21,7 +21,11 @@ type Ctrl struct {
 	Logger         *slog.Logger
 	Store          store.Interface
 	Service        *revisor.Service
+	Prompts        *revisor.Prompts
+	Fetcher        *feed.Fetcher
 	API            botx.API
+	Usernames      []string // of the bot on each platform, to recognise commands and mentions in groups
+	DefaultFeeds   []string
 	AdminIDs       []string
 	AuthToken      string
 	HandlerTimeout time.Duration
@@ -30,6 +34,7 @@ type Ctrl struct {
 // Routes returns a multiplexer for bot controllers.
 func (c *Ctrl) Routes() *botx.Router {
 	rtr := botx.NewRouter()
+	rtr.Username(c.Usernames...)
 
 	rtr.Use(
 		botmw.RequestID(),
@@ -41,13 +46,47 @@ func (c *Ctrl) Routes() *botx.Router {
 	)
 
 	articleCtrl := &article{
+		Logger:  c.Logger,
 		API:     c.API,
+		Store:   c.Store,
 		Service: c.Service,
 	}
 	rtr.NotFound(articleCtrl.article)
+	rtr.Unaddressed(articleCtrl.autoSummarize)
+	rtr.AddCallback(feedbackCallbackPrefix, articleCtrl.feedback)
+	rtr.AddCallback(reasonCallbackPrefix, articleCtrl.reason)
+	rtr.AddCallback(detailCallbackPrefix, articleCtrl.detail)
+	rtr.AddCallback(translateCallbackPrefix, articleCtrl.translate)
+	rtr.AddCallback(translateToCallbackPrefix, articleCtrl.translateTo)
+
+	inlineCtrl := &inline{
+		Logger:  c.Logger,
+		Store:   c.Store,
+		Service: c.Service,
+		Timeout: c.HandlerTimeout,
+	}
+	rtr.Inline(inlineCtrl.query)
 
 	rtr.Add("/start", c.start)
 	rtr.Add("/stop", c.stop)
+	rtr.Add("/schedule", c.schedule)
+	rtr.Add("/timezone", c.timezone)
+	rtr.Add("/lang", c.lang)
+	rtr.Add("/style", c.style)
+	rtr.Add("/autosummarize", c.autoSummarize)
+
+	subsCtrl := &subscriptions{
+		Store:   c.Store,
+		Fetcher: c.Fetcher,
+	}
+	rtr.Add("/subscribe", subsCtrl.subscribe)
+	rtr.Add("/unsubscribe", subsCtrl.unsubscribe)
+	rtr.Add("/feeds", subsCtrl.list)
+	rtr.AddCallback(unsubscribeCallbackPrefix, subsCtrl.unsubscribeCallback)
+
+	historyCtrl := &history{Store: c.Store}
+	rtr.Add("/history", historyCtrl.history)
+	rtr.Add("/find", historyCtrl.find)
 
 	rtr.Group(func(rtr *botx.Router) {
 		rtr.Use(c.ensureAdmin)
@@ -55,16 +94,38 @@ func (c *Ctrl) Routes() *botx.Router {
 		adminCtrl := &admin{
 			Store:   c.Store,
 			Service: c.Service,
+			Prompts: c.Prompts,
 		}
 
 		rtr.Add("/list", adminCtrl.list)
 		rtr.Add("/delete", adminCtrl.delete)
 		rtr.Add("/cache", adminCtrl.cacheStats)
+		rtr.Add("/prompts", adminCtrl.prompts)
+		rtr.Add("/quality", adminCtrl.quality)
 	})
 
 	return rtr
 }
 
+// Commands returns commands, that are available to every user,
+// admin commands are left out, as they aren't shown to users.
+func (c *Ctrl) Commands() []botx.Command {
+	return []botx.Command{
+		{Name: "start", Description: "subscribe to news updates"},
+		{Name: "stop", Description: "unsubscribe from news updates"},
+		{Name: "subscribe", Description: "subscribe to the feed by its url"},
+		{Name: "unsubscribe", Description: "unsubscribe from the feed by its url or number"},
+		{Name: "feeds", Description: "list subscribed feeds"},
+		{Name: "schedule", Description: "show or change the schedule of digests"},
+		{Name: "timezone", Description: "show or change the timezone of the schedule"},
+		{Name: "lang", Description: "show or change the language of summaries"},
+		{Name: "style", Description: "show or change the style of summaries"},
+		{Name: "autosummarize", Description: "turn summarizing of posted links on or off"},
+		{Name: "history", Description: "show the last received articles"},
+		{Name: "find", Description: "search the received articles"},
+	}
+}
+
 func (c *Ctrl) start(ctx context.Context, req botx.Request) ([]botx.Response, error) {
 	u, ok := userFromContext(ctx)
 	if !ok {
@@ -112,6 +173,28 @@ func (c *Ctrl) ensureAdmin(h botx.Handler) botx.Handler {
 func (c *Ctrl) ensureAuthorized(h botx.Handler) botx.Handler {
 	return func(ctx context.Context, req botx.Request) ([]botx.Response, error) {
 		u, err := c.Store.Get(ctx, req.Chat.ID)
+
+		// inline queries come from other chats, so the user
+		// is asked to open the private chat with the bot
+		if req.Kind == botx.RequestInline && (err != nil || !u.Authorized) {
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return nil, fmt.Errorf("get user: %w", err)
+			}
+
+			return []botx.Response{{
+				InlineQueryID: req.InlineQueryID,
+				Text:          "Start the bot to summarize articles",
+			}}, nil
+		}
+
+		// the group talks about something else, not to the bot
+		if req.Kind == botx.RequestMessage && !req.Addressed && (err != nil || !u.Authorized) {
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return nil, fmt.Errorf("get user: %w", err)
+			}
+			return nil, nil
+		}
+
 		if err != nil {
 			if errors.Is(err, store.ErrNotFound) {
 				return c.register(ctx, req)
@@ -135,6 +218,10 @@ func (c *Ctrl) ensureAuthorized(h botx.Handler) botx.Handler {
 				return nil, fmt.Errorf("update user: %w", err)
 			}
 
+			if err := c.subscribeToDefaultFeeds(ctx, u.ChatID); err != nil {
+				return nil, err
+			}
+
 			return []botx.Response{{
 				ChatID: req.Chat.ID,
 				Text: "You are now authorized.\n" +
@@ -147,10 +234,35 @@ func (c *Ctrl) ensureAuthorized(h botx.Handler) botx.Handler {
 	}
 }
 
+// subscribeToDefaultFeeds subscribes the user to the default feeds, feeds are
+// added back, if they were deleted after their last subscriber had left.
+func (c *Ctrl) subscribeToDefaultFeeds(ctx context.Context, chatID string) error {
+	for _, feedURL := range c.DefaultFeeds {
+		_, err := c.Store.GetFeed(ctx, feedURL)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			if err = c.Store.PutFeed(ctx, store.Feed{URL: feedURL}); err != nil {
+				return fmt.Errorf("put default feed %s: %w", feedURL, err)
+			}
+		case err != nil:
+			return fmt.Errorf("get default feed %s: %w", feedURL, err)
+		}
+
+		if err = c.Store.Subscribe(ctx, chatID, feedURL); err != nil {
+			return fmt.Errorf("subscribe to default feed %s: %w", feedURL, err)
+		}
+	}
+
+	return nil
+}
+
 func (c *Ctrl) register(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	// in groups the whole chat is authorized, not its members
 	u := store.User{
 		ChatID:   req.Chat.ID,
 		Username: req.Chat.Username,
+		Platform: req.Chat.Platform,
+		Title:    req.Chat.Title,
 	}
 
 	if err := c.Store.Put(ctx, u); err != nil {
@@ -160,7 +272,12 @@ func (c *Ctrl) register(ctx context.Context, req botx.Request) ([]botx.Response,
 	const response = "Hello! In order to subscribe to news, you need to provide a token,\n" +
 		"please ask admin for it and then send it to me."
 
-	if err := c.NotifyAdmins(ctx, fmt.Sprintf("new user: %s", req.Chat.Username)); err != nil {
+	notification := fmt.Sprintf("new user: %s", req.Chat.Username)
+	if req.Chat.IsGroup() {
+		notification = fmt.Sprintf("new group: %s", req.Chat.Title)
+	}
+
+	if err := c.NotifyAdmins(ctx, notification); err != nil {
 		c.Logger.WarnCtx(ctx, "notify admins about registered user", slog.Any("err", err))
 	}
 
@@ -173,7 +290,7 @@ func (c *Ctrl) register(ctx context.Context, req botx.Request) ([]botx.Response,
 // NotifyAdmins sends a message to all admins.
 func (c *Ctrl) NotifyAdmins(ctx context.Context, msg string) error {
 	for _, adminID := range c.AdminIDs {
-		if err := c.API.SendMessage(ctx, botx.Response{
+		if _, err := c.API.SendMessage(ctx, botx.Response{
 			ChatID: adminID,
 			Text:   msg,
 		}); err != nil {
@@ -184,18 +301,10 @@ func (c *Ctrl) NotifyAdmins(ctx context.Context, msg string) error {
 	return nil
 }
 
-var mdEscaper = strings.NewReplacer(
-	`*`, `\*`,
-	`_`, `\_`,
-	"`", "\\`",
-	"[", "\\[",
-	"]", "\\]",
-	"(", "\\(",
-	")", "\\)",
-	"~", "\\~",
-	">", "\\>",
-)
+// parseMode is used for messages with formatting,
+// the rest of messages are sent as plain text.
+const parseMode = botx.ParseModeMarkdownV2
 
-func escapeMarkdown(s string) string {
-	return mdEscaper.Replace(s)
-}
+func escapeMarkdown(s string) string { return parseMode.Escape(s) }
+
+func escapeURL(s string) string { return parseMode.EscapeURL(s) }
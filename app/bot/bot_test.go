@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/revisor"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestCtrl_DefaultFeeds(t *testing.T) {
+	const feedURL = "https://example.com/feed"
+
+	c, _, s := newTestCtrl(t)
+	c.DefaultFeeds = []string{feedURL}
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	authorize(t, rtr, "1")
+	subs, err := s.ListSubscriptions(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []store.Feed{{URL: feedURL}}, subs)
+
+	// the last subscriber leaves, so the feed is not polled anymore
+	resps := handle(t, rtr, botx.Request{Chat: botx.Chat{ID: "1"}, Text: "/unsubscribe " + feedURL})
+	require.Len(t, resps, 1)
+	_, err = s.GetFeed(ctx, feedURL)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	// the feed is added back for the new user
+	authorize(t, rtr, "2")
+	f, err := s.GetFeed(ctx, feedURL)
+	require.NoError(t, err)
+	assert.Equal(t, feedURL, f.URL)
+
+	subscribers, err := s.ListSubscribers(ctx, feedURL)
+	require.NoError(t, err)
+	require.Len(t, subscribers, 1)
+	assert.Equal(t, "2", subscribers[0].ChatID)
+}
+
+func TestCtrl_ensureAuthorized(t *testing.T) {
+	c, api, s := newTestCtrl(t)
+	c.AdminIDs = []string{"42"}
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	req := botx.Request{Chat: botx.Chat{ID: "1", Username: "user"}, Text: "/history"}
+
+	resps := handle(t, rtr, req)
+	require.Len(t, resps, 1)
+	assert.Contains(t, resps[0].Text, "you need to provide a token")
+	assert.Equal(t, []botx.Response{{ChatID: "42", Text: "new user: user"}}, api.sent())
+
+	resps = handle(t, rtr, req)
+	require.Len(t, resps, 1)
+	assert.Equal(t, "You are not authorized, please provide a token.", resps[0].Text)
+
+	authorize(t, rtr, "1")
+	u, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, u.Authorized)
+	assert.True(t, u.Subscribed)
+
+	resps = handle(t, rtr, req)
+	require.Len(t, resps, 1)
+	assert.Equal(t, "You haven't received any articles yet.", resps[0].Text)
+
+	// unauthorized groups are not bothered by messages, that are not for the bot
+	resps = handle(t, rtr, botx.Request{Chat: botx.Chat{ID: "-1", Type: botx.ChatGroup}, Text: "hello"})
+	assert.Empty(t, resps)
+}
+
+// testAuthToken is the token to authorize users in tests.
+const testAuthToken = "token"
+
+// newTestCtrl returns the controller with a temporary store,
+// that talks to the fake API.
+func newTestCtrl(t *testing.T) (*Ctrl, *fakeAPI, *store.Bolt) {
+	s, err := store.NewBolt(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, s.Close()) })
+
+	api := &fakeAPI{}
+	return &Ctrl{
+		Logger:         slog.Default(),
+		Store:          s,
+		API:            api,
+		AuthToken:      testAuthToken,
+		HandlerTimeout: time.Minute,
+	}, api, s
+}
+
+// authorize registers the private chat and authorizes it with the token.
+func authorize(t *testing.T, rtr *botx.Router, chatID string) {
+	chat := botx.Chat{ID: chatID, Username: "user" + chatID}
+	handle(t, rtr, botx.Request{Chat: chat, Text: "/start"})
+
+	resps := handle(t, rtr, botx.Request{Chat: chat, Text: testAuthToken})
+	require.Len(t, resps, 1)
+	require.Contains(t, resps[0].Text, "You are now authorized.")
+}
+
+func handle(t *testing.T, rtr *botx.Router, req botx.Request) []botx.Response {
+	resps, err := rtr.Handle(context.Background(), req)
+	require.NoError(t, err)
+	return resps
+}
+
+// testArticleHTML is the page of the article, that is served to the test service.
+const testArticleHTML = `<html><head><title>Test article</title></head><body><article>
+<h1>Test article</h1>
+<p>The first sentence of the article is about cats. The second sentence tells about dogs.
+The third sentence is about birds, which fly over the city. The fourth sentence is about fish.
+The fifth sentence is about the weather, that is good today.</p>
+</article></body></html>`
+
+// newTestService returns the service, that summarizes articles extractively,
+// and the link to the article, served by the test server.
+func newTestService(t *testing.T) (*revisor.Service, string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, err := w.Write([]byte(testArticleHTML))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+
+	svc := revisor.NewService(slog.Default(), ts.Client(), revisor.NewExtractive(3), nil,
+		revisor.NewExtractor(), revisor.NewLRUCache(100, 0))
+	return svc, ts.URL + "/article"
+}
+
+// fakeAPI records messages, that are sent and edited by handlers directly.
+type fakeAPI struct {
+	mu       sync.Mutex
+	lastID   int
+	messages []botx.Response
+	edits    []botx.Response
+	sendErr  error // returned by SendMessage, if set
+}
+
+func (f *fakeAPI) Updates() <-chan botx.Request { return nil }
+
+func (f *fakeAPI) SendMessage(_ context.Context, resp botx.Response) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.sendErr != nil {
+		return "", f.sendErr
+	}
+
+	f.lastID++
+	f.messages = append(f.messages, resp)
+	return strconv.Itoa(f.lastID), nil
+}
+
+func (f *fakeAPI) EditMessage(_ context.Context, _ string, resp botx.Response) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.edits = append(f.edits, resp)
+	return nil
+}
+
+func (f *fakeAPI) DeleteMessage(context.Context, string, string) error { return nil }
+
+func (f *fakeAPI) AnswerCallback(context.Context, string, string) error { return nil }
+
+func (f *fakeAPI) AnswerInline(context.Context, botx.Response) error { return nil }
+
+// failSends makes SendMessage fail with the error, nil restores it.
+func (f *fakeAPI) failSends(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sendErr = err
+}
+
+// sent returns and forgets the sent messages.
+func (f *fakeAPI) sent() []botx.Response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msgs := f.messages
+	f.messages = nil
+	return msgs
+}
+
+// lastEdit returns the last edit of messages and forgets all edits.
+func (f *fakeAPI) lastEdit() botx.Response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.edits) == 0 {
+		return botx.Response{}
+	}
+
+	last := f.edits[len(f.edits)-1]
+	f.edits = nil
+	return last
+}
@@ -0,0 +1,344 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/revisor"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"golang.org/x/exp/slog"
+)
+
+// Prefixes of callback data, telegram limits the data by 64 bytes,
+// so articles and feeds are referred by store.ShortID.
+const (
+	feedbackCallbackPrefix    = "fb:"    // fb:<up|down>:<article id>:<summarizer id>
+	reasonCallbackPrefix      = "fbr:"   // fbr:<reason>:<article id>:<summarizer id>
+	detailCallbackPrefix      = "det:"   // det:<article id>
+	translateCallbackPrefix   = "tr:"    // tr:<article id>
+	translateToCallbackPrefix = "trto:"  // trto:<article id>:<language code>
+	unsubscribeCallbackPrefix = "unsub:" // unsub:<feed id>
+)
+
+// Ratings of summaries.
+const (
+	ratingUp   = "up"
+	ratingDown = "down"
+)
+
+// reasons of negative ratings, in the order of buttons.
+var reasons = []struct{ Code, Text string }{
+	{"inaccurate", "Inaccurate"},
+	{"missed", "Missed the point"},
+	{"long", "Too long"},
+	{"short", "Too short"},
+	{"language", "Poor language"},
+}
+
+// reasonsPerRow is the number of reason buttons in a row.
+const reasonsPerRow = 2
+
+// languagesPerRow is the number of language buttons in a row.
+const languagesPerRow = 3
+
+// articleButtons returns buttons under the summary of the article,
+// feed is set if the article came from the feed.
+func articleButtons(a store.Article, f *store.Feed) [][]botx.Button {
+	// the summary is rated, not the article, which may be resummarized later
+	summary := store.ShortID(a.URL) + ":" + store.SummarizerID(a.Summarizer)
+
+	buttons := [][]botx.Button{
+		{
+			{Text: "👍", Data: feedbackCallbackPrefix + ratingUp + ":" + summary},
+			{Text: "👎", Data: feedbackCallbackPrefix + ratingDown + ":" + summary},
+		},
+		actionButtons(a),
+	}
+
+	if f != nil {
+		buttons = append(buttons, []botx.Button{{
+			Text: "Unsubscribe from this feed",
+			Data: unsubscribeCallbackPrefix + store.ShortID(f.URL),
+		}})
+	}
+
+	return buttons
+}
+
+// actionButtons returns buttons to get another summary of the article.
+func actionButtons(a store.Article) []botx.Button {
+	id := store.ShortID(a.URL)
+
+	actions := []botx.Button{{Text: "Translate", Data: translateCallbackPrefix + id}}
+	if a.Style != store.StyleDetailed {
+		actions = append([]botx.Button{{Text: "More detail", Data: detailCallbackPrefix + id}}, actions...)
+	}
+
+	return actions
+}
+
+// feedback saves the rating of the summary, and asks for the reason
+// if the rating is negative.
+func (c *article) feedback(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	tokens := strings.SplitN(strings.TrimPrefix(req.Text, feedbackCallbackPrefix), ":", 2)
+	if len(tokens) != 2 || (tokens[0] != ratingUp && tokens[0] != ratingDown) {
+		return nil, fmt.Errorf("invalid feedback callback %q", req.Text)
+	}
+
+	a, resps, err := c.ratedArticle(ctx, req, tokens[1])
+	if err != nil || a.URL == "" {
+		return resps, err
+	}
+
+	r := store.Rating{
+		ChatID:     req.Chat.ID,
+		URL:        a.URL,
+		Summarizer: a.Summarizer,
+		Positive:   tokens[0] == ratingUp,
+		RatedAt:    time.Now(),
+	}
+	if err = c.Store.PutRating(ctx, r); err != nil {
+		return nil, fmt.Errorf("put rating: %w", err)
+	}
+
+	resps = []botx.Response{{CallbackID: req.CallbackID, Text: "Thank you for your feedback!"}}
+	if r.Positive {
+		return resps, nil
+	}
+
+	var buttons [][]botx.Button
+	for i, reason := range reasons {
+		if i%reasonsPerRow == 0 {
+			buttons = append(buttons, nil)
+		}
+		buttons[len(buttons)-1] = append(buttons[len(buttons)-1], botx.Button{
+			Text: reason.Text,
+			Data: reasonCallbackPrefix + reason.Code + ":" + tokens[1],
+		})
+	}
+
+	return append(resps, botx.Response{
+		ReplyToMessageID: req.MessageID,
+		ChatID:           req.Chat.ID,
+		Text:             "What was wrong with the summary? It is optional, but it helps us to improve.",
+		Buttons:          buttons,
+	}), nil
+}
+
+// reason adds the reason to the negative rating of the summary.
+func (c *article) reason(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	tokens := strings.SplitN(strings.TrimPrefix(req.Text, reasonCallbackPrefix), ":", 2)
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("invalid reason callback %q", req.Text)
+	}
+
+	a, resps, err := c.ratedArticle(ctx, req, tokens[1])
+	if err != nil || a.URL == "" {
+		return resps, err
+	}
+
+	r, err := c.Store.GetRating(ctx, req.Chat.ID, a.URL, a.Summarizer)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		r = store.Rating{ChatID: req.Chat.ID, URL: a.URL, Summarizer: a.Summarizer}
+	case err != nil:
+		return nil, fmt.Errorf("get rating: %w", err)
+	}
+
+	r.Positive = false
+	r.Reason = tokens[0]
+	r.RatedAt = time.Now()
+	if err = c.Store.PutRating(ctx, r); err != nil {
+		return nil, fmt.Errorf("put rating: %w", err)
+	}
+
+	// the question is not needed anymore
+	err = c.API.EditMessage(ctx, req.MessageID, botx.Response{
+		ChatID: req.Chat.ID,
+		Text:   "Thank you, we will take it into account.",
+	})
+	if err != nil {
+		c.Logger.WarnCtx(ctx, "failed to edit reason question", slog.Any("err", err))
+	}
+
+	return []botx.Response{{CallbackID: req.CallbackID}}, nil
+}
+
+// ratedArticle returns the article by "<article id>:<summarizer id>" with
+// the summarizer of the rated summary, buttons of older messages have no
+// summarizer id, the summarizer of the stored summary is used for them.
+// If the article is not found, the article is empty and the responses
+// notify the user about it.
+func (c *article) ratedArticle(ctx context.Context, req botx.Request, ref string) (store.Article, []botx.Response, error) {
+	id, summarizerID, _ := strings.Cut(ref, ":")
+
+	notFound := []botx.Response{{
+		CallbackID: req.CallbackID,
+		Text:       "I don't remember this article anymore.",
+	}}
+
+	a, err := c.Store.GetArticleByID(ctx, id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return store.Article{}, notFound, nil
+	case err != nil:
+		return store.Article{}, nil, fmt.Errorf("get article by id: %w", err)
+	}
+
+	if summarizerID == "" || summarizerID == store.SummarizerID(a.Summarizer) {
+		return a, nil, nil
+	}
+
+	a.Summarizer, err = c.Store.GetSummarizer(ctx, summarizerID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return store.Article{}, notFound, nil
+	case err != nil:
+		return store.Article{}, nil, fmt.Errorf("get summarizer by id: %w", err)
+	}
+
+	return a, nil, nil
+}
+
+// detail replies to the summary with a detailed one.
+func (c *article) detail(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	prefs := u.Preferences
+	prefs.Style = store.StyleDetailed
+
+	return c.resummarize(ctx, req, strings.TrimPrefix(req.Text, detailCallbackPrefix), prefs)
+}
+
+// translate replies to the summary with a choice of languages.
+func (c *article) translate(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	id := strings.TrimPrefix(req.Text, translateCallbackPrefix)
+
+	codes := make([]string, 0, len(store.Languages))
+	for code := range store.Languages {
+		if store.Languages[code] != u.LanguageName() {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	var buttons [][]botx.Button
+	for i, code := range codes {
+		if i%languagesPerRow == 0 {
+			buttons = append(buttons, nil)
+		}
+		buttons[len(buttons)-1] = append(buttons[len(buttons)-1], botx.Button{
+			Text: store.Languages[code],
+			Data: translateToCallbackPrefix + id + ":" + code,
+		})
+	}
+
+	return []botx.Response{
+		{CallbackID: req.CallbackID},
+		{
+			ReplyToMessageID: req.MessageID,
+			ChatID:           req.Chat.ID,
+			Text:             "Choose the language of the summary:",
+			Buttons:          buttons,
+		},
+	}, nil
+}
+
+// translateTo replaces the choice of languages with the summary in the chosen language.
+func (c *article) translateTo(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(req.Text, translateToCallbackPrefix), ":")
+	if len(tokens) != 2 {
+		return nil, fmt.Errorf("invalid translate callback %q", req.Text)
+	}
+
+	if _, ok := store.Languages[tokens[1]]; !ok {
+		return nil, fmt.Errorf("unknown language %q", tokens[1])
+	}
+
+	prefs := u.Preferences
+	prefs.Language = tokens[1]
+
+	p := &placeholder{Logger: c.Logger, API: c.API, ChatID: req.Chat.ID, MessageID: req.MessageID}
+	return c.resummarizeTo(ctx, req, p, tokens[0], prefs)
+}
+
+// resummarize replies to the message of the callback with the summary
+// of the article, made with the given preferences.
+func (c *article) resummarize(
+	ctx context.Context,
+	req botx.Request,
+	articleID string,
+	prefs store.Preferences,
+) ([]botx.Response, error) {
+	msgID, err := c.API.SendMessage(ctx, botx.Response{
+		ReplyToMessageID: req.MessageID,
+		ChatID:           req.Chat.ID,
+		Text:             "I'm working on it, please wait...",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send start message: %w", err)
+	}
+
+	p := &placeholder{Logger: c.Logger, API: c.API, ChatID: req.Chat.ID, MessageID: msgID}
+	return c.resummarizeTo(ctx, req, p, articleID, prefs)
+}
+
+func (c *article) resummarizeTo(
+	ctx context.Context,
+	req botx.Request,
+	p *placeholder,
+	articleID string,
+	prefs store.Preferences,
+) ([]botx.Response, error) {
+	// summarizing takes a while, so stop the loading of the button right away,
+	// telegram ignores the answer of the bot after the handler finishes
+	if err := c.API.AnswerCallback(ctx, req.CallbackID, ""); err != nil {
+		c.Logger.WarnCtx(ctx, "failed to answer callback", slog.Any("err", err))
+	}
+
+	stored, err := c.Store.GetArticleByID(ctx, articleID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return p.replace(ctx, botx.Response{
+				ChatID: req.Chat.ID,
+				Text:   "I don't remember this article anymore, please, send me the link again.",
+			}), nil
+		}
+
+		p.remove(ctx)
+		return nil, fmt.Errorf("get article by id: %w", err)
+	}
+
+	// the summary is made for this message only, so it is not saved, to leave
+	// the summary, that digests, histories and ratings refer to, as is
+	ctx = revisor.ContextWithProgress(ctx, func(stage revisor.Stage) { p.update(ctx, stageMessages[stage]) })
+
+	article, err := c.Service.GetArticle(ctx, stored.URL, prefs)
+	switch {
+	case err != nil && stored.Preferences.Key() == prefs.Key():
+		article = stored // the stored summary is better than nothing
+	case err != nil:
+		return p.fail(ctx, err)
+	}
+
+	// ratings refer to the stored summary, so the transient one is not rated
+	return p.show(ctx, article, [][]botx.Button{actionButtons(article)})
+}
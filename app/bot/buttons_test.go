@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticle_buttons(t *testing.T) {
+	c, api, s := newTestCtrl(t)
+	var articleURL string
+	c.Service, articleURL = newTestService(t)
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	authorize(t, rtr, "1")
+	chat := botx.Chat{ID: "1"}
+
+	resps := handle(t, rtr, botx.Request{Chat: chat, MessageID: "1", Text: articleURL})
+	assert.Empty(t, resps)
+	require.Len(t, api.sent(), 1)
+
+	summary := api.lastEdit()
+	assert.Contains(t, summary.Text, "The first sentence of the article is about cats")
+	require.Len(t, summary.Buttons, 2)
+	require.Len(t, summary.Buttons[0], 2)
+	require.Len(t, summary.Buttons[1], 2)
+
+	up, down := summary.Buttons[0][0].Data, summary.Buttons[0][1].Data
+	detail, translate := summary.Buttons[1][0].Data, summary.Buttons[1][1].Data
+
+	stored, err := s.GetArticle(ctx, articleURL)
+	require.NoError(t, err)
+
+	callback := func(data string) []botx.Response {
+		return handle(t, rtr, botx.Request{
+			Kind:       botx.RequestCallback,
+			Chat:       chat,
+			MessageID:  "2",
+			CallbackID: "cb",
+			Text:       data,
+		})
+	}
+
+	t.Run("positive feedback", func(t *testing.T) {
+		resps := callback(up)
+		require.Len(t, resps, 1)
+		assert.Equal(t, botx.Response{CallbackID: "cb", Text: "Thank you for your feedback!"}, resps[0])
+
+		r, err := s.GetRating(ctx, "1", articleURL, stored.Summarizer)
+		require.NoError(t, err)
+		assert.True(t, r.Positive)
+	})
+
+	t.Run("negative feedback with reason", func(t *testing.T) {
+		resps := callback(down)
+		require.Len(t, resps, 2)
+		assert.Equal(t, "What was wrong with the summary? It is optional, but it helps us to improve.", resps[1].Text)
+		require.NotEmpty(t, resps[1].Buttons)
+
+		reason := resps[1].Buttons[1][0] // "Too long"
+		assert.Equal(t, "Too long", reason.Text)
+
+		resps = callback(reason.Data)
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Thank you, we will take it into account.", api.lastEdit().Text)
+
+		r, err := s.GetRating(ctx, "1", articleURL, stored.Summarizer)
+		require.NoError(t, err)
+		assert.False(t, r.Positive)
+		assert.Equal(t, "long", r.Reason)
+	})
+
+	t.Run("detail", func(t *testing.T) {
+		resps := callback(detail)
+		assert.Empty(t, resps)
+
+		msgs := api.sent()
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "2", msgs[0].ReplyToMessageID)
+
+		detailed := api.lastEdit()
+		assert.Contains(t, detailed.Text, "The first sentence of the article is about cats")
+		// the detailed summary is neither rated nor detailed again
+		assert.Equal(t, [][]botx.Button{{{Text: "Translate", Data: translate}}}, detailed.Buttons)
+
+		// the stored summary is left for digests, histories and ratings
+		a, err := s.GetArticle(ctx, articleURL)
+		require.NoError(t, err)
+		assert.Equal(t, stored, a)
+	})
+
+	t.Run("translate", func(t *testing.T) {
+		resps := callback(translate)
+		require.Len(t, resps, 2)
+		assert.Equal(t, "Choose the language of the summary:", resps[1].Text)
+
+		var english botx.Button
+		for _, row := range resps[1].Buttons {
+			for _, b := range row {
+				assert.NotEqual(t, store.Languages[store.DefaultLanguage], b.Text, "the language of the user is offered")
+				if b.Text == "English" {
+					english = b
+				}
+			}
+		}
+		require.NotEmpty(t, english.Data)
+
+		resps = callback(english.Data)
+		assert.Empty(t, resps)
+		assert.Empty(t, api.sent(), "the choice of languages is not replaced")
+		assert.Contains(t, api.lastEdit().Text, "The first sentence of the article is about cats")
+
+		a, err := s.GetArticle(ctx, articleURL)
+		require.NoError(t, err)
+		assert.Equal(t, stored, a)
+	})
+
+	t.Run("forgotten article", func(t *testing.T) {
+		resps := callback(detailCallbackPrefix + "unknown")
+		assert.Empty(t, resps)
+		require.Len(t, api.sent(), 1)
+		assert.Contains(t, api.lastEdit().Text, "I don't remember this article anymore")
+
+		resps = callback(feedbackCallbackPrefix + ratingUp + ":unknown")
+		require.Len(t, resps, 1)
+		assert.Equal(t, "I don't remember this article anymore.", resps[0].Text)
+	})
+}
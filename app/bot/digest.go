@@ -0,0 +1,269 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"golang.org/x/exp/slog"
+)
+
+var digestMessageTmpl = template.Must(template.New("digestMessage").
+	Funcs(template.FuncMap{"escapeMarkdown": escapeMarkdown, "escapeURL": escapeURL}).
+	Parse(`
+*Your news digest, {{len .}} article\(s\)*
+{{range .}}
+*{{.Title | escapeMarkdown}}*
+{{.BulletPoints | escapeMarkdown}}
+[source]({{.URL | escapeURL}})
+{{end}}
+`))
+
+const scheduleUsage = "Usage:\n" +
+	"/schedule now - receive every article as soon as it's ready\n" +
+	"/schedule daily HH:MM - receive a digest every day\n" +
+	"/schedule weekly <day> HH:MM - receive a digest every week, e.g. /schedule weekly mon 09:00\n" +
+	"Time is in your time zone, set it with /timezone <name>, e.g. /timezone Asia/Almaty"
+
+func (c *Ctrl) schedule(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) == 1 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("You receive news %s.\n\n%s", u.Schedule, scheduleUsage),
+		}}, nil
+	}
+
+	sched, err := parseSchedule(tokens[1:])
+	if err != nil {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("%s.\n\n%s", err, scheduleUsage),
+		}}, nil
+	}
+
+	u.Schedule = sched
+	// the first digest is sent at the next scheduled time
+	u.LastDigestAt = time.Now()
+	if err = c.Store.Put(ctx, u); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("You will receive news %s (%s).", sched, u.Location()),
+	}}, nil
+}
+
+func (c *Ctrl) timezone(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text: fmt.Sprintf("Your time zone is %s.\n"+
+				"Usage: /timezone <name>, e.g. /timezone Europe/Moscow", u.Location()),
+		}}, nil
+	}
+
+	loc, err := time.LoadLocation(tokens[1])
+	if err != nil {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Unknown time zone, please use a name from the IANA database, e.g. Asia/Almaty.",
+		}}, nil
+	}
+
+	u.Timezone = loc.String()
+	if err = c.Store.Put(ctx, u); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("Your time zone is set to %s.", u.Timezone),
+	}}, nil
+}
+
+func parseSchedule(tokens []string) (store.Schedule, error) {
+	switch tokens[0] {
+	case "now", "immediate":
+		return store.Schedule{Mode: store.ScheduleImmediate}, nil
+	case "daily":
+		if len(tokens) != 2 {
+			return store.Schedule{}, fmt.Errorf("time of day is required")
+		}
+
+		at, err := parseTimeOfDay(tokens[1])
+		if err != nil {
+			return store.Schedule{}, err
+		}
+
+		return store.Schedule{Mode: store.ScheduleDaily, At: at}, nil
+	case "weekly":
+		if len(tokens) != 3 {
+			return store.Schedule{}, fmt.Errorf("day of week and time of day are required")
+		}
+
+		wd, err := parseWeekday(tokens[1])
+		if err != nil {
+			return store.Schedule{}, err
+		}
+
+		at, err := parseTimeOfDay(tokens[2])
+		if err != nil {
+			return store.Schedule{}, err
+		}
+
+		return store.Schedule{Mode: store.ScheduleWeekly, At: at, Weekday: wd}, nil
+	default:
+		return store.Schedule{}, fmt.Errorf("unknown schedule %q", tokens[0])
+	}
+}
+
+// parseTimeOfDay parses HH:MM into minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("time must be in HH:MM format")
+	}
+
+	h, herr := strconv.Atoi(hh)
+	m, merr := strconv.Atoi(mm)
+	if herr != nil || merr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("time must be in HH:MM format")
+	}
+
+	return h*60 + m, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day of week %q", s)
+}
+
+// digestCheckInterval is how often the scheduler checks for due digests.
+const digestCheckInterval = time.Minute
+
+// RunDigests sends digests to users with scheduled delivery until context is dead.
+// Pending articles are kept in the store, so digests missed during downtime
+// are sent right after the start.
+func (c *Ctrl) RunDigests(ctx context.Context) error {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.SendDueDigests(ctx, time.Now()); err != nil {
+			c.Logger.WarnCtx(ctx, "failed to send digests", slog.Any("err", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDueDigests sends digests to all users, whose digest time has come at the given moment.
+func (c *Ctrl) SendDueDigests(ctx context.Context, now time.Time) error {
+	users, err := c.Store.List(ctx, store.ListRequest{})
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	for _, u := range users {
+		if !u.Authorized || !u.Subscribed {
+			continue
+		}
+
+		// users, who switched back to immediate mode, receive leftovers right away
+		if u.Schedule.Mode != store.ScheduleImmediate && u.Schedule.Next(u.LastDigestAt, u.Location()).After(now) {
+			continue
+		}
+
+		if err = c.sendDigest(ctx, u, now); err != nil {
+			c.Logger.WarnCtx(ctx, "failed to send digest", slog.String("chat_id", u.ChatID), slog.Any("err", err))
+		}
+	}
+
+	return nil
+}
+
+func (c *Ctrl) sendDigest(ctx context.Context, u store.User, now time.Time) error {
+	// checked without a write transaction, as most of users have nothing pending
+	has, err := c.Store.HasPending(ctx, u.ChatID)
+	if err != nil {
+		return fmt.Errorf("check pending articles: %w", err)
+	}
+
+	if u.Schedule.Mode == store.ScheduleImmediate && !has {
+		return nil
+	}
+
+	var articles []store.Article
+	if has {
+		if articles, err = c.Store.PopPending(ctx, u.ChatID); err != nil {
+			return fmt.Errorf("pop pending articles: %w", err)
+		}
+	}
+
+	if len(articles) > 0 {
+		sb := &strings.Builder{}
+		if err = digestMessageTmpl.Execute(sb, articles); err != nil {
+			return fmt.Errorf("execute digest message template: %w", err)
+		}
+
+		_, err = c.API.SendMessage(ctx, botx.Response{
+			ChatID:    u.ChatID,
+			Text:      strings.TrimSpace(sb.String()),
+			ParseMode: parseMode,
+		})
+		if err != nil {
+			// put articles back to not lose them, they'll be sent with the next attempt
+			for _, a := range articles {
+				if perr := c.Store.AddPending(ctx, u.ChatID, a); perr != nil {
+					return fmt.Errorf("return articles to pending after failed send (%v): %w", err, perr)
+				}
+			}
+			return fmt.Errorf("send digest: %w", err)
+		}
+
+		for _, a := range articles {
+			if err = recordHistory(ctx, c.Store, u.ChatID, a, store.SourceFeed); err != nil {
+				c.Logger.WarnCtx(ctx, "failed to record history", slog.Any("err", err))
+			}
+		}
+	}
+
+	if u.Schedule.Mode == store.ScheduleImmediate {
+		return nil
+	}
+
+	// the user may have changed the settings, while the digest was sent
+	if err = c.Store.SetLastDigestAt(ctx, u.ChatID, now); err != nil {
+		return fmt.Errorf("update last digest time: %w", err)
+	}
+
+	return nil
+}
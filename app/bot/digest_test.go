@@ -0,0 +1,311 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    store.Schedule
+		wantErr string
+	}{
+		{name: "now", text: "now", want: store.Schedule{Mode: store.ScheduleImmediate}},
+		{name: "immediate", text: "immediate", want: store.Schedule{Mode: store.ScheduleImmediate}},
+		{name: "daily", text: "daily 09:30", want: store.Schedule{Mode: store.ScheduleDaily, At: 9*60 + 30}},
+		{name: "daily at midnight", text: "daily 00:00", want: store.Schedule{Mode: store.ScheduleDaily}},
+		{name: "daily, last minute", text: "daily 23:59", want: store.Schedule{Mode: store.ScheduleDaily, At: 23*60 + 59}},
+		{name: "daily without time", text: "daily", wantErr: "time of day is required"},
+		{name: "daily, hour out of range", text: "daily 24:00", wantErr: "time must be in HH:MM format"},
+		{name: "daily, minute out of range", text: "daily 10:60", wantErr: "time must be in HH:MM format"},
+		{name: "daily, no colon", text: "daily 0930", wantErr: "time must be in HH:MM format"},
+		{name: "daily, not a number", text: "daily 9am:00", wantErr: "time must be in HH:MM format"},
+		{name: "weekly, short day", text: "weekly mon 09:00",
+			want: store.Schedule{Mode: store.ScheduleWeekly, At: 9 * 60, Weekday: time.Monday}},
+		{name: "weekly, full day in any case", text: "weekly Sunday 18:15",
+			want: store.Schedule{Mode: store.ScheduleWeekly, At: 18*60 + 15, Weekday: time.Sunday}},
+		{name: "weekly without time", text: "weekly mon", wantErr: "day of week and time of day are required"},
+		{name: "weekly, unknown day", text: "weekly someday 09:00", wantErr: `unknown day of week "someday"`},
+		{name: "weekly, bad time", text: "weekly fri 9", wantErr: "time must be in HH:MM format"},
+		{name: "unknown", text: "hourly", wantErr: `unknown schedule "hourly"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseSchedule(strings.Fields(tt.text))
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sched)
+		})
+	}
+}
+
+func TestCtrl_schedule(t *testing.T) {
+	c, _, s := newTestCtrl(t)
+	rtr := c.Routes()
+	ctx := context.Background()
+	authorize(t, rtr, "1")
+
+	chat := botx.Chat{ID: "1"}
+
+	resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/schedule"})
+	require.Len(t, resps, 1)
+	assert.Equal(t, "You receive news immediately.\n\n"+scheduleUsage, resps[0].Text)
+
+	resps = handle(t, rtr, botx.Request{Chat: chat, Text: "/schedule daily 25:00"})
+	require.Len(t, resps, 1)
+	assert.Equal(t, "time must be in HH:MM format.\n\n"+scheduleUsage, resps[0].Text)
+
+	before := time.Now()
+	resps = handle(t, rtr, botx.Request{Chat: chat, Text: "/schedule weekly fri 18:00"})
+	require.Len(t, resps, 1)
+	assert.Equal(t, "You will receive news weekly on Friday at 18:00 (UTC).", resps[0].Text)
+
+	u, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, store.Schedule{Mode: store.ScheduleWeekly, At: 18 * 60, Weekday: time.Friday}, u.Schedule)
+	// articles, received before the schedule is set, are not sent in the digest again
+	assert.False(t, u.LastDigestAt.Before(before), "last digest time is not updated")
+}
+
+func TestCtrl_timezone(t *testing.T) {
+	c, _, s := newTestCtrl(t)
+	rtr := c.Routes()
+	ctx := context.Background()
+	authorize(t, rtr, "1")
+
+	chat := botx.Chat{ID: "1"}
+
+	resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/timezone"})
+	require.Len(t, resps, 1)
+	assert.Contains(t, resps[0].Text, "Your time zone is UTC.")
+
+	resps = handle(t, rtr, botx.Request{Chat: chat, Text: "/timezone Mars/Olympus"})
+	require.Len(t, resps, 1)
+	assert.Contains(t, resps[0].Text, "Unknown time zone")
+
+	u, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, u.Timezone)
+
+	loadLocation(t, "Asia/Almaty")
+	resps = handle(t, rtr, botx.Request{Chat: chat, Text: "/timezone Asia/Almaty"})
+	require.Len(t, resps, 1)
+	assert.Equal(t, "Your time zone is set to Asia/Almaty.", resps[0].Text)
+
+	u, err = s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Almaty", u.Timezone)
+}
+
+func TestCtrl_SendDueDigests(t *testing.T) {
+	berlin := loadLocation(t, "Europe/Berlin")
+	almaty := loadLocation(t, "Asia/Almaty")
+
+	type check struct {
+		at   time.Time
+		sent bool
+	}
+
+	tests := []struct {
+		name         string
+		timezone     string
+		schedule     store.Schedule
+		lastDigestAt time.Time
+		checks       []check
+	}{
+		{
+			name:         "daily, across the switch to summer time",
+			timezone:     "Europe/Berlin",
+			schedule:     store.Schedule{Mode: store.ScheduleDaily, At: 9 * 60},
+			lastDigestAt: time.Date(2023, 3, 25, 9, 0, 0, 0, berlin), // 08:00 UTC
+			checks: []check{
+				{at: time.Date(2023, 3, 26, 6, 59, 0, 0, time.UTC), sent: false},
+				// 09:00 in Berlin is 07:00 UTC after the switch, not 08:00
+				{at: time.Date(2023, 3, 26, 7, 0, 0, 0, time.UTC), sent: true},
+				// the scheduler checks the time every minute, the digest is sent once
+				{at: time.Date(2023, 3, 26, 7, 0, 30, 0, time.UTC), sent: false},
+				{at: time.Date(2023, 3, 26, 8, 0, 0, 0, time.UTC), sent: false},
+				{at: time.Date(2023, 3, 27, 7, 0, 0, 0, time.UTC), sent: true},
+			},
+		},
+		{
+			name:         "daily, across the switch to winter time",
+			timezone:     "Europe/Berlin",
+			schedule:     store.Schedule{Mode: store.ScheduleDaily, At: 9 * 60},
+			lastDigestAt: time.Date(2023, 10, 28, 9, 0, 0, 0, berlin), // 07:00 UTC
+			checks: []check{
+				{at: time.Date(2023, 10, 29, 7, 0, 0, 0, time.UTC), sent: false},
+				{at: time.Date(2023, 10, 29, 8, 0, 0, 0, time.UTC), sent: true},
+			},
+		},
+		{
+			name:         "weekly, the day in the time zone differs from UTC",
+			timezone:     "Asia/Almaty",
+			schedule:     store.Schedule{Mode: store.ScheduleWeekly, At: 30, Weekday: time.Monday},
+			lastDigestAt: time.Date(2023, 3, 13, 0, 30, 0, 0, almaty),
+			checks: []check{
+				{at: time.Date(2023, 3, 19, 18, 29, 0, 0, time.UTC), sent: false},
+				// Monday, 00:30 in Almaty is Sunday, 18:30 UTC
+				{at: time.Date(2023, 3, 19, 18, 30, 0, 0, time.UTC), sent: true},
+				{at: time.Date(2023, 3, 20, 18, 30, 0, 0, time.UTC), sent: false},
+			},
+		},
+		{
+			name:     "missed digest is sent after downtime",
+			timezone: "",
+			schedule: store.Schedule{Mode: store.ScheduleDaily, At: 12 * 60},
+			// the bot was down for several days
+			lastDigestAt: time.Date(2023, 3, 20, 12, 0, 0, 0, time.UTC),
+			checks: []check{
+				{at: time.Date(2023, 3, 24, 7, 0, 0, 0, time.UTC), sent: true},
+				{at: time.Date(2023, 3, 24, 7, 1, 0, 0, time.UTC), sent: false},
+				{at: time.Date(2023, 3, 24, 12, 0, 0, 0, time.UTC), sent: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, api, s := newTestCtrl(t)
+			ctx := context.Background()
+
+			require.NoError(t, s.Put(ctx, store.User{
+				ChatID:       "1",
+				Authorized:   true,
+				Subscribed:   true,
+				Timezone:     tt.timezone,
+				Schedule:     tt.schedule,
+				LastDigestAt: tt.lastDigestAt,
+			}))
+
+			for i, chk := range tt.checks {
+				a := store.Article{URL: fmt.Sprintf("https://example.com/%d", i), Title: "title", BulletPoints: "points"}
+				require.NoError(t, s.AddPending(ctx, "1", a))
+
+				require.NoError(t, c.SendDueDigests(ctx, chk.at))
+
+				msgs := api.sent()
+				if !chk.sent {
+					assert.Empty(t, msgs, "digest is sent at %s", chk.at)
+					continue
+				}
+
+				require.Len(t, msgs, 1, "digest is not sent at %s", chk.at)
+				assert.Contains(t, msgs[0].Text, "Your news digest")
+				assert.Contains(t, msgs[0].Text, a.URL)
+
+				u, err := s.Get(ctx, "1")
+				require.NoError(t, err)
+				assert.True(t, chk.at.Equal(u.LastDigestAt), "last digest at %s, expected %s", u.LastDigestAt, chk.at)
+			}
+		})
+	}
+}
+
+func TestCtrl_SendDueDigests_SendFailure(t *testing.T) {
+	c, api, s := newTestCtrl(t)
+	ctx := context.Background()
+
+	lastDigestAt := time.Date(2023, 3, 20, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, s.Put(ctx, store.User{
+		ChatID:       "1",
+		Authorized:   true,
+		Subscribed:   true,
+		Schedule:     store.Schedule{Mode: store.ScheduleDaily, At: 9 * 60},
+		LastDigestAt: lastDigestAt,
+	}))
+
+	// articles from feeds are saved before they are queued
+	a := store.Article{URL: "https://example.com/1", Title: "title", BulletPoints: "points"}
+	require.NoError(t, s.PutArticle(ctx, a))
+	require.NoError(t, s.AddPending(ctx, "1", a))
+
+	now := time.Date(2023, 3, 21, 9, 0, 0, 0, time.UTC)
+
+	api.failSends(errors.New("network is down"))
+	require.NoError(t, c.SendDueDigests(ctx, now))
+
+	// articles are returned to the queue and the digest is still due
+	has, err := s.HasPending(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	u, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, lastDigestAt.Equal(u.LastDigestAt))
+
+	entries, err := s.ListHistory(ctx, store.HistoryRequest{ChatID: "1"})
+	require.NoError(t, err)
+	assert.Empty(t, entries, "failed digest is recorded to the history")
+
+	api.failSends(nil)
+	require.NoError(t, c.SendDueDigests(ctx, now.Add(time.Minute)))
+
+	msgs := api.sent()
+	require.Len(t, msgs, 1)
+	assert.Contains(t, msgs[0].Text, a.URL)
+
+	has, err = s.HasPending(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	entries, err = s.ListHistory(ctx, store.HistoryRequest{ChatID: "1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, a.URL, entries[0].URL)
+	assert.Equal(t, store.SourceFeed, entries[0].Source)
+}
+
+func TestCtrl_SendDueDigests_Leftovers(t *testing.T) {
+	c, api, s := newTestCtrl(t)
+	ctx := context.Background()
+
+	// the user has switched back to immediate delivery with articles in the queue
+	require.NoError(t, s.Put(ctx, store.User{ChatID: "1", Authorized: true, Subscribed: true}))
+	require.NoError(t, s.AddPending(ctx, "1", store.Article{URL: "https://example.com/1", Title: "title"}))
+
+	// unsubscribed users receive nothing
+	require.NoError(t, s.Put(ctx, store.User{ChatID: "2", Authorized: true}))
+	require.NoError(t, s.AddPending(ctx, "2", store.Article{URL: "https://example.com/2", Title: "title"}))
+
+	now := time.Date(2023, 3, 21, 15, 4, 0, 0, time.UTC)
+	require.NoError(t, c.SendDueDigests(ctx, now))
+
+	msgs := api.sent()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "1", msgs[0].ChatID)
+	assert.Contains(t, msgs[0].Text, "https://example.com/1")
+
+	// users without schedule don't have the digest time
+	u, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, u.LastDigestAt.IsZero())
+
+	// nothing is left to send
+	require.NoError(t, c.SendDueDigests(ctx, now.Add(time.Minute)))
+	assert.Empty(t, api.sent())
+}
+
+// loadLocation loads the time zone, the test is skipped if there is no tzdata.
+func loadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	return loc
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Semior001/newsfeed/app/feed"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/samber/lo"
+	"golang.org/x/exp/slog"
+)
+
+// DeliverFeedItem summarizes the new feed item and sends it to every user
+// subscribed to the feed, unless the user has stopped news updates.
+// For users with scheduled delivery the summary is queued for the next digest.
+func (c *Ctrl) DeliverFeedItem(ctx context.Context, f store.Feed, item feed.Item) error {
+	users, err := c.Store.ListSubscribers(ctx, f.URL)
+	if err != nil {
+		return fmt.Errorf("list subscribers: %w", err)
+	}
+
+	users = lo.Filter(users, func(u store.User, _ int) bool { return u.Authorized && u.Subscribed })
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	// users with the same preferences receive the same summary
+	var errs []error
+	for _, group := range lo.GroupBy(users, func(u store.User) string { return u.Preferences.Key() }) {
+		if err = c.deliverFeedItem(ctx, f, item, group); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Ctrl) deliverFeedItem(ctx context.Context, f store.Feed, item feed.Item, users []store.User) error {
+	article, err := getArticle(ctx, c.Store, c.Service, item.URL, users[0].Preferences)
+	if err != nil {
+		return fmt.Errorf("get article %s from feed %s: %w", item.URL, f.URL, err)
+	}
+
+	text, err := renderArticle(article)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if u.Schedule.Mode != store.ScheduleImmediate {
+			if err = c.Store.AddPending(ctx, u.ChatID, article); err != nil {
+				c.Logger.WarnCtx(ctx, "failed to queue feed item for digest",
+					slog.String("chat_id", u.ChatID), slog.String("url", item.URL), slog.Any("err", err))
+			}
+			continue
+		}
+
+		_, err = c.API.SendMessage(ctx, botx.Response{
+			ChatID:    u.ChatID,
+			Text:      text,
+			ParseMode: parseMode,
+			Buttons:   articleButtons(article, &f),
+			Card:      articleCard(article),
+		})
+		if err != nil {
+			c.Logger.WarnCtx(ctx, "failed to deliver feed item",
+				slog.String("chat_id", u.ChatID), slog.String("url", item.URL), slog.Any("err", err))
+			continue
+		}
+
+		if err = recordHistory(ctx, c.Store, u.ChatID, article, store.SourceFeed); err != nil {
+			c.Logger.WarnCtx(ctx, "failed to record history", slog.Any("err", err))
+		}
+	}
+
+	return nil
+}
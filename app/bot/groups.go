@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+const autoSummarizeUsage = "Usage: /autosummarize on|off"
+
+// autoSummarize turns on or off summarizing every link posted to the group.
+func (c *Ctrl) autoSummarize(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	if !req.Chat.IsGroup() {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Links are summarized automatically only in groups, just send me a link here.",
+		}}, nil
+	}
+
+	state := map[bool]string{true: "on", false: "off"}
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Summarizing of posted links is %s.\n\n%s", state[u.AutoSummarize], autoSummarizeUsage),
+		}}, nil
+	}
+
+	switch strings.ToLower(tokens[1]) {
+	case "on":
+		u.AutoSummarize = true
+	case "off":
+		u.AutoSummarize = false
+	default:
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   autoSummarizeUsage,
+		}}, nil
+	}
+
+	if err := c.Store.Put(ctx, u); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	text := "I will summarize every link posted to this group."
+	if !u.AutoSummarize {
+		text = "I will summarize only links sent to me with a mention."
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   text,
+	}}, nil
+}
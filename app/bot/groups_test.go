@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCtrl_autoSummarize(t *testing.T) {
+	c, api, s := newTestCtrl(t)
+	var articleURL string
+	c.Service, articleURL = newTestService(t)
+	c.Usernames = []string{"newsfeedbot"}
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	group := botx.Chat{ID: "-1", Type: botx.ChatGroup, Title: "group"}
+	require.NoError(t, s.Put(ctx, store.User{ChatID: group.ID, Title: group.Title, Authorized: true, Subscribed: true}))
+
+	t.Run("private chat", func(t *testing.T) {
+		authorize(t, rtr, "1")
+		resps := handle(t, rtr, botx.Request{Chat: botx.Chat{ID: "1"}, Text: "/autosummarize on"})
+		require.Len(t, resps, 1)
+		assert.Contains(t, resps[0].Text, "only in groups")
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: group, Text: "/autosummarize@newsfeedbot"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Summarizing of posted links is off.\n\n"+autoSummarizeUsage, resps[0].Text)
+
+		resps = handle(t, rtr, botx.Request{Chat: group, MessageID: "10", Text: "look at " + articleURL})
+		assert.Empty(t, resps)
+		assert.Empty(t, api.sent())
+	})
+
+	t.Run("mention is summarized", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: group, MessageID: "11", Text: "@newsfeedbot " + articleURL})
+		assert.Empty(t, resps)
+
+		msgs := api.sent()
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "11", msgs[0].ReplyToMessageID, "placeholder is not a reply in the group")
+		assert.Contains(t, api.lastEdit().Text, "The first sentence of the article is about cats")
+	})
+
+	t.Run("on", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: group, Text: "/autosummarize ON"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "I will summarize every link posted to this group.", resps[0].Text)
+
+		u, err := s.Get(ctx, group.ID)
+		require.NoError(t, err)
+		assert.True(t, u.AutoSummarize)
+
+		resps = handle(t, rtr, botx.Request{Chat: group, MessageID: "12", Text: "look at " + articleURL})
+		assert.Empty(t, resps)
+
+		msgs := api.sent()
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "12", msgs[0].ReplyToMessageID)
+		assert.Contains(t, api.lastEdit().Text, "The first sentence of the article is about cats")
+
+		// messages without links are ignored
+		resps = handle(t, rtr, botx.Request{Chat: group, Text: "hello"})
+		assert.Empty(t, resps)
+		assert.Empty(t, api.sent())
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: group, Text: "/autosummarize maybe"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, autoSummarizeUsage, resps[0].Text)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: group, Text: "/autosummarize off"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "I will summarize only links sent to me with a mention.", resps[0].Text)
+
+		resps = handle(t, rtr, botx.Request{Chat: group, MessageID: "13", Text: articleURL})
+		assert.Empty(t, resps)
+		assert.Empty(t, api.sent())
+	})
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+const (
+	defaultHistoryLimit = 5
+	maxHistoryLimit     = 20
+)
+
+type history struct {
+	Store store.Interface
+}
+
+func (c *history) history(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	limit := defaultHistoryLimit
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) > 1 {
+		n, err := strconv.Atoi(tokens[1])
+		if err != nil || n < 1 || len(tokens) > 2 {
+			return []botx.Response{{
+				ChatID: req.Chat.ID,
+				Text:   fmt.Sprintf("Usage: /history [number of articles, up to %d]", maxHistoryLimit),
+			}}, nil
+		}
+		limit = n
+	}
+
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	entries, err := c.Store.ListHistory(ctx, store.HistoryRequest{ChatID: req.Chat.ID, Limit: limit})
+	if err != nil {
+		return nil, fmt.Errorf("list history: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "You haven't received any articles yet.",
+		}}, nil
+	}
+
+	return c.render(req, fmt.Sprintf("Your last %d article(s):", len(entries)), entries)
+}
+
+func (c *history) find(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	_, query, _ := strings.Cut(req.Text, " ")
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Usage: /find <words to search in the articles you've received>",
+		}}, nil
+	}
+
+	entries, err := c.Store.ListHistory(ctx, store.HistoryRequest{
+		ChatID: req.Chat.ID,
+		Limit:  defaultHistoryLimit,
+		Query:  query,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list history: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Nothing found.",
+		}}, nil
+	}
+
+	return c.render(req, fmt.Sprintf("Found %d article(s), newest first:", len(entries)), entries)
+}
+
+func (c *history) render(req botx.Request, header string, entries []store.HistoryEntry) ([]botx.Response, error) {
+	resps := []botx.Response{{ChatID: req.Chat.ID, Text: header}}
+	for _, e := range entries {
+		text, err := renderArticle(e.Article)
+		if err != nil {
+			return nil, err
+		}
+
+		resps = append(resps, botx.Response{
+			ChatID:    req.Chat.ID,
+			Text:      fmt.Sprintf("_received on %s_\n%s", escapeMarkdown(e.ReceivedAt.Format("2006-01-02 15:04")), text),
+			ParseMode: parseMode,
+		})
+	}
+
+	return resps, nil
+}
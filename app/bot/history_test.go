@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	c, _, s := newTestCtrl(t)
+	rtr := c.Routes()
+	ctx := context.Background()
+	authorize(t, rtr, "1")
+
+	chat := botx.Chat{ID: "1"}
+
+	for i, title := range []string{"Cats are cute", "Dogs are loyal", "Birds can fly"} {
+		a := store.Article{
+			URL:          fmt.Sprintf("https://example.com/%d", i),
+			Title:        title,
+			BulletPoints: "- " + title,
+		}
+		require.NoError(t, s.PutArticle(ctx, a))
+		require.NoError(t, recordHistory(ctx, s, "1", a, store.SourceRequest))
+	}
+
+	t.Run("last articles", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/history 2"})
+		require.Len(t, resps, 3)
+		assert.Equal(t, "Your last 2 article(s):", resps[0].Text)
+		assert.Contains(t, resps[1].Text, "Birds can fly")
+		assert.Contains(t, resps[2].Text, "Dogs are loyal")
+		assert.Equal(t, parseMode, resps[1].ParseMode)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/history many"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Usage: /history [number of articles, up to 20]", resps[0].Text)
+	})
+
+	t.Run("find", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/find CATS"})
+		require.Len(t, resps, 2)
+		assert.Equal(t, "Found 1 article(s), newest first:", resps[0].Text)
+		assert.Contains(t, resps[1].Text, "Cats are cute")
+	})
+
+	t.Run("nothing found", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/find fish"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "Nothing found.", resps[0].Text)
+	})
+
+	t.Run("find without query", func(t *testing.T) {
+		resps := handle(t, rtr, botx.Request{Chat: chat, Text: "/find"})
+		require.Len(t, resps, 1)
+		assert.Contains(t, resps[0].Text, "Usage: /find")
+	})
+
+	t.Run("history of another user", func(t *testing.T) {
+		authorize(t, rtr, "2")
+		resps := handle(t, rtr, botx.Request{Chat: botx.Chat{ID: "2"}, Text: "/history"})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "You haven't received any articles yet.", resps[0].Text)
+	})
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/revisor"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"golang.org/x/exp/slog"
+	"golang.org/x/sync/singleflight"
+)
+
+// inlineWait is the time to wait for the summary before answering
+// with the "still processing" result, telegram drops answers to inline
+// queries, that take longer than several seconds.
+const inlineWait = 5 * time.Second
+
+// inline answers inline queries with summaries of articles.
+type inline struct {
+	Logger  *slog.Logger
+	Store   store.Interface
+	Service *revisor.Service
+	// Timeout limits the summarization, that goes on in background
+	// after the query is answered, so that the next query gets the result.
+	Timeout time.Duration
+
+	// the user sends a query on every typed character,
+	// so the same article is requested many times in a row
+	inflight singleflight.Group
+}
+
+func (c *inline) query(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	answer := botx.Response{InlineQueryID: req.InlineQueryID}
+
+	articleURL := strings.TrimSpace(req.Text)
+	if _, err := url.ParseRequestURI(articleURL); err != nil {
+		return []botx.Response{answer}, nil
+	}
+
+	key := u.Preferences.Key() + ":" + store.NormalizeURL(articleURL)
+	ch := c.inflight.DoChan(key, func() (any, error) {
+		// the query context is done as soon as the query is answered
+		ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+		defer cancel()
+		return getArticle(ctx, c.Store, c.Service, articleURL, u.Preferences)
+	})
+
+	timer := time.NewTimer(inlineWait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		answer.InlineResults = []botx.InlineResult{{
+			ID:          "pending:" + store.ShortID(articleURL),
+			Title:       "Still summarizing the article...",
+			Description: "Type the link again in a few seconds to get the summary.",
+			Text:        articleURL,
+		}}
+		return []botx.Response{answer}, nil
+	case res := <-ch:
+		if res.Err != nil {
+			answer.InlineResults = []botx.InlineResult{c.failedResult(ctx, articleURL, res.Err)}
+			return []botx.Response{answer}, nil
+		}
+
+		article := res.Val.(store.Article)
+
+		text, err := renderArticle(article)
+		if err != nil {
+			return nil, err
+		}
+
+		answer.InlineResults = []botx.InlineResult{{
+			ID:          store.ShortID(article.URL),
+			Title:       article.Title,
+			Description: article.Excerpt,
+			Text:        text,
+			ParseMode:   parseMode,
+		}}
+		return []botx.Response{answer}, nil
+	}
+}
+
+// failedResult returns the result for the article, that couldn't be summarized.
+// The error is logged and not returned, as the user can't see error messages
+// in the inline mode.
+func (c *inline) failedResult(ctx context.Context, articleURL string, err error) botx.InlineResult {
+	if errors.Is(err, revisor.ErrTooManyTokens) {
+		return botx.InlineResult{
+			ID:          "failed:" + store.ShortID(articleURL),
+			Title:       "The article is too long to summarize",
+			Description: articleURL,
+			Text:        articleURL,
+		}
+	}
+
+	c.Logger.WarnCtx(ctx, "failed to summarize article for inline query",
+		slog.String("url", articleURL), slog.Any("err", err))
+
+	return botx.InlineResult{
+		ID:          "failed:" + store.ShortID(articleURL),
+		Title:       "Couldn't summarize the article",
+		Description: "Please, try again later.",
+		Text:        articleURL,
+	}
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInline_query(t *testing.T) {
+	c, _, s := newTestCtrl(t)
+	var articleURL string
+	c.Service, articleURL = newTestService(t)
+	rtr := c.Routes()
+	ctx := context.Background()
+
+	query := func(chatID, text string) botx.Response {
+		resps := handle(t, rtr, botx.Request{
+			Kind:          botx.RequestInline,
+			Chat:          botx.Chat{ID: chatID},
+			InlineQueryID: "q" + chatID,
+			Text:          text,
+		})
+		require.Len(t, resps, 1)
+		assert.Equal(t, "q"+chatID, resps[0].InlineQueryID)
+		return resps[0]
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		resp := query("2", articleURL)
+		assert.Equal(t, "Start the bot to summarize articles", resp.Text)
+		assert.Empty(t, resp.InlineResults)
+	})
+
+	authorize(t, rtr, "1")
+
+	t.Run("not a link", func(t *testing.T) {
+		resp := query("1", "some text")
+		assert.Empty(t, resp.InlineResults)
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		resp := query("1", " "+articleURL+" ")
+		require.Len(t, resp.InlineResults, 1)
+
+		res := resp.InlineResults[0]
+		assert.Equal(t, store.ShortID(articleURL), res.ID)
+		assert.Equal(t, "Test article", res.Title)
+		assert.Contains(t, res.Text, "The first sentence of the article is about cats")
+		assert.Equal(t, parseMode, res.ParseMode)
+
+		// the summary is saved for the next queries
+		a, err := s.GetArticle(ctx, articleURL)
+		require.NoError(t, err)
+		assert.Equal(t, "Test article", a.Title)
+	})
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+// maxLinksPerMessage limits the number of links summarized from a single message.
+const maxLinksPerMessage = 5
+
+// linkRe matches links in the text, the trailing punctuation is trimmed later.
+var linkRe = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'«»]+`)
+
+// messageLinks returns unique links of the message: links from the formatting
+// of the message first, then links found in the text, no more than maxLinksPerMessage.
+func messageLinks(req botx.Request) []string {
+	candidates := append([]string{}, req.URLs...)
+	candidates = append(candidates, linkRe.FindAllString(req.Text, -1)...)
+
+	var links []string
+	seen := map[string]bool{}
+	for _, link := range candidates {
+		link, ok := parseLink(link)
+		if !ok || seen[store.NormalizeURL(link)] {
+			continue
+		}
+
+		seen[store.NormalizeURL(link)] = true
+		links = append(links, link)
+
+		if len(links) == maxLinksPerMessage {
+			break
+		}
+	}
+
+	return links
+}
+
+// parseLink validates the link and adds the scheme, if it is missing,
+// e.g. telegram recognizes "example.com/article" as a link.
+func parseLink(link string) (string, bool) {
+	link = strings.TrimRight(link, ".,;:!?")
+	// closing parenthesis is a part of the link only if it is balanced, like in wikipedia links
+	for strings.HasSuffix(link, ")") && strings.Count(link, "(") < strings.Count(link, ")") {
+		link = strings.TrimSuffix(link, ")")
+	}
+
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	// links with credentials are not articles, and "mailto:user@example.com"
+	// with the added scheme is parsed as the host with credentials
+	u, err := url.ParseRequestURI(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", false
+	}
+
+	return link, true
+}
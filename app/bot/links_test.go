@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		req  botx.Request
+		want []string
+	}{
+		{
+			name: "bare link",
+			req:  botx.Request{Text: "https://example.com/article"},
+			want: []string{"https://example.com/article"},
+		},
+		{
+			name: "links in the text with punctuation",
+			req:  botx.Request{Text: "Read https://example.com/a, and http://example.org/b!"},
+			want: []string{"https://example.com/a", "http://example.org/b"},
+		},
+		{
+			name: "balanced parentheses are kept",
+			req:  botx.Request{Text: "(see https://en.wikipedia.org/wiki/Go_(programming_language))"},
+			want: []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"},
+		},
+		{
+			name: "links from formatting go first, without duplicates",
+			req: botx.Request{
+				Text: "https://example.com/a and the article",
+				URLs: []string{"example.com/b", "https://example.com/a/"},
+			},
+			want: []string{"https://example.com/b", "https://example.com/a/"},
+		},
+		{
+			name: "not http links are skipped",
+			req:  botx.Request{Text: "no links here", URLs: []string{"mailto:user@example.com", "ftp://example.com/file"}},
+		},
+		{
+			name: "number of links is limited",
+			req: botx.Request{Text: "https://example.com/1 https://example.com/2 https://example.com/3 " +
+				"https://example.com/4 https://example.com/5 https://example.com/6"},
+			want: []string{"https://example.com/1", "https://example.com/2", "https://example.com/3",
+				"https://example.com/4", "https://example.com/5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, messageLinks(tt.req))
+		})
+	}
+}
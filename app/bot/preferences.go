@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+var styleDescriptions = map[store.Style]string{
+	store.StyleBullets:   "3-10 short bullet points",
+	store.StyleParagraph: "a single paragraph",
+	store.StyleTLDR:      "a single TL;DR line",
+	store.StyleDetailed:  "up to 15 detailed bullet points",
+}
+
+func (c *Ctrl) lang(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Summaries are written in %s.\n\n%s", u.LanguageName(), langUsage()),
+		}}, nil
+	}
+
+	code, ok := parseLanguage(tokens[1])
+	if !ok {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Unknown language.\n\n%s", langUsage()),
+		}}, nil
+	}
+
+	u.Language = code
+	if err := c.Store.Put(ctx, u); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("Summaries will be written in %s.", u.LanguageName()),
+	}}, nil
+}
+
+func (c *Ctrl) style(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	u, ok := userFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no user in context")
+	}
+
+	current := u.Style
+	if current == "" {
+		current = store.StyleBullets
+	}
+
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Summaries are written as %s.\n\n%s", styleDescriptions[current], styleUsage()),
+		}}, nil
+	}
+
+	style := store.Style(strings.ToLower(tokens[1]))
+	if _, ok := styleDescriptions[style]; !ok {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   fmt.Sprintf("Unknown style %q.\n\n%s", tokens[1], styleUsage()),
+		}}, nil
+	}
+
+	u.Style = style
+	if err := c.Store.Put(ctx, u); err != nil {
+		return nil, fmt.Errorf("update user: %w", err)
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("Summaries will be written as %s.", styleDescriptions[style]),
+	}}, nil
+}
+
+// parseLanguage returns the code of the language by its code or name, case-insensitive.
+func parseLanguage(s string) (string, bool) {
+	s = strings.ToLower(s)
+	for code, name := range store.Languages {
+		if s == code || s == strings.ToLower(name) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+func langUsage() string {
+	codes := make([]string, 0, len(store.Languages))
+	for code := range store.Languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	sb := &strings.Builder{}
+	_, _ = sb.WriteString("Usage: /lang <code>, e.g. /lang en\nAvailable languages:")
+	for _, code := range codes {
+		_, _ = fmt.Fprintf(sb, "\n%s - %s", code, store.Languages[code])
+	}
+	return sb.String()
+}
+
+func styleUsage() string {
+	sb := &strings.Builder{}
+	_, _ = sb.WriteString("Usage: /style <name>, e.g. /style tldr\nAvailable styles:")
+	for _, style := range store.Styles {
+		_, _ = fmt.Fprintf(sb, "\n%s - %s", style, styleDescriptions[style])
+	}
+	return sb.String()
+}
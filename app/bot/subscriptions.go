@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Semior001/newsfeed/app/feed"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/samber/lo"
+)
+
+type subscriptions struct {
+	Store   store.Interface
+	Fetcher *feed.Fetcher
+}
+
+func (c *subscriptions) subscribe(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Usage: /subscribe <feed url>",
+		}}, nil
+	}
+
+	u, err := url.ParseRequestURI(tokens[1])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Please, provide a valid link to RSS, Atom or JSON feed.",
+		}}, nil
+	}
+
+	f, err := c.Store.GetFeed(ctx, u.String())
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		doc, err := c.Fetcher.Fetch(ctx, u.String())
+		if err != nil {
+			return []botx.Response{{
+				ChatID: req.Chat.ID,
+				Text:   fmt.Sprintf("I couldn't read a feed by this link: %v", err),
+			}}, nil
+		}
+
+		f = store.Feed{URL: u.String(), Title: doc.Title}
+		if err = c.Store.PutFeed(ctx, f); err != nil {
+			return nil, fmt.Errorf("put feed: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("get feed: %w", err)
+	}
+
+	if err = c.Store.Subscribe(ctx, req.Chat.ID, f.URL); err != nil {
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("You have been subscribed to %s.", feedName(f)),
+	}}, nil
+}
+
+func (c *subscriptions) unsubscribe(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	tokens := strings.Fields(req.Text)
+	if len(tokens) != 2 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "Usage: /unsubscribe <feed url or its number in /feeds>",
+		}}, nil
+	}
+
+	feeds, err := c.Store.ListSubscriptions(ctx, req.Chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+
+	var f *store.Feed
+	if idx, err := strconv.Atoi(tokens[1]); err == nil {
+		if idx >= 1 && idx <= len(feeds) {
+			f = &feeds[idx-1]
+		}
+	} else {
+		for i := range feeds {
+			if feeds[i].URL == tokens[1] {
+				f = &feeds[i]
+				break
+			}
+		}
+	}
+
+	if f == nil {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "You are not subscribed to this feed, see /feeds for the list of your subscriptions.",
+		}}, nil
+	}
+
+	if err = c.unsubscribeFeed(ctx, req.Chat.ID, f.URL); err != nil {
+		return nil, err
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   fmt.Sprintf("You have been unsubscribed from %s.", feedName(*f)),
+	}}, nil
+}
+
+// unsubscribeCallback unsubscribes the user from the feed of the delivered article.
+func (c *subscriptions) unsubscribeCallback(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	feedID := strings.TrimPrefix(req.Text, unsubscribeCallbackPrefix)
+
+	feeds, err := c.Store.ListSubscriptions(ctx, req.Chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+
+	f, ok := lo.Find(feeds, func(f store.Feed) bool { return store.ShortID(f.URL) == feedID })
+	if !ok {
+		return []botx.Response{{
+			CallbackID: req.CallbackID,
+			Text:       "You are not subscribed to this feed.",
+		}}, nil
+	}
+
+	if err = c.unsubscribeFeed(ctx, req.Chat.ID, f.URL); err != nil {
+		return nil, err
+	}
+
+	return []botx.Response{{
+		CallbackID: req.CallbackID,
+		Text:       fmt.Sprintf("You have been unsubscribed from %s.", feedName(f)),
+	}}, nil
+}
+
+func (c *subscriptions) unsubscribeFeed(ctx context.Context, chatID, feedURL string) error {
+	if err := c.Store.Unsubscribe(ctx, chatID, feedURL); err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+
+	// nobody reads it anymore, no need to poll it
+	subscribers, err := c.Store.ListSubscribers(ctx, feedURL)
+	if err != nil {
+		return fmt.Errorf("list subscribers: %w", err)
+	}
+
+	if len(subscribers) == 0 {
+		if err = c.Store.DeleteFeed(ctx, feedURL); err != nil {
+			return fmt.Errorf("delete feed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *subscriptions) list(ctx context.Context, req botx.Request) ([]botx.Response, error) {
+	feeds, err := c.Store.ListSubscriptions(ctx, req.Chat.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+
+	if len(feeds) == 0 {
+		return []botx.Response{{
+			ChatID: req.Chat.ID,
+			Text:   "You have no subscriptions, use /subscribe <feed url> to add one.",
+		}}, nil
+	}
+
+	sb := &strings.Builder{}
+	_, _ = sb.WriteString("Your feeds:\n")
+	for i, f := range feeds {
+		_, _ = sb.WriteString(fmt.Sprintf("%d. %s", i+1, f.URL))
+		if f.Title != "" {
+			_, _ = sb.WriteString(fmt.Sprintf(" (%s)", f.Title))
+		}
+		_, _ = sb.WriteString("\n")
+	}
+
+	return []botx.Response{{
+		ChatID: req.Chat.ID,
+		Text:   sb.String(),
+	}}, nil
+}
+
+func feedName(f store.Feed) string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.URL
+}
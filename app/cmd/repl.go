@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/bot"
+	"github.com/Semior001/newsfeed/app/feed"
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/Semior001/newsfeed/pkg/botx/botapi"
+	"golang.org/x/exp/slog"
+)
+
+// Repl is a command to talk to the bot in the terminal, e.g. to try handlers
+// without a messaging platform. Lines of stdin are sent to the bot as messages
+// from the chat, that is authorized, subscribed and is the admin, responses
+// are printed to stdout.
+type Repl struct {
+	Chat struct {
+		ID       string `long:"id" env:"ID" default:"1" description:"ID of the chat"`
+		Username string `long:"username" env:"USERNAME" default:"developer" description:"username of the user"`
+		Type     string `long:"type" env:"TYPE" choice:"private" choice:"group" default:"private" description:"type of the chat"`
+		Title    string `long:"title" env:"TITLE" default:"console" description:"title of the group chat"`
+	} `group:"chat" namespace:"chat" env-namespace:"CHAT"`
+
+	Revisor RevisorOpts `group:"revisor" namespace:"revisor" env-namespace:"REVISOR"`
+
+	// prefixed, so that the repl doesn't pick up the store of the bot from the environment
+	Timeout   time.Duration `long:"timeout" env:"REPL_TIMEOUT" default:"6m" description:"timeout for requests"`
+	StorePath string        `long:"store-path" env:"REPL_STORE_PATH" default:"var/repl" description:"parent dir for local bolt files"`
+}
+
+// Execute runs the command.
+func (r Repl) Execute(_ []string) error {
+	lg := slog.Default()
+
+	if err := os.MkdirAll(r.StorePath, 0o700); err != nil {
+		return fmt.Errorf("make store dir: %w", err)
+	}
+
+	rev, prompts, closeCache, err := r.Revisor.makeService(lg, r.StorePath)
+	if err != nil {
+		return fmt.Errorf("make revisor: %w", err)
+	}
+	defer closeCache()
+
+	s, err := store.NewBolt(r.StorePath)
+	if err != nil {
+		return fmt.Errorf("make store: %w", err)
+	}
+
+	defer func() {
+		if err := s.Close(); err != nil {
+			lg.Error("close bolt store", slog.Any("err", err))
+		}
+	}()
+
+	chat := botx.Chat{ID: r.Chat.ID, Username: r.Chat.Username, Type: botx.ChatType(r.Chat.Type)}
+	if chat.IsGroup() {
+		chat.Title = r.Chat.Title
+	}
+
+	if err = r.authorize(context.Background(), s, chat); err != nil {
+		return fmt.Errorf("authorize chat: %w", err)
+	}
+
+	console := botapi.NewConsole(
+		lg.With(slog.String("prefix", "console")),
+		botapi.ConsoleParams{In: os.Stdin, Out: os.Stdout, Chat: chat},
+		10,
+	)
+
+	ctrl := &bot.Ctrl{
+		Logger:  lg.With(slog.String("prefix", "bot")),
+		Store:   s,
+		Service: rev,
+		Prompts: prompts,
+		Fetcher: feed.NewFetcher(
+			lg.With(slog.String("prefix", "fetcher")),
+			&http.Client{Timeout: 30 * time.Second},
+		),
+		API:            console,
+		Usernames:      []string{console.Username()},
+		AdminIDs:       []string{chat.ID},
+		HandlerTimeout: r.Timeout,
+	}
+
+	// requests are handled one by one, so that responses are printed in order
+	b := botx.NewBot(
+		ctrl.Routes().Handle,
+		console,
+		botx.WithLogger(lg.With(slog.String("prefix", "botx"))),
+		botx.WithWorkers(1),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go console.Run()
+
+	// the bot stops, when the input ends and all requests are handled
+	b.Run(ctx)
+	console.Stop()
+
+	return nil
+}
+
+// authorize saves the chat as the authorized and subscribed user, if it is not saved yet.
+func (r Repl) authorize(ctx context.Context, s store.Interface, chat botx.Chat) error {
+	u, err := s.Get(ctx, chat.ID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		u = store.User{ChatID: chat.ID, Username: chat.Username, Title: chat.Title}
+	case err != nil:
+		return fmt.Errorf("get user: %w", err)
+	case u.Authorized && u.Subscribed:
+		return nil
+	}
+
+	u.Authorized, u.Subscribed = true, true
+	if err = s.Put(ctx, u); err != nil {
+		return fmt.Errorf("put user: %w", err)
+	}
+
+	return nil
+}
@@ -6,12 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Semior001/newsfeed/app/bot"
+	"github.com/Semior001/newsfeed/app/feed"
 	"github.com/Semior001/newsfeed/app/revisor"
 	"github.com/Semior001/newsfeed/app/store"
 	"github.com/Semior001/newsfeed/pkg/botx"
@@ -23,42 +27,86 @@ import (
 // Run is a command to run the bot.
 type Run struct {
 	Bot struct {
-		Timeout time.Duration `long:"timeout" env:"TIMEOUT" default:"6m" description:"timeout for requests"`
+		Timeout   time.Duration `long:"timeout" env:"TIMEOUT" default:"6m" description:"timeout for requests"`
+		Platforms []string      `long:"platform" env:"PLATFORM" env-delim:"," choice:"telegram" choice:"slack" choice:"matrix" choice:"discord" default:"telegram" description:"messaging platforms to run the bot on, chat IDs of all but the first one are prefixed with the platform, e.g. slack:C123"`
 
 		Telegram struct {
 			Token string `long:"token" env:"TOKEN" description:"telegram token"`
+
+			Webhook struct {
+				URL    string `long:"url" env:"URL" description:"public URL of the webhook, long polling is used if empty"`
+				Secret string `long:"secret" env:"SECRET" description:"secret token to verify webhook requests, required for webhook"`
+				Listen string `long:"listen" env:"LISTEN" default:":8080" description:"address to listen for webhook requests"`
+			} `group:"webhook" namespace:"webhook" env-namespace:"WEBHOOK"`
 		} `group:"telegram" namespace:"telegram" env-namespace:"TELEGRAM"`
 
+		Slack struct {
+			Token    string `long:"token" env:"TOKEN" description:"slack bot token (xoxb-...)"`
+			AppToken string `long:"app-token" env:"APP_TOKEN" description:"slack app-level token (xapp-...) for socket mode"`
+			Command  string `long:"command" env:"COMMAND" default:"/newsfeed" description:"slash command of the bot"`
+		} `group:"slack" namespace:"slack" env-namespace:"SLACK"`
+
+		Matrix struct {
+			URL   string `long:"url" env:"URL" description:"base URL of the matrix homeserver"`
+			Token string `long:"token" env:"TOKEN" description:"access token of the matrix bot user"`
+		} `group:"matrix" namespace:"matrix" env-namespace:"MATRIX"`
+
+		Discord struct {
+			Token string `long:"token" env:"TOKEN" description:"discord bot token"`
+		} `group:"discord" namespace:"discord" env-namespace:"DISCORD"`
+
 		AdminIDs  []string `long:"admin-ids" env:"ADMIN_IDS" description:"admin IDs"`
 		AuthToken string   `long:"auth-token" env:"AUTH_TOKEN" description:"token for authorizing requests"`
 	} `group:"bot" namespace:"bot" env-namespace:"BOT"`
 
-	Revisor struct {
-		OpenAI struct {
-			Token     string        `long:"token" env:"TOKEN" description:"OpenAI token"`
-			MaxTokens int           `long:"max-tokens" env:"MAX_TOKENS" default:"1000" description:"max tokens for OpenAI"`
-			Timeout   time.Duration `long:"timeout" env:"TIMEOUT" default:"5m" description:"timeout for OpenAI calls"`
-		} `group:"openai" namespace:"openai" env-namespace:"OPENAI"`
-	} `group:"revisor" namespace:"revisor" env-namespace:"REVISOR"`
+	Revisor RevisorOpts `group:"revisor" namespace:"revisor" env-namespace:"REVISOR"`
+
+	Feed struct {
+		URLs     []string      `long:"url" env:"URLS" env-delim:"," description:"default feeds, newly authorized users are subscribed to them"`
+		Interval time.Duration `long:"interval" env:"INTERVAL" default:"15m" description:"interval between feed polls"`
+	} `group:"feed" namespace:"feed" env-namespace:"FEED"`
 
 	StorePath string `long:"store-path" env:"STORE_PATH" description:"parent dir for bolt files"`
 }
 
+// RevisorOpts defines options of the summaries service.
+type RevisorOpts struct {
+	Provider  string `long:"provider" env:"PROVIDER" choice:"openai" choice:"extractive" default:"openai" description:"summarizer provider"`
+	PromptDir string `long:"prompt-dir" env:"PROMPT_DIR" description:"directory with *.tmpl prompts, reloaded on changes"`
+	Prompt    string `long:"prompt" env:"PROMPT" default:"default" description:"name of the prompt to use"`
+
+	OpenAI struct {
+		Token     string        `long:"token" env:"TOKEN" description:"OpenAI token"`
+		BaseURL   string        `long:"base-url" env:"BASE_URL" description:"base URL of OpenAI-compatible API, e.g. local llama.cpp or Ollama server"`
+		Model     string        `long:"model" env:"MODEL" default:"gpt-3.5-turbo" description:"model to use"`
+		MaxTokens int           `long:"max-tokens" env:"MAX_TOKENS" default:"1000" description:"max tokens for OpenAI"`
+		Timeout   time.Duration `long:"timeout" env:"TIMEOUT" default:"5m" description:"timeout for OpenAI calls"`
+	} `group:"openai" namespace:"openai" env-namespace:"OPENAI"`
+
+	Extractive struct {
+		MaxBulletPoints int  `long:"max-bullet-points" env:"MAX_BULLET_POINTS" default:"5" description:"max sentences in extractive summary"`
+		NoFallback      bool `long:"no-fallback" env:"NO_FALLBACK" description:"don't fall back to extractive summary when the provider fails"`
+	} `group:"extractive" namespace:"extractive" env-namespace:"EXTRACTIVE"`
+
+	Cache struct {
+		MemoryKeys int           `long:"memory-keys" env:"MEMORY_KEYS" default:"100" description:"max summaries in memory"`
+		MaxKeys    int           `long:"max-keys" env:"MAX_KEYS" default:"10000" description:"max summaries in persistent cache, 0 for no limit"`
+		TTL        time.Duration `long:"ttl" env:"TTL" default:"720h" description:"ttl of cached summaries, 0 for no limit"`
+	} `group:"cache" namespace:"cache" env-namespace:"CACHE"`
+}
+
+// promptsReloadInterval is how often the prompts directory is checked for changes.
+const promptsReloadInterval = 10 * time.Second
+
 // Execute runs the command.
 func (r Run) Execute(_ []string) error {
 	lg := slog.Default()
 
-	rev := revisor.NewService(
-		lg.With(slog.String("prefix", "revisor")),
-		&http.Client{Timeout: 5 * time.Second},
-		revisor.NewChatGPT(
-			lg.With(slog.String("prefix", "chatgpt")),
-			http.Client{Timeout: r.Revisor.OpenAI.Timeout},
-			r.Revisor.OpenAI.Token,
-			r.Revisor.OpenAI.MaxTokens,
-		),
-		revisor.NewExtractor(),
-	)
+	rev, prompts, closeCache, err := r.Revisor.makeService(lg, r.StorePath)
+	if err != nil {
+		return fmt.Errorf("make revisor: %w", err)
+	}
+	defer closeCache()
 
 	s, err := store.NewBolt(r.StorePath)
 	if err != nil {
@@ -71,28 +119,51 @@ func (r Run) Execute(_ []string) error {
 		}
 	}()
 
-	api, err := botapi.NewTelegram(
-		lg.With(slog.String("prefix", "telegram")),
-		r.Bot.Telegram.Token,
-		100,
-	)
+	p, err := r.makePlatforms(lg)
 	if err != nil {
-		return fmt.Errorf("make telegram controller: %w", err)
+		return fmt.Errorf("make platforms: %w", err)
 	}
 
+	fetcher := feed.NewFetcher(
+		lg.With(slog.String("prefix", "fetcher")),
+		&http.Client{Timeout: 30 * time.Second},
+	)
+
 	ctrl := &bot.Ctrl{
 		Logger:         lg.With(slog.String("prefix", "bot")),
 		Store:          s,
 		Service:        rev,
-		API:            api,
+		Prompts:        prompts,
+		Fetcher:        fetcher,
+		API:            p.API,
+		Usernames:      p.Usernames,
+		DefaultFeeds:   r.Feed.URLs,
 		AdminIDs:       r.Bot.AdminIDs,
 		AuthToken:      r.Bot.AuthToken,
 		HandlerTimeout: r.Bot.Timeout,
 	}
 
+	if err = r.addFeeds(context.Background(), s); err != nil {
+		return fmt.Errorf("add feeds: %w", err)
+	}
+
+	poller := feed.NewPoller(
+		lg.With(slog.String("prefix", "poller")),
+		fetcher,
+		s,
+		r.Feed.Interval,
+		ctrl.DeliverFeedItem,
+	)
+
+	if p.RegisterCommands != nil {
+		if err = p.RegisterCommands(context.Background(), ctrl.Commands()); err != nil {
+			return fmt.Errorf("register commands: %w", err)
+		}
+	}
+
 	b := botx.NewBot(
 		ctrl.Routes().Handle,
-		api,
+		p.API,
 		botx.WithLogger(lg.With(slog.String("prefix", "botx"))),
 		botx.WithWorkers(10),
 	)
@@ -122,14 +193,29 @@ func (r Run) Execute(_ []string) error {
 		lg.Warn("bot stopped")
 		return nil
 	})
+	ewg.Go(func() error {
+		lg.Info("starting digest scheduler")
+		err := ctrl.RunDigests(ctx)
+		lg.Warn("digest scheduler stopped")
+		return err
+	})
+	ewg.Go(func() error {
+		lg.Info("starting feed poller")
+		err := poller.Run(ctx)
+		lg.Warn("feed poller stopped")
+		return err
+	})
+	ewg.Go(func() error {
+		return prompts.Watch(ctx, promptsReloadInterval)
+	})
 
 	// we should run api out of errgroup, because it lives longer than the context,
 	// as we want to notify admins about bot stopping
 	apiStopped := make(chan struct{})
 	go func() {
-		lg.Info("starting telegram api")
-		api.Run()
-		lg.Warn("telegram api stopped listening for updates")
+		lg.Info("starting api", slog.Any("platforms", r.Bot.Platforms))
+		p.Run()
+		lg.Warn("api stopped listening for updates")
 		apiStopped <- struct{}{}
 	}()
 
@@ -147,10 +233,299 @@ func (r Run) Execute(_ []string) error {
 		return fmt.Errorf("notify admins about stopped bot: %w", err)
 	}
 
-	lg.Info("stopping telegram api")
-	api.Stop()
+	lg.Info("stopping api")
+	p.Stop()
 	<-apiStopped
-	lg.Info("telegram api stopped")
+	lg.Info("api stopped")
+
+	return nil
+}
+
+// platform is the messaging platform, the bot runs on.
+type platform struct {
+	API       botx.API
+	Usernames []string // of the bot, to recognize mentions
+	Run       func()   // receives updates until Stop is called
+	Stop      func()
+
+	// RegisterCommands, if set, registers commands of the bot on the platform
+	RegisterCommands func(ctx context.Context, cmds []botx.Command) error
+}
+
+// makePlatforms returns the selected messaging platforms combined into one,
+// the first of them is the primary one, see botx.Mux.
+func (r Run) makePlatforms(lg *slog.Logger) (platform, error) {
+	if len(r.Bot.Platforms) == 0 {
+		return platform{}, errors.New("no platforms selected")
+	}
+
+	apis := map[string]botx.API{}
+	var platforms []platform
+	var usernames []string
+
+	for _, name := range r.Bot.Platforms {
+		if _, ok := apis[name]; ok {
+			return platform{}, fmt.Errorf("platform %s is selected twice", name)
+		}
+
+		p, err := r.makePlatform(lg, name)
+		if err != nil {
+			return platform{}, fmt.Errorf("make %s controller: %w", name, err)
+		}
+
+		apis[name] = p.API
+		platforms = append(platforms, p)
+		usernames = append(usernames, p.Usernames...)
+	}
+
+	mux, err := botx.NewMux(r.Bot.Platforms[0], apis, 100)
+	if err != nil {
+		return platform{}, fmt.Errorf("make mux: %w", err)
+	}
+
+	run := func() {
+		wg := &sync.WaitGroup{}
+		for _, p := range platforms {
+			wg.Add(1)
+			go func(p platform) {
+				defer wg.Done()
+				p.Run()
+			}(p)
+		}
+
+		mux.Run()
+		wg.Wait()
+	}
+
+	stop := func() {
+		for _, p := range platforms {
+			p.Stop()
+		}
+		mux.Stop()
+	}
+
+	registerCommands := func(ctx context.Context, cmds []botx.Command) error {
+		for i, p := range platforms {
+			if p.RegisterCommands == nil {
+				continue
+			}
+
+			if err := p.RegisterCommands(ctx, cmds); err != nil {
+				return fmt.Errorf("register commands on %s: %w", r.Bot.Platforms[i], err)
+			}
+		}
+		return nil
+	}
+
+	return platform{API: mux, Usernames: usernames, Run: run, Stop: stop, RegisterCommands: registerCommands}, nil
+}
+
+// makePlatform returns the API of the messaging platform by its name.
+func (r Run) makePlatform(lg *slog.Logger, name string) (platform, error) {
+	switch name {
+	case "slack":
+		api, err := botapi.NewSlack(
+			lg.With(slog.String("prefix", "slack")),
+			botapi.SlackParams{
+				Token:    r.Bot.Slack.Token,
+				AppToken: r.Bot.Slack.AppToken,
+				Command:  r.Bot.Slack.Command,
+			},
+			100,
+		)
+		if err != nil {
+			return platform{}, err
+		}
+
+		return platform{API: api, Usernames: []string{api.Username()}, Run: api.Run, Stop: api.Stop}, nil
+	case "matrix":
+		api, err := botapi.NewMatrix(
+			lg.With(slog.String("prefix", "matrix")),
+			botapi.MatrixParams{
+				URL:           r.Bot.Matrix.URL,
+				Token:         r.Bot.Matrix.Token,
+				SyncTokenPath: path.Join(r.StorePath, "matrix_sync_token"),
+			},
+			100,
+		)
+		if err != nil {
+			return platform{}, err
+		}
+
+		return platform{API: api, Usernames: []string{api.Username()}, Run: api.Run, Stop: api.Stop}, nil
+	case "discord":
+		api, err := botapi.NewDiscord(
+			lg.With(slog.String("prefix", "discord")),
+			botapi.DiscordParams{Token: r.Bot.Discord.Token},
+			100,
+		)
+		if err != nil {
+			return platform{}, err
+		}
+
+		return platform{
+			API:              api,
+			Usernames:        []string{api.Username()},
+			Run:              api.Run,
+			Stop:             api.Stop,
+			RegisterCommands: api.RegisterCommands,
+		}, nil
+	default:
+		api, err := botapi.NewTelegram(
+			lg.With(slog.String("prefix", "telegram")),
+			r.Bot.Telegram.Token,
+			100,
+		)
+		if err != nil {
+			return platform{}, err
+		}
+
+		run, stop, err := r.telegramRunner(lg, api)
+		if err != nil {
+			return platform{}, fmt.Errorf("prepare telegram api: %w", err)
+		}
+
+		return platform{API: api, Usernames: []string{api.Username()}, Run: run, Stop: stop}, nil
+	}
+}
+
+// telegramRunner returns functions to run and stop receiving telegram updates,
+// either by long polling or by webhook, if its URL is set.
+func (r Run) telegramRunner(lg *slog.Logger, api *botapi.Telegram) (run, stop func(), err error) {
+	wh := r.Bot.Telegram.Webhook
+	if wh.URL == "" {
+		return api.Run, api.Stop, nil
+	}
+
+	u, err := url.Parse(wh.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse webhook url: %w", err)
+	}
+
+	if err = api.SetWebhook(botapi.WebhookParams{URL: wh.URL, Secret: wh.Secret}); err != nil {
+		return nil, nil, err
+	}
+
+	pattern := u.Path
+	if pattern == "" {
+		pattern = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, api)
+
+	srv := &http.Server{
+		Addr:              wh.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	run = func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			lg.Error("webhook server failed", slog.Any("err", err))
+		}
+	}
+
+	stop = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			lg.Warn("shutdown webhook server", slog.Any("err", err))
+		}
+
+		api.Stop()
+	}
+
+	return run, stop, nil
+}
+
+// makeService returns the summaries service with the prompts and the cache,
+// that must be closed after the service is no longer used.
+func (o RevisorOpts) makeService(lg *slog.Logger, storePath string) (*revisor.Service, *revisor.Prompts, func(), error) {
+	boltCache, err := revisor.NewBoltCache(
+		lg.With(slog.String("prefix", "cache")),
+		path.Join(storePath, "cache.db"),
+		o.Cache.TTL,
+		o.Cache.MaxKeys,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("make summaries cache: %w", err)
+	}
+
+	closeCache := func() {
+		if err := boltCache.Close(); err != nil {
+			lg.Error("close summaries cache", slog.Any("err", err))
+		}
+	}
+
+	summaries := revisor.TieredCache{revisor.NewLRUCache(o.Cache.MemoryKeys, o.Cache.TTL), boltCache}
+
+	prompts, err := revisor.NewPrompts(lg.With(slog.String("prefix", "prompts")), o.PromptDir)
+	if err != nil {
+		closeCache()
+		return nil, nil, nil, fmt.Errorf("load prompts: %w", err)
+	}
+
+	if _, ok := prompts.Get(o.Prompt); !ok {
+		closeCache()
+		return nil, nil, nil, fmt.Errorf("prompt %q not found", o.Prompt)
+	}
+
+	rev := revisor.NewService(
+		lg.With(slog.String("prefix", "revisor")),
+		&http.Client{Timeout: 5 * time.Second},
+		o.makeSummarizer(lg, prompts, summaries),
+		o.makeFallback(),
+		revisor.NewExtractor(),
+		summaries,
+	)
+
+	return rev, prompts, closeCache, nil
+}
+
+func (o RevisorOpts) makeSummarizer(lg *slog.Logger, prompts *revisor.Prompts, cache revisor.Cache) revisor.Summarizer {
+	switch o.Provider {
+	case "extractive":
+		return revisor.NewExtractive(o.Extractive.MaxBulletPoints)
+	default:
+		return revisor.NewChatGPT(
+			lg.With(slog.String("prefix", "chatgpt")),
+			http.Client{Timeout: o.OpenAI.Timeout},
+			revisor.ChatGPTParams{
+				Token:             o.OpenAI.Token,
+				BaseURL:           o.OpenAI.BaseURL,
+				Model:             o.OpenAI.Model,
+				MaxResponseTokens: o.OpenAI.MaxTokens,
+				Prompts:           prompts,
+				Prompt:            o.Prompt,
+			},
+			cache,
+		)
+	}
+}
+
+func (o RevisorOpts) makeFallback() revisor.Summarizer {
+	if o.Extractive.NoFallback || o.Provider == "extractive" {
+		return nil
+	}
+	return revisor.NewExtractive(o.Extractive.MaxBulletPoints)
+}
+
+func (r Run) addFeeds(ctx context.Context, s store.Interface) error {
+	for _, u := range r.Feed.URLs {
+		_, err := s.GetFeed(ctx, u)
+		switch {
+		case err == nil:
+			continue
+		case !errors.Is(err, store.ErrNotFound):
+			return fmt.Errorf("get feed %s: %w", u, err)
+		}
+
+		if err = s.PutFeed(ctx, store.Feed{URL: u}); err != nil {
+			return fmt.Errorf("put feed %s: %w", u, err)
+		}
+	}
 
 	return nil
 }
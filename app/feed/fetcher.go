@@ -0,0 +1,66 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/exp/slog"
+)
+
+// Fetcher downloads and parses feeds.
+type Fetcher struct {
+	log *slog.Logger
+	cl  *http.Client
+}
+
+// NewFetcher creates new Fetcher.
+func NewFetcher(lg *slog.Logger, cl *http.Client) *Fetcher {
+	return &Fetcher{log: lg, cl: cl}
+}
+
+// Fetch downloads and parses the feed, relative item links are resolved
+// against the feed URL.
+func (f *Fetcher) Fetch(ctx context.Context, u string) (Document, error) {
+	base, err := url.Parse(u)
+	if err != nil {
+		return Document{}, fmt.Errorf("parse feed url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return Document{}, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := f.cl.Do(req)
+	if err != nil {
+		return Document{}, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			f.log.WarnCtx(ctx, "failed to close response body", slog.Any("err", err))
+		}
+	}()
+
+	ok := resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+	if !ok {
+		return Document{}, fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+
+	doc, err := Parse(resp.Body)
+	if err != nil {
+		return Document{}, fmt.Errorf("parse feed: %w", err)
+	}
+
+	for i, item := range doc.Items {
+		if item.URL == "" {
+			continue
+		}
+		if ref, err := url.Parse(item.URL); err == nil {
+			doc.Items[i].URL = base.ResolveReference(ref).String()
+		}
+	}
+
+	return doc, nil
+}
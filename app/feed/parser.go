@@ -0,0 +1,238 @@
+// Package feed contains services for polling RSS, Atom and JSON feeds.
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Document is a parsed feed.
+type Document struct {
+	Title string
+	Items []Item
+}
+
+// Item is a single entry of the feed.
+type Item struct {
+	ID        string
+	Title     string
+	URL       string
+	Published time.Time
+}
+
+// ErrUnknownFormat is returned when the document is neither RSS, Atom nor JSON feed.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Parse parses RSS (0.9x, 1.0, 2.0), Atom or JSON feed.
+// Items without ID and URL are skipped.
+func Parse(rd io.Reader) (Document, error) {
+	bts, err := io.ReadAll(rd)
+	if err != nil {
+		return Document{}, fmt.Errorf("read feed: %w", err)
+	}
+
+	var doc Document
+	if trimmed := bytes.TrimSpace(bts); len(trimmed) > 0 && trimmed[0] == '{' {
+		doc, err = parseJSON(trimmed)
+	} else {
+		doc, err = parseXML(bts)
+	}
+	if err != nil {
+		return Document{}, err
+	}
+
+	items := doc.Items[:0]
+	for _, item := range doc.Items {
+		item.Title = strings.TrimSpace(item.Title)
+		item.URL = strings.TrimSpace(item.URL)
+		item.ID = strings.TrimSpace(item.ID)
+		if item.ID == "" {
+			item.ID = item.URL
+		}
+		if item.ID == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	doc.Items = items
+	doc.Title = strings.TrimSpace(doc.Title)
+
+	return doc, nil
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	About   string `xml:"about,attr"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // dublin core, used by RSS 1.0
+}
+
+type rssDoc struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"` // RSS 1.0 keeps items out of the channel
+}
+
+type atomDoc struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+func parseXML(bts []byte) (Document, error) {
+	dec := xml.NewDecoder(bytes.NewReader(bts))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return Document{}, ErrUnknownFormat
+			}
+			return Document{}, fmt.Errorf("read xml token: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "rss", "RDF":
+			var rss rssDoc
+			if err = dec.DecodeElement(&rss, &start); err != nil {
+				return Document{}, fmt.Errorf("decode rss: %w", err)
+			}
+			return rss.document(), nil
+		case "feed":
+			var atom atomDoc
+			if err = dec.DecodeElement(&atom, &start); err != nil {
+				return Document{}, fmt.Errorf("decode atom: %w", err)
+			}
+			return atom.document(), nil
+		default:
+			return Document{}, ErrUnknownFormat
+		}
+	}
+}
+
+func (r rssDoc) document() Document {
+	doc := Document{Title: r.Channel.Title}
+	for _, it := range append(r.Channel.Items, r.Items...) {
+		item := Item{ID: it.GUID, Title: it.Title, URL: it.Link}
+		if item.ID == "" {
+			item.ID = it.About
+		}
+		if item.URL == "" && strings.HasPrefix(item.ID, "http") {
+			item.URL = item.ID
+		}
+		item.Published = parseTime(it.PubDate)
+		if item.Published.IsZero() {
+			item.Published = parseTime(it.Date)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return doc
+}
+
+func (a atomDoc) document() Document {
+	doc := Document{Title: a.Title}
+	for _, e := range a.Entries {
+		item := Item{ID: e.ID, Title: e.Title}
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				item.URL = l.Href
+				break
+			}
+		}
+		item.Published = parseTime(e.Published)
+		if item.Published.IsZero() {
+			item.Published = parseTime(e.Updated)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return doc
+}
+
+type jsonDoc struct {
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	Items   []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		ExternalURL   string          `json:"external_url"`
+		Title         string          `json:"title"`
+		DatePublished string          `json:"date_published"`
+	} `json:"items"`
+}
+
+func parseJSON(bts []byte) (Document, error) {
+	var j jsonDoc
+	if err := json.Unmarshal(bts, &j); err != nil {
+		return Document{}, fmt.Errorf("decode json feed: %w", err)
+	}
+
+	if !strings.HasPrefix(j.Version, "https://jsonfeed.org/version/") {
+		return Document{}, ErrUnknownFormat
+	}
+
+	doc := Document{Title: j.Title}
+	for _, it := range j.Items {
+		item := Item{Title: it.Title, URL: it.URL, Published: parseTime(it.DatePublished)}
+		if item.URL == "" {
+			item.URL = it.ExternalURL
+		}
+		// JSON Feed 1.0 allowed numeric IDs
+		if err := json.Unmarshal(it.ID, &item.ID); err != nil {
+			item.ID = string(it.ID)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	return doc, nil
+}
+
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTime returns zero time if the value doesn't match any known layout.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
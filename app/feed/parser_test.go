@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	almaty := time.FixedZone("", 6*60*60)
+
+	tests := []struct {
+		name     string
+		file     string
+		expected Document
+	}{
+		{
+			name: "rss 2.0",
+			file: "data/test/rss.xml",
+			expected: Document{
+				Title: "Example News",
+				Items: []Item{
+					{
+						ID:        "news-2",
+						Title:     "Second article",
+						URL:       "https://example.com/news/2",
+						Published: time.Date(2023, 3, 21, 10, 0, 0, 0, almaty),
+					},
+					{
+						ID:        "/news/1",
+						Title:     "First article",
+						URL:       "/news/1",
+						Published: time.Date(2023, 3, 20, 10, 0, 0, 0, almaty),
+					},
+				},
+			},
+		},
+		{
+			name: "rss 1.0 in windows-1251",
+			file: "data/test/rdf.xml",
+			expected: Document{
+				Title: "Новости",
+				Items: []Item{{
+					ID:        "https://example.com/rdf/1",
+					Title:     "RDF item",
+					URL:       "https://example.com/rdf/1",
+					Published: time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		{
+			name: "atom",
+			file: "data/test/atom.xml",
+			expected: Document{
+				Title: "Example Atom",
+				Items: []Item{{
+					ID:        "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a",
+					Title:     "Atom entry",
+					URL:       "https://example.com/entries/1",
+					Published: time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC),
+				}},
+			},
+		},
+		{
+			name: "json feed",
+			file: "data/test/feed.json",
+			expected: Document{
+				Title: "Example JSON",
+				Items: []Item{
+					{
+						ID:        "42",
+						Title:     "JSON item",
+						URL:       "https://example.com/json/42",
+						Published: time.Date(2023, 3, 21, 10, 0, 0, 0, almaty),
+					},
+					{
+						ID:    "no-url",
+						Title: "External item",
+						URL:   "https://other.example.com/post",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.file)
+			require.NoError(t, err)
+			defer f.Close()
+
+			doc, err := Parse(f)
+			require.NoError(t, err)
+
+			require.Len(t, doc.Items, len(tt.expected.Items))
+			for i := range doc.Items {
+				assert.True(t, tt.expected.Items[i].Published.Equal(doc.Items[i].Published),
+					"expected %s, got %s", tt.expected.Items[i].Published, doc.Items[i].Published)
+				doc.Items[i].Published = tt.expected.Items[i].Published
+			}
+			assert.Equal(t, tt.expected, doc)
+		})
+	}
+}
+
+func TestParse_UnknownFormat(t *testing.T) {
+	_, err := Parse(strings.NewReader("<html><body>not a feed</body></html>"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse(strings.NewReader(`{"title": "not a feed"}`))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,125 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/Semior001/newsfeed/pkg/logx"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"golang.org/x/exp/slog"
+)
+
+// NotifyFunc is called for every new item of the feed.
+type NotifyFunc func(ctx context.Context, f store.Feed, item Item) error
+
+// Poller periodically fetches feeds from the store and
+// notifies about items that were not seen before.
+type Poller struct {
+	log      *slog.Logger
+	fetcher  *Fetcher
+	store    store.Interface
+	interval time.Duration
+	notify   NotifyFunc
+}
+
+// NewPoller creates new Poller.
+func NewPoller(lg *slog.Logger, fetcher *Fetcher, s store.Interface, interval time.Duration, notify NotifyFunc) *Poller {
+	return &Poller{
+		log:      lg,
+		fetcher:  fetcher,
+		store:    s,
+		interval: interval,
+		notify:   notify,
+	}
+}
+
+// Run polls feeds until context is dead.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.pollAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) pollAll(ctx context.Context) {
+	feeds, err := p.store.ListFeeds(ctx)
+	if err != nil {
+		p.log.ErrorCtx(ctx, "failed to list feeds", slog.Any("err", err))
+		return
+	}
+
+	for _, f := range feeds {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err = p.poll(ctx, f); err != nil {
+			p.log.WarnCtx(ctx, "failed to poll feed", slog.String("feed", f.URL), slog.Any("err", err))
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, f store.Feed) error {
+	doc, err := p.fetcher.Fetch(ctx, f.URL)
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+
+	// empty document is most likely a glitch on the feed's side,
+	// don't forget known items, otherwise they will be sent again
+	if len(doc.Items) == 0 {
+		return nil
+	}
+
+	fresh, err := p.store.UpdateFeedItems(ctx, f.URL, lo.Map(doc.Items, func(it Item, _ int) string { return it.ID }))
+	if err != nil {
+		return fmt.Errorf("update feed items: %w", err)
+	}
+
+	firstPoll := f.LastPolledAt.IsZero()
+
+	f.LastPolledAt = time.Now()
+	if doc.Title != "" {
+		f.Title = doc.Title
+	}
+
+	if err = p.store.PutFeed(ctx, f); err != nil {
+		return fmt.Errorf("update feed: %w", err)
+	}
+
+	// we don't want to flood subscribers with the whole backlog of a newly added feed
+	if firstPoll {
+		p.log.InfoCtx(ctx, "feed polled for the first time, skipping existing items",
+			slog.String("feed", f.URL), slog.Int("items", len(fresh)))
+		return nil
+	}
+
+	// feeds usually list the newest items first, notify in chronological order
+	for i := len(doc.Items) - 1; i >= 0; i-- {
+		item := doc.Items[i]
+		if !lo.Contains(fresh, item.ID) {
+			continue
+		}
+
+		ctx := logx.ContextWithRequestID(ctx, uuid.New().String())
+		p.log.DebugCtx(ctx, "new feed item", slog.String("feed", f.URL), slog.String("url", item.URL))
+
+		if err := p.notify(ctx, f, item); err != nil {
+			p.log.WarnCtx(ctx, "failed to notify about feed item",
+				slog.String("feed", f.URL), slog.String("url", item.URL), slog.Any("err", err))
+		}
+	}
+
+	return nil
+}
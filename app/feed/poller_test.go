@@ -0,0 +1,92 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestPoller_Run(t *testing.T) {
+	mu := &sync.Mutex{}
+	items := []string{"1"}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sb := &strings.Builder{}
+		sb.WriteString(`<rss version="2.0"><channel><title>test feed</title>`)
+		// newest first
+		for i := len(items) - 1; i >= 0; i-- {
+			_, _ = fmt.Fprintf(sb, `<item><title>item %[1]s</title><link>/news/%[1]s</link></item>`, items[i])
+		}
+		sb.WriteString(`</channel></rss>`)
+
+		_, err := w.Write([]byte(sb.String()))
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	s, err := store.NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.PutFeed(context.Background(), store.Feed{URL: ts.URL}))
+
+	notified := make(chan Item, 10)
+	p := NewPoller(slog.Default(), NewFetcher(slog.Default(), ts.Client()), s, 10*time.Millisecond,
+		func(_ context.Context, f store.Feed, item Item) error {
+			assert.Equal(t, ts.URL, f.URL)
+			assert.Equal(t, "test feed", f.Title)
+			notified <- item
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		assert.ErrorIs(t, p.Run(ctx), context.Canceled)
+		close(done)
+	}()
+
+	// wait for the first poll, its items must be skipped
+	require.Eventually(t, func() bool {
+		f, err := s.GetFeed(context.Background(), ts.URL)
+		require.NoError(t, err)
+		return !f.LastPolledAt.IsZero()
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	items = append(items, "2", "3")
+	mu.Unlock()
+
+	for _, id := range []string{"2", "3"} {
+		select {
+		case item := <-notified:
+			assert.Equal(t, Item{
+				ID:    "/news/" + id,
+				Title: "item " + id,
+				URL:   ts.URL + "/news/" + id,
+			}, item)
+		case <-time.After(time.Second):
+			t.Fatalf("item %s was not delivered", id)
+		}
+	}
+
+	cancel()
+	<-done
+
+	assert.Empty(t, notified, "items must be delivered only once")
+}
@@ -13,9 +13,10 @@ import (
 )
 
 var opts struct {
-	Run      cmd.Run `command:"run" description:"run newsfeed bot"`
-	JSONLogs bool    `long:"json-logs" env:"JSON_LOGS" description:"turn on json logs"`
-	Debug    bool    `long:"dbg" env:"DEBUG" description:"turn on debug mode"`
+	Run      cmd.Run  `command:"run" description:"run newsfeed bot"`
+	Repl     cmd.Repl `command:"repl" description:"talk to the bot in the terminal"`
+	JSONLogs bool     `long:"json-logs" env:"JSON_LOGS" description:"turn on json logs"`
+	Debug    bool     `long:"dbg" env:"DEBUG" description:"turn on debug mode"`
 }
 
 var version = "unknown"
@@ -0,0 +1,354 @@
+package revisor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	cache "github.com/go-pkgz/expirable-cache/v2"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/exp/slog"
+)
+
+// Cache stores summaries of articles.
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string)
+	// Stat returns statistics of every tier of the cache.
+	Stat() []CacheStat
+}
+
+// CacheStat contains statistics of a cache tier.
+type CacheStat struct {
+	Name    string
+	Hits    int
+	Misses  int
+	Added   int
+	Evicted int
+	Size    int
+}
+
+// LRUCache is an in-memory cache with TTL and the limited number of keys.
+type LRUCache struct {
+	cache cache.Cache[string, string]
+}
+
+// NewLRUCache creates new LRUCache. Zero ttl means no expiration.
+func NewLRUCache(maxKeys int, ttl time.Duration) *LRUCache {
+	c := cache.NewCache[string, string]().WithLRU().WithMaxKeys(maxKeys)
+	if ttl > 0 {
+		c = c.WithTTL(ttl)
+	}
+	return &LRUCache{cache: c}
+}
+
+// Get returns the value by key.
+func (c *LRUCache) Get(key string) (string, bool) { return c.cache.Get(key) }
+
+// Set sets the value by key.
+func (c *LRUCache) Set(key, value string) { c.cache.Set(key, value, 0) }
+
+// Stat returns cache stats.
+func (c *LRUCache) Stat() []CacheStat {
+	st := c.cache.Stat()
+	return []CacheStat{{
+		Name:    "memory",
+		Hits:    st.Hits,
+		Misses:  st.Misses,
+		Added:   st.Added,
+		Evicted: st.Evicted,
+		Size:    c.cache.Len(),
+	}}
+}
+
+const (
+	cacheEntriesBktName = "entries"
+	// creation time -> key, to evict the oldest entries first
+	cacheAgeBktName = "age"
+)
+
+// BoltCache is a persistent cache with TTL and the limited number of keys.
+// When the cache is full, the oldest entry is evicted.
+type BoltCache struct {
+	log     *slog.Logger
+	db      *bolt.DB
+	ttl     time.Duration
+	maxKeys int
+
+	// size is the number of entries, counting them walks the whole bucket,
+	// so it is changed along with entries in write transactions, which
+	// are serialized by bolt
+	size atomic.Int64
+
+	hits, misses, added, evicted atomic.Int64
+}
+
+type boltCacheEntry struct {
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewBoltCache opens or creates a cache in the bolt file by the given path.
+// Zero ttl or maxKeys means no limit.
+func NewBoltCache(lg *slog.Logger, path string, ttl time.Duration, maxKeys int) (*BoltCache, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make boltdb for %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{cacheEntriesBktName, cacheAgeBktName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create top-level bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("make buckets: %w", err)
+	}
+
+	c := &BoltCache{log: lg, db: db, ttl: ttl, maxKeys: maxKeys}
+	if err = c.count(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Get returns the value by key, expired entries are removed.
+func (c *BoltCache) Get(key string) (string, bool) {
+	var (
+		entry   boltCacheEntry
+		found   bool
+		expired bool
+	)
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bts := tx.Bucket([]byte(cacheEntriesBktName)).Get([]byte(key))
+		if bts == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(bts, &entry); err != nil {
+			return fmt.Errorf("unmarshal entry: %w", err)
+		}
+
+		found = true
+		expired = c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl
+		return nil
+	})
+	if err != nil {
+		c.log.Warn("failed to get cache entry", slog.String("key", key), slog.Any("err", err))
+	}
+
+	if expired {
+		removed, err := c.removeExpired(key)
+		if err != nil {
+			c.log.Warn("failed to remove expired cache entry", slog.String("key", key), slog.Any("err", err))
+		}
+		if removed {
+			c.evicted.Add(1)
+		}
+	}
+
+	if !found || expired {
+		c.misses.Add(1)
+		return "", false
+	}
+
+	c.hits.Add(1)
+	return entry.Value, true
+}
+
+// Set sets the value by key, evicting the oldest entries if the cache is full.
+func (c *BoltCache) Set(key, value string) {
+	entry := boltCacheEntry{Value: value, CreatedAt: time.Now()}
+
+	err := c.update(func(tx *bolt.Tx) error {
+		entries := tx.Bucket([]byte(cacheEntriesBktName))
+		size := c.size.Load()
+
+		// replace the previous entry to keep the age index consistent
+		if bts := entries.Get([]byte(key)); bts != nil {
+			var prev boltCacheEntry
+			if err := json.Unmarshal(bts, &prev); err != nil {
+				return fmt.Errorf("unmarshal previous entry: %w", err)
+			}
+			if err := c.remove(tx, key, prev); err != nil {
+				return fmt.Errorf("remove previous entry: %w", err)
+			}
+			size--
+		}
+
+		age := tx.Bucket([]byte(cacheAgeBktName)).Cursor()
+		for ; c.maxKeys > 0 && size >= int64(c.maxKeys); size-- {
+			k, oldest := age.First()
+			if k == nil {
+				break
+			}
+
+			if err := entries.Delete(oldest); err != nil {
+				return fmt.Errorf("evict %s: %w", oldest, err)
+			}
+			if err := age.Delete(); err != nil {
+				return fmt.Errorf("evict %s from age index: %w", oldest, err)
+			}
+			c.evicted.Add(1)
+		}
+
+		bts, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("marshal entry: %w", err)
+		}
+
+		if err = entries.Put([]byte(key), bts); err != nil {
+			return fmt.Errorf("put entry: %w", err)
+		}
+
+		if err = tx.Bucket([]byte(cacheAgeBktName)).Put(ageKey(key, entry.CreatedAt), []byte(key)); err != nil {
+			return fmt.Errorf("put age index: %w", err)
+		}
+
+		c.size.Store(size + 1)
+		return nil
+	})
+	if err != nil {
+		c.log.Warn("failed to set cache entry", slog.String("key", key), slog.Any("err", err))
+		return
+	}
+
+	c.added.Add(1)
+}
+
+// removeExpired removes the entry by key, if it is still expired,
+// as it might have been replaced after it was read.
+func (c *BoltCache) removeExpired(key string) (removed bool, err error) {
+	err = c.update(func(tx *bolt.Tx) error {
+		bts := tx.Bucket([]byte(cacheEntriesBktName)).Get([]byte(key))
+		if bts == nil {
+			return nil
+		}
+
+		var entry boltCacheEntry
+		if err := json.Unmarshal(bts, &entry); err != nil {
+			return fmt.Errorf("unmarshal entry: %w", err)
+		}
+
+		if time.Since(entry.CreatedAt) <= c.ttl {
+			return nil
+		}
+
+		if err := c.remove(tx, key, entry); err != nil {
+			return err
+		}
+
+		c.size.Add(-1)
+		removed = true
+		return nil
+	})
+	return removed, err
+}
+
+func (c *BoltCache) remove(tx *bolt.Tx, key string, entry boltCacheEntry) error {
+	if err := tx.Bucket([]byte(cacheEntriesBktName)).Delete([]byte(key)); err != nil {
+		return fmt.Errorf("remove entry: %w", err)
+	}
+
+	if err := tx.Bucket([]byte(cacheAgeBktName)).Delete(ageKey(key, entry.CreatedAt)); err != nil {
+		return fmt.Errorf("remove age index: %w", err)
+	}
+
+	return nil
+}
+
+// update runs the write transaction, entries are counted again, if it fails,
+// as the number of entries might have been changed before the rollback.
+func (c *BoltCache) update(fn func(tx *bolt.Tx) error) error {
+	err := c.db.Update(fn)
+	if err == nil {
+		return nil
+	}
+
+	if cerr := c.count(); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+
+	return err
+}
+
+// count loads the number of entries from the bucket.
+func (c *BoltCache) count() error {
+	err := c.db.View(func(tx *bolt.Tx) error {
+		c.size.Store(int64(tx.Bucket([]byte(cacheEntriesBktName)).Stats().KeyN))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("count entries: %w", err)
+	}
+	return nil
+}
+
+// ageKey is a big-endian creation time followed by the key,
+// so that entries created at the same moment don't collide.
+func ageKey(key string, createdAt time.Time) []byte {
+	bts := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(bts, uint64(createdAt.UnixNano()))
+	return append(bts, key...)
+}
+
+// Stat returns cache stats.
+func (c *BoltCache) Stat() []CacheStat {
+	return []CacheStat{{
+		Name:    "bolt",
+		Hits:    int(c.hits.Load()),
+		Misses:  int(c.misses.Load()),
+		Added:   int(c.added.Load()),
+		Evicted: int(c.evicted.Load()),
+		Size:    int(c.size.Load()),
+	}}
+}
+
+// Close closes the underlying bolt file.
+func (c *BoltCache) Close() error { return c.db.Close() }
+
+// TieredCache looks up the value in every tier in order,
+// filling the faster tiers on hits in the slower ones.
+type TieredCache []Cache
+
+// Get returns the value from the first tier that has it.
+func (c TieredCache) Get(key string) (string, bool) {
+	for i, tier := range c {
+		v, ok := tier.Get(key)
+		if !ok {
+			continue
+		}
+
+		for _, upper := range c[:i] {
+			upper.Set(key, v)
+		}
+
+		return v, true
+	}
+
+	return "", false
+}
+
+// Set sets the value to every tier.
+func (c TieredCache) Set(key, value string) {
+	for _, tier := range c {
+		tier.Set(key, value)
+	}
+}
+
+// Stat returns stats of every tier.
+func (c TieredCache) Stat() []CacheStat {
+	var result []CacheStat
+	for _, tier := range c {
+		result = append(result, tier.Stat()...)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package revisor
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/exp/slog"
+)
+
+func TestBoltCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c, err := NewBoltCache(slog.Default(), path, time.Hour, 2)
+	require.NoError(t, err)
+
+	c.Set("a", "1")
+	c.Set("b", "2")
+	c.Set("a", "3") // replacing doesn't evict anything
+	c.Set("c", "4") // evicts "b" as the oldest one
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "3", v)
+
+	assert.Equal(t, []CacheStat{{Name: "bolt", Hits: 1, Misses: 1, Added: 4, Evicted: 1, Size: 2}}, c.Stat())
+
+	// entries survive reopening
+	require.NoError(t, c.Close())
+	c, err = NewBoltCache(slog.Default(), path, time.Hour, 2)
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, 2, c.Stat()[0].Size)
+
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "4", v)
+
+	// expired entries are removed
+	c.ttl = time.Nanosecond
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Stat()[0].Size)
+}
+
+func TestBoltCache_Size(t *testing.T) {
+	c, err := NewBoltCache(slog.Default(), filepath.Join(t.TempDir(), "cache.db"), time.Hour, 3)
+	require.NoError(t, err)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), "v")
+		c.Set(strconv.Itoa(i/2), "v") // replaces the entry or restores the evicted one
+	}
+
+	keys := 0
+	require.NoError(t, c.db.View(func(tx *bolt.Tx) error {
+		keys = tx.Bucket([]byte(cacheEntriesBktName)).Stats().KeyN
+		return nil
+	}))
+	assert.Equal(t, 3, keys)
+	assert.Equal(t, keys, c.Stat()[0].Size)
+}
+
+func TestTieredCache(t *testing.T) {
+	bc, err := NewBoltCache(slog.Default(), filepath.Join(t.TempDir(), "cache.db"), 0, 0)
+	require.NoError(t, err)
+	defer bc.Close()
+
+	lru := NewLRUCache(10, 0)
+	c := TieredCache{lru, bc}
+
+	bc.Set("a", "1")
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	// value is promoted to the memory tier
+	v, ok = lru.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	c.Set("b", "2")
+	_, ok = bc.Get("b")
+	assert.True(t, ok)
+
+	stats := c.Stat()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "memory", stats[0].Name)
+	assert.Equal(t, "bolt", stats[1].Name)
+	assert.Equal(t, 2, stats[1].Size)
+}
+
+func TestLRUCache_TTL(t *testing.T) {
+	c := NewLRUCache(10, 50*time.Millisecond)
+
+	c.Set("a", "1")
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	time.Sleep(100 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
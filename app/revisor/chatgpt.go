@@ -2,26 +2,21 @@ package revisor
 
 import (
 	"context"
-	_ "embed"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
-	"text/template"
 
 	"github.com/Semior001/newsfeed/app/store"
 	"github.com/Semior001/newsfeed/pkg/logx"
-	cache "github.com/go-pkgz/expirable-cache/v2"
 	"github.com/go-pkgz/requester"
 	"github.com/go-pkgz/requester/middleware"
 	"github.com/sashabaranov/go-openai"
 	"golang.org/x/exp/slog"
+	"golang.org/x/sync/errgroup"
 )
 
-//go:embed data/prompt.tmpl
-var prompt string
-
-var promptTmpl = template.Must(template.New("prompt").Parse(prompt))
-
 //go:generate moq -out mock_openai_client.go . OpenAIClient
 
 // OpenAIClient is interface for OpenAI client with the possibility to mock it
@@ -29,16 +24,35 @@ type OpenAIClient interface {
 	CreateChatCompletion(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
 }
 
-// ChatGPT is a client to make requests to OpenAI chatgpt service.
+// ChatGPT is a client to make requests to OpenAI chatgpt service,
+// or any other service with OpenAI-compatible API.
 type ChatGPT struct {
 	log               *slog.Logger
 	cl                OpenAIClient
+	model             string
 	maxResponseTokens int
-	cache             cache.Cache[string, string]
+	prompts           *Prompts
+	promptName        string
+	// cache keeps summaries of chunks of long articles
+	cache Cache
+}
+
+// ChatGPTParams defines parameters to access OpenAI-compatible API.
+type ChatGPTParams struct {
+	Token string
+	// BaseURL of the API, e.g. http://localhost:11434/v1 for a local Ollama server,
+	// OpenAI API is used if empty.
+	BaseURL           string
+	Model             string
+	MaxResponseTokens int
+	// Prompts to pick the prompt from, only the embedded prompt is used if nil.
+	Prompts *Prompts
+	// Prompt is the name of the prompt, DefaultPromptName if empty.
+	Prompt string
 }
 
 // NewChatGPT creates new ChatGPT client.
-func NewChatGPT(lg *slog.Logger, cl http.Client, token string, maxResponseTokens int) *ChatGPT {
+func NewChatGPT(lg *slog.Logger, cl http.Client, params ChatGPTParams, cache Cache) *ChatGPT {
 	rstr := requester.New(cl,
 		middleware.MaxConcurrent(3),
 		logx.LoggingRoundTripper(lg, logx.RoundTripperOpts{
@@ -47,54 +61,193 @@ func NewChatGPT(lg *slog.Logger, cl http.Client, token string, maxResponseTokens
 		}),
 	)
 
-	config := openai.DefaultConfig(token)
+	config := openai.DefaultConfig(params.Token)
 	config.HTTPClient = rstr.Client()
+	if params.BaseURL != "" {
+		config.BaseURL = strings.TrimSuffix(params.BaseURL, "/")
+	}
 
 	client := openai.NewClientWithConfig(config)
 
 	svc := &ChatGPT{
 		log:               lg,
 		cl:                client,
-		maxResponseTokens: maxResponseTokens,
-		cache: cache.NewCache[string, string]().
-			WithLRU().
-			WithMaxKeys(100),
+		model:             params.Model,
+		maxResponseTokens: params.MaxResponseTokens,
+		prompts:           params.Prompts,
+		promptName:        params.Prompt,
+		cache:             cache,
 	}
 
 	return svc
 }
 
-// maxRequestTokens is a maximum number of tokens that can be sent to OpenAI.
+// maxRequestTokens is a maximum number of tokens that can be processed by
+// the model within a single request, including the response.
 const maxRequestTokens = 4097
 
+const (
+	// chunkOverlap is the number of paragraphs or sentences repeated at the start of
+	// the next chunk, to not lose the context on the chunk boundary.
+	chunkOverlap = 2
+	// maxConcurrentChunks is the number of chunks summarized at the same time.
+	maxConcurrentChunks = 3
+)
+
 // ErrTooManyTokens is returned when article is too long.
 var ErrTooManyTokens = fmt.Errorf("too many tokens")
 
-// CacheStat returns cache stats.
-func (s *ChatGPT) CacheStat() cache.Stats { return s.cache.Stat() }
+// TooManyTokensError contains the number of tokens in the request
+// that couldn't be processed by the model, it matches ErrTooManyTokens.
+type TooManyTokensError struct {
+	Tokens int
+	Limit  int
+}
+
+// Error returns the error message.
+func (e *TooManyTokensError) Error() string {
+	return fmt.Sprintf("too many tokens: %d, limit is %d", e.Tokens, e.Limit)
+}
+
+// Is returns true if the target is ErrTooManyTokens.
+func (e *TooManyTokensError) Is(target error) bool { return target == ErrTooManyTokens }
+
+// Name returns the model and the prompt version.
+func (s *ChatGPT) Name() string { return s.model + ":" + s.prompt().Version }
+
+// prompt returns the current version of the prompt, or the embedded one,
+// if there is no prompt with such name.
+func (s *ChatGPT) prompt() Prompt {
+	if s.prompts == nil {
+		return defaultPrompt
+	}
+
+	name := s.promptName
+	if name == "" {
+		name = DefaultPromptName
+	}
+
+	if p, ok := s.prompts.Get(name); ok {
+		return p
+	}
+
+	s.log.Warn("prompt not found, using the embedded one", slog.String("prompt", name))
+	return defaultPrompt
+}
 
 // BulletPoints shortens article.
+// Articles that don't fit into the model's context are split into chunks,
+// which are summarized separately, and then the summaries of chunks
+// are summarized into the final one.
 func (s *ChatGPT) BulletPoints(ctx context.Context, article store.Article) (string, error) {
-	if resp, ok := s.cache.Get(article.URL); ok {
+	return s.bulletPoints(ctx, s.prompt(), article)
+}
+
+func (s *ChatGPT) bulletPoints(ctx context.Context, p Prompt, article store.Article) (string, error) {
+	req, err := p.Execute(article)
+	if err != nil {
+		return "", err
+	}
+
+	if tokens := countTokens(req) + chatMessageOverhead; tokens <= s.promptBudget() {
+		return s.complete(ctx, req, tokens)
+	}
+
+	return s.mapReduce(ctx, p, article)
+}
+
+// promptBudget returns the number of tokens available for the prompt.
+func (s *ChatGPT) promptBudget() int { return maxRequestTokens - s.maxResponseTokens }
+
+func (s *ChatGPT) mapReduce(ctx context.Context, p Prompt, article store.Article) (string, error) {
+	overhead, err := p.Execute(store.Article{Title: article.Title, Preferences: article.Preferences})
+	if err != nil {
+		return "", err
+	}
+
+	overheadTokens := countTokens(overhead) + chatMessageOverhead
+	chunkBudget := s.promptBudget() - overheadTokens
+	if chunkBudget <= 0 {
+		return "", &TooManyTokensError{Tokens: overheadTokens, Limit: s.promptBudget()}
+	}
+
+	chunks := chunkText(article.Content, chunkBudget, chunkOverlap)
+	s.log.DebugCtx(ctx, "article is too long, summarizing by chunks",
+		slog.String("url", article.URL), slog.Int("chunks", len(chunks)))
+
+	partials := make([]string, len(chunks))
+	sema := make(chan struct{}, maxConcurrentChunks)
+
+	ewg, ectx := errgroup.WithContext(ctx)
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		ewg.Go(func() (err error) {
+			select {
+			case sema <- struct{}{}:
+			case <-ectx.Done():
+				return ectx.Err()
+			}
+			defer func() { <-sema }()
+
+			if partials[i], err = s.summarizeChunk(ectx, p, article, chunk); err != nil {
+				return fmt.Errorf("summarize chunk %d: %w", i, err)
+			}
+			return nil
+		})
+	}
+
+	if err = ewg.Wait(); err != nil {
+		return "", err
+	}
+
+	reduced := article
+	reduced.Content = strings.Join(partials, "\n")
+
+	// summaries of chunks must be shorter than the article itself,
+	// otherwise we would never finish
+	if tokens := countTokens(reduced.Content); tokens >= countTokens(article.Content) {
+		return "", &TooManyTokensError{Tokens: tokens + overheadTokens, Limit: s.promptBudget()}
+	}
+
+	return s.bulletPoints(ctx, p, reduced)
+}
+
+func (s *ChatGPT) summarizeChunk(ctx context.Context, p Prompt, article store.Article, chunk string) (string, error) {
+	h := sha256.Sum256([]byte(article.Title + "\n" + chunk))
+	key := s.model + ":" + p.Version + ":" + article.Preferences.Key() + ":chunk:" + hex.EncodeToString(h[:16])
+
+	if resp, ok := s.cache.Get(key); ok {
 		return resp, nil
 	}
 
-	buf := &strings.Builder{}
+	article.Content = chunk
+	req, err := p.Execute(article)
+	if err != nil {
+		return "", err
+	}
 
-	if err := promptTmpl.Execute(buf, article); err != nil {
-		return "", fmt.Errorf("build request: %w", err)
+	resp, err := s.complete(ctx, req, countTokens(req)+chatMessageOverhead)
+	if err != nil {
+		return "", err
 	}
 
-	totalTokens := strings.Count(buf.String(), " ") + 1
-	if totalTokens > maxRequestTokens {
-		return "", ErrTooManyTokens
+	s.cache.Set(key, resp)
+	return resp, nil
+}
+
+// complete sends the prompt to the model, the response is limited
+// to the rest of the model's context, but not more than maxResponseTokens.
+func (s *ChatGPT) complete(ctx context.Context, prompt string, promptTokens int) (string, error) {
+	maxTokens := maxRequestTokens - promptTokens
+	if s.maxResponseTokens > 0 && s.maxResponseTokens < maxTokens {
+		maxTokens = s.maxResponseTokens
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:     openai.GPT3Dot5Turbo,
-		MaxTokens: s.maxResponseTokens,
+		Model:     s.model,
+		MaxTokens: maxTokens,
 		Messages: []openai.ChatCompletionMessage{
-			{Role: openai.ChatMessageRoleUser, Content: buf.String()},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
 	}
 
@@ -107,7 +260,5 @@ func (s *ChatGPT) BulletPoints(ctx context.Context, article store.Article) (stri
 		return "", fmt.Errorf("no choices in response")
 	}
 
-	result := resp.Choices[0].Message.Content
-	s.cache.Set(article.URL, result, 0)
-	return result, nil
+	return resp.Choices[0].Message.Content, nil
 }
@@ -4,10 +4,11 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Semior001/newsfeed/app/store"
-	cache "github.com/go-pkgz/expirable-cache/v2"
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,7 +21,7 @@ var chatGPTRequest string
 func TestChatGPT_Shorten(t *testing.T) {
 	cl := &ChatGPT{
 		log:   slog.Default(),
-		cache: cache.NewCache[string, string](),
+		model: openai.GPT3Dot5Turbo,
 		cl: &OpenAIClientMock{
 			CreateChatCompletionFunc: func(
 				ctx context.Context,
@@ -54,3 +55,48 @@ func TestChatGPT_Shorten(t *testing.T) {
 
 	assert.Equal(t, "shortened content", resp)
 }
+
+func TestChatGPT_BulletPoints_MapReduce(t *testing.T) {
+	sb := &strings.Builder{}
+	for i := 0; i < 600; i++ {
+		fmt.Fprintf(sb, "Sentence number %d is long enough to take ten tokens. ", i)
+	}
+
+	mock := &OpenAIClientMock{
+		CreateChatCompletionFunc: func(
+			ctx context.Context,
+			req openai.ChatCompletionRequest,
+		) (openai.ChatCompletionResponse, error) {
+			assert.LessOrEqual(t, countTokens(req.Messages[0].Content)+chatMessageOverhead+req.MaxTokens, maxRequestTokens)
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{
+					Message: openai.ChatCompletionMessage{Content: "- point"},
+				}},
+			}, nil
+		},
+	}
+
+	cl := &ChatGPT{
+		log:               slog.Default(),
+		model:             openai.GPT3Dot5Turbo,
+		cl:                mock,
+		maxResponseTokens: 1000,
+		cache:             NewLRUCache(100, 0),
+	}
+
+	article := store.Article{Title: "Long read", Content: sb.String()}
+
+	resp, err := cl.BulletPoints(context.Background(), article)
+	require.NoError(t, err)
+	assert.Equal(t, "- point", resp)
+
+	// several chunks and the final summary
+	calls := mock.CreateChatCompletionCalls()
+	require.Greater(t, len(calls), 2)
+	assert.Contains(t, calls[len(calls)-1].ChatCompletionRequest.Messages[0].Content, "- point\n- point")
+
+	// summaries of chunks are cached
+	_, err = cl.BulletPoints(context.Background(), article)
+	require.NoError(t, err)
+	assert.Len(t, mock.CreateChatCompletionCalls(), len(calls)+1)
+}
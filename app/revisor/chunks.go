@@ -0,0 +1,141 @@
+package revisor
+
+import (
+	"regexp"
+	"strings"
+)
+
+var paragraphRe = regexp.MustCompile(`\n\s*\n`)
+
+// chunkPart is a paragraph or, if the paragraph doesn't fit into a chunk,
+// a sentence of it.
+type chunkPart struct {
+	text      string
+	paragraph int
+}
+
+// chunkText splits text into chunks of at most maxTokens tokens, packing whole
+// paragraphs, each chunk starts with the last overlap parts of the previous one.
+// Paragraphs longer than maxTokens are split on sentence boundaries, sentences
+// longer than maxTokens are split into several parts.
+func chunkText(text string, maxTokens, overlap int) []string {
+	var parts []chunkPart
+	for i, paragraph := range splitParagraphs(text) {
+		if countTokens(paragraph) <= maxTokens {
+			parts = append(parts, chunkPart{text: paragraph, paragraph: i})
+			continue
+		}
+
+		for _, sentence := range splitSentences(paragraph) {
+			for _, s := range splitLong(sentence, maxTokens) {
+				parts = append(parts, chunkPart{text: s, paragraph: i})
+			}
+		}
+	}
+
+	var (
+		chunks  []string
+		current []chunkPart
+		tokens  int
+	)
+
+	for _, part := range parts {
+		n := countTokens(part.text)
+		if tokens+n > maxTokens && len(current) > 0 {
+			chunks = append(chunks, joinParts(current))
+
+			// keep the tail of the previous chunk, as long as it leaves space for the new part
+			keep := overlap
+			if keep > len(current) {
+				keep = len(current)
+			}
+			current = append([]chunkPart{}, current[len(current)-keep:]...)
+			tokens = countTokens(joinParts(current))
+			for len(current) > 0 && tokens+n > maxTokens {
+				current = current[1:]
+				tokens = countTokens(joinParts(current))
+			}
+			if len(current) == 0 {
+				tokens = 0
+			}
+		}
+
+		current = append(current, part)
+		tokens += n
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, joinParts(current))
+	}
+
+	return chunks
+}
+
+// splitParagraphs splits the text on blank lines, empty paragraphs are dropped.
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	for _, p := range paragraphRe.Split(text, -1) {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
+
+// joinParts joins sentences of the same paragraph with a space
+// and paragraphs with a blank line.
+func joinParts(parts []chunkPart) string {
+	sb := &strings.Builder{}
+	for i, part := range parts {
+		switch {
+		case i == 0:
+		case part.paragraph == parts[i-1].paragraph:
+			_ = sb.WriteByte(' ')
+		default:
+			_, _ = sb.WriteString("\n\n")
+		}
+		_, _ = sb.WriteString(part.text)
+	}
+	return sb.String()
+}
+
+// splitLong splits the text into parts of at most maxTokens tokens,
+// preferably by spaces.
+func splitLong(text string, maxTokens int) []string {
+	if countTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	var parts []string
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > 0 {
+		// binary search for the longest prefix that fits
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if countTokens(string(runes[:mid])) <= maxTokens {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+
+		// don't cut the word in the middle, if possible
+		end := lo
+		if end < len(runes) && runes[end] != ' ' {
+			for i := end - 1; i > 0; i-- {
+				if runes[i] == ' ' {
+					end = i
+					break
+				}
+			}
+		}
+
+		if part := strings.TrimSpace(string(runes[:end])); part != "" {
+			parts = append(parts, part)
+		}
+		runes = []rune(strings.TrimLeft(string(runes[end:]), " "))
+	}
+
+	return parts
+}
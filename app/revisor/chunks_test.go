@@ -0,0 +1,32 @@
+package revisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkText(t *testing.T) {
+	text := "One two three. Four five six. Seven eight nine. Ten eleven twelve."
+
+	assert.Equal(t, []string{
+		"One two three. Four five six.",
+		"Four five six. Seven eight nine.",
+		"Seven eight nine. Ten eleven twelve.",
+	}, chunkText(text, 8, 1))
+
+	assert.Equal(t, []string{text}, chunkText(text, 100, 2))
+
+	// sentences longer than the limit are split by words
+	chunks := chunkText(strings.Repeat("word ", 10), 4, 0)
+	assert.Equal(t, []string{"word word word word", "word word word word", "word word"}, chunks)
+
+	// whole paragraphs are packed, only the long one is split into sentences
+	text = "First paragraph.\n\nSecond one.\n \nThird paragraph is long. It has two sentences."
+	assert.Equal(t, []string{
+		"First paragraph.\n\nSecond one.",
+		"Third paragraph is long.",
+		"It has two sentences.",
+	}, chunkText(text, 7, 0))
+}
@@ -0,0 +1,252 @@
+package revisor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Semior001/newsfeed/app/store"
+)
+
+// ErrNoContent is returned when the article has no text to summarize.
+var ErrNoContent = fmt.Errorf("no content to summarize")
+
+// Extractive makes a summary from the most representative sentences
+// of the article, it doesn't need any network access and is deterministic.
+//
+// Sentences are ranked with TextRank: each sentence is a node of a graph,
+// weighted by the cosine similarity of TF-IDF vectors of the sentences,
+// so the sentences that have the most in common with the rest
+// of the article get the highest rank.
+type Extractive struct {
+	maxBulletPoints int
+}
+
+// NewExtractive creates new Extractive summarizer.
+func NewExtractive(maxBulletPoints int) *Extractive {
+	return &Extractive{maxBulletPoints: maxBulletPoints}
+}
+
+// Name returns the name of the summarizer with the number of bullet points,
+// as it changes the summary. There is no colon in the name, as the part
+// after it is taken for the version of the prompt, see store.Rating.
+func (e *Extractive) Name() string { return fmt.Sprintf("extractive/%d", e.maxBulletPoints) }
+
+// BulletPoints returns the most relevant sentences as bullet points,
+// in the order they appear in the article.
+func (e *Extractive) BulletPoints(_ context.Context, article store.Article) (string, error) {
+	sentences := splitSentences(article.Content)
+	if len(sentences) == 0 {
+		return "", ErrNoContent
+	}
+
+	vectors := tfidf(append([]string{article.Title}, sentences...))
+	title, vectors := vectors[0], vectors[1:]
+
+	ranks := textRank(vectors)
+
+	type scored struct {
+		idx   int
+		score float64
+	}
+
+	scores := make([]scored, len(sentences))
+	for i := range sentences {
+		// sentences, that are close to the title, are most likely the key ones
+		scores[i] = scored{idx: i, score: ranks[i] * (1 + cosine(title, vectors[i]))}
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+
+	n := e.maxBulletPoints
+	if n > len(scores) {
+		n = len(scores)
+	}
+
+	picked := make([]int, n)
+	for i := range picked {
+		picked[i] = scores[i].idx
+	}
+	sort.Ints(picked)
+
+	sb := &strings.Builder{}
+	for i, idx := range picked {
+		if i > 0 {
+			_, _ = sb.WriteString("\n")
+		}
+		_, _ = sb.WriteString("- " + sentences[idx])
+	}
+
+	return sb.String(), nil
+}
+
+// tfidf returns TF-IDF vectors of the documents.
+func tfidf(docs []string) []map[string]float64 {
+	df := map[string]int{}
+	tfs := make([]map[string]float64, len(docs))
+	for i, doc := range docs {
+		tfs[i] = map[string]float64{}
+		for _, w := range words(doc) {
+			if tfs[i][w] == 0 {
+				df[w]++
+			}
+			tfs[i][w]++
+		}
+	}
+
+	for _, tf := range tfs {
+		for w := range tf {
+			tf[w] *= math.Log(float64(len(docs))/float64(df[w])) + 1
+		}
+	}
+
+	return tfs
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for w, x := range a {
+		dot += x * b[w]
+		na += x * x
+	}
+	for _, y := range b {
+		nb += y * y
+	}
+
+	if na == 0 || nb == 0 {
+		return 0
+	}
+
+	return dot / math.Sqrt(na*nb)
+}
+
+const (
+	textRankDamping    = 0.85
+	textRankIterations = 50
+	textRankEpsilon    = 1e-6
+)
+
+// textRank returns ranks of the nodes of the graph with
+// edges weighted by the similarity of the vectors.
+func textRank(vectors []map[string]float64) []float64 {
+	n := len(vectors)
+
+	sim := make([][]float64, n)
+	outWeight := make([]float64, n)
+	for i := range sim {
+		sim[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			sim[i][j] = cosine(vectors[i], vectors[j])
+			sim[j][i] = sim[i][j]
+			outWeight[i] += sim[i][j]
+			outWeight[j] += sim[i][j]
+		}
+	}
+
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = 1
+	}
+
+	next := make([]float64, n)
+	for iter := 0; iter < textRankIterations; iter++ {
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if sim[j][i] == 0 {
+					continue
+				}
+				sum += sim[j][i] / outWeight[j] * ranks[j]
+			}
+			next[i] = (1 - textRankDamping) + textRankDamping*sum
+			delta += math.Abs(next[i] - ranks[i])
+		}
+
+		ranks, next = next, ranks
+		if delta < textRankEpsilon {
+			break
+		}
+	}
+
+	return ranks
+}
+
+// splitSentences splits text by sentence terminators, followed by a space
+// and an upper-case letter or a digit, or glued to the next sentence.
+func splitSentences(text string) []string {
+	var result []string
+
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !strings.ContainsRune(".!?…", runes[i]) {
+			continue
+		}
+
+		// consume repeated terminators and closing quotes, e.g. `?!` or `."`
+		end := i + 1
+		for end < len(runes) && strings.ContainsRune(".!?…\"»)”", runes[end]) {
+			end++
+		}
+
+		spaced := end < len(runes)-1 && unicode.IsSpace(runes[end]) &&
+			(unicode.IsUpper(runes[end+1]) || unicode.IsDigit(runes[end+1]) || strings.ContainsRune("\"«“", runes[end+1]))
+		// extracted text often loses the space between paragraphs, e.g. "end.Start"
+		glued := i > 0 && end < len(runes) && unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[end])
+
+		if spaced || glued {
+			if s := strings.TrimSpace(string(runes[start:end])); s != "" {
+				result = append(result, s)
+			}
+			start = end
+		}
+
+		i = end - 1
+	}
+
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// words returns lower-cased words of the text without stop words.
+func words(text string) []string {
+	var result []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// stopWords contain the most frequent English and Russian words,
+// that don't carry any meaning on their own.
+var stopWords = func() map[string]bool {
+	res := map[string]bool{}
+	for _, w := range strings.Fields(`
+		the and for are but not you all any can had her was one our out has him his how its may
+		new now old see two who did get she too use that with have this will your from they know
+		want been good much some time very when come here just like long make many more only over
+		such take than them well were what which their there would about could other these into
+		also after most then those while where being should because through
+		как что это все она так его только было еще уже для или при ему они
+		них был была были быть вот тот эти этот этой этого того тем чем кто где когда если
+		даже ведь нет нам вам вас нас мне меня тебя себя свой своей своих также которые который
+		которая которых после перед между через более менее очень может можно будет будут есть
+	`) {
+		res[w] = true
+	}
+	return res
+}()
@@ -0,0 +1,59 @@
+package revisor
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractive_BulletPoints(t *testing.T) {
+	var article store.Article
+	require.NoError(t, json.Unmarshal(articleContent, &article))
+
+	e := NewExtractive(4)
+
+	bp, err := e.BulletPoints(context.Background(), article)
+	require.NoError(t, err)
+
+	lines := strings.Split(bp, "\n")
+	require.Len(t, lines, 4)
+
+	prevIdx := -1
+	for _, line := range lines {
+		require.True(t, strings.HasPrefix(line, "- "), line)
+
+		// sentences are kept in the order of the article
+		idx := strings.Index(article.Content, strings.TrimPrefix(line, "- "))
+		require.Greater(t, idx, prevIdx, line)
+		prevIdx = idx
+	}
+
+	// the summary is deterministic
+	again, err := e.BulletPoints(context.Background(), article)
+	require.NoError(t, err)
+	assert.Equal(t, bp, again)
+
+	// the number of bullet points changes the summary
+	assert.Equal(t, "extractive/4", e.Name())
+	assert.NotEqual(t, e.Name(), NewExtractive(5).Name())
+
+	_, err = e.BulletPoints(context.Background(), store.Article{Title: "Empty", Content: " \n\t "})
+	assert.ErrorIs(t, err, ErrNoContent)
+}
+
+func TestSplitSentences(t *testing.T) {
+	assert.Equal(t, []string{
+		"Ветер 15-20 м/с.",
+		"В Петропавловске туман?!",
+		"\"Казгидромет\" предупреждает.",
+		"3 дня подряд… и т.д. без конца.",
+		"Пыльная буря.",
+		"На севере метель, сообщает Zakon.kz.",
+	}, splitSentences(`Ветер 15-20 м/с. В Петропавловске туман?! "Казгидромет" предупреждает. `+
+		`3 дня подряд… и т.д. без конца. Пыльная буря.На севере метель, сообщает Zakon.kz.`))
+}
@@ -0,0 +1,31 @@
+package revisor
+
+import "context"
+
+// Stage is a stage of processing of the article.
+type Stage string
+
+// Stages of processing of the article, in order.
+const (
+	StageFetching    Stage = "fetching"
+	StageExtracting  Stage = "extracting"
+	StageSummarizing Stage = "summarizing"
+)
+
+// ProgressFunc is called when the processing of the article
+// proceeds to the next stage.
+type ProgressFunc func(Stage)
+
+type progressKey struct{}
+
+// ContextWithProgress returns a context, that reports the progress
+// of processing to the given function.
+func ContextWithProgress(parent context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(parent, progressKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, stage Stage) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(stage)
+	}
+}
@@ -0,0 +1,241 @@
+package revisor
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"golang.org/x/exp/slog"
+)
+
+//go:embed data/prompt.tmpl
+var embeddedPrompt string
+
+// DefaultPromptName is the name of the embedded prompt,
+// a file with the same name in the prompts directory overrides it.
+const DefaultPromptName = "default"
+
+// promptExt is the extension of prompt files in the prompts directory.
+const promptExt = ".tmpl"
+
+var defaultPrompt = func() Prompt {
+	p, err := ParsePrompt(DefaultPromptName, "embedded", embeddedPrompt)
+	if err != nil {
+		panic(fmt.Sprintf("parse embedded prompt: %v", err))
+	}
+	return p
+}()
+
+// Prompt is a text/template that builds a request to the language model
+// from store.Article.
+type Prompt struct {
+	Name string
+	// Source is the path to the file of the prompt, or "embedded".
+	Source string
+	Text   string
+	// Version identifies the text of the prompt in cache keys,
+	// so that changing the prompt invalidates cached summaries.
+	Version string
+
+	tmpl *template.Template
+}
+
+// ParsePrompt parses the prompt and validates it against store.Article.
+func ParsePrompt(name, source, text string) (Prompt, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return Prompt{}, fmt.Errorf("parse template: %w", err)
+	}
+
+	// templates fail on execution if they refer to unknown fields
+	sample := store.Article{Title: "title", Content: "content", Preferences: store.Preferences{Style: store.StyleBullets}}
+	if err = tmpl.Execute(io.Discard, sample); err != nil {
+		return Prompt{}, fmt.Errorf("validate template: %w", err)
+	}
+
+	h := sha256.Sum256([]byte(text))
+
+	return Prompt{
+		Name:    name,
+		Source:  source,
+		Text:    text,
+		Version: hex.EncodeToString(h[:4]),
+		tmpl:    tmpl,
+	}, nil
+}
+
+// Execute builds the request for the article.
+func (p Prompt) Execute(article store.Article) (string, error) {
+	buf := &strings.Builder{}
+	if err := p.tmpl.Execute(buf, article); err != nil {
+		return "", fmt.Errorf("build request: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Prompts is a set of named prompts, loaded from *.tmpl files
+// in the directory, the name of the prompt is the name of the file
+// without extension. The embedded prompt is always available
+// as DefaultPromptName, unless it is overridden by a file.
+type Prompts struct {
+	log *slog.Logger
+	dir string
+
+	mu      sync.RWMutex
+	prompts map[string]Prompt
+	// modTimes of the loaded files, to detect changes
+	modTimes map[string]time.Time
+}
+
+// NewPrompts loads prompts from the directory, empty dir means
+// that only the embedded prompt is available.
+func NewPrompts(lg *slog.Logger, dir string) (*Prompts, error) {
+	p := &Prompts{
+		log:      lg,
+		dir:      dir,
+		prompts:  map[string]Prompt{DefaultPromptName: defaultPrompt},
+		modTimes: map[string]time.Time{},
+	}
+
+	if dir == "" {
+		return p, nil
+	}
+
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Get returns the prompt by name.
+func (p *Prompts) Get(name string) (Prompt, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	prompt, ok := p.prompts[name]
+	return prompt, ok
+}
+
+// List returns all prompts sorted by name.
+func (p *Prompts) List() []Prompt {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make([]Prompt, 0, len(p.prompts))
+	for _, prompt := range p.prompts {
+		result = append(result, prompt)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// Reload reads the directory and reloads changed files.
+// Invalid templates are skipped and the previously loaded version is kept,
+// the errors are returned together with the flag whether anything has changed.
+func (p *Prompts) Reload() (changed bool, err error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return false, fmt.Errorf("read prompts dir: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != promptExt {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), promptExt)
+		path := filepath.Join(p.dir, entry.Name())
+		seen[name] = true
+
+		// entries of symlinks, e.g. in mounted kubernetes config maps, don't have modtime
+		fi, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stat %s: %w", path, err))
+			continue
+		}
+
+		if prev, ok := p.modTimes[name]; ok && prev.Equal(fi.ModTime()) {
+			continue
+		}
+
+		bts, err := os.ReadFile(path) //nolint:gosec // the directory is set by the operator
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s: %w", path, err))
+			continue
+		}
+
+		// invalid template is not parsed again until the file is changed
+		p.modTimes[name] = fi.ModTime()
+
+		prompt, err := ParsePrompt(name, path, string(bts))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("prompt %s: %w", path, err))
+			continue
+		}
+
+		p.prompts[name] = prompt
+		changed = true
+	}
+
+	// removed files
+	for name := range p.modTimes {
+		if seen[name] {
+			continue
+		}
+
+		delete(p.modTimes, name)
+		delete(p.prompts, name)
+		if name == DefaultPromptName {
+			p.prompts[name] = defaultPrompt
+		}
+		changed = true
+	}
+
+	return changed, errors.Join(errs...)
+}
+
+// Watch reloads prompts on changes in the directory until context is dead.
+func (p *Prompts) Watch(ctx context.Context, interval time.Duration) error {
+	if p.dir == "" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		changed, err := p.Reload()
+		if err != nil {
+			p.log.WarnCtx(ctx, "failed to reload prompts", slog.Any("err", err))
+		}
+
+		if changed {
+			p.log.InfoCtx(ctx, "prompts reloaded", slog.Int("count", len(p.List())))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package revisor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/app/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestPrompts(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string, mtime time.Time) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(text), 0o600))
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("short.tmpl", "Summarize {{.Title}} in {{.LanguageName}}", start)
+	write("readme.md", "not a prompt", start)
+
+	p, err := NewPrompts(slog.Default(), dir)
+	require.NoError(t, err)
+
+	prompts := p.List()
+	require.Len(t, prompts, 2)
+	assert.Equal(t, DefaultPromptName, prompts[0].Name)
+	assert.Equal(t, "embedded", prompts[0].Source)
+	assert.Equal(t, "short", prompts[1].Name)
+
+	req, err := prompts[1].Execute(store.Article{Title: "news", Preferences: store.Preferences{Language: "en"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Summarize news in English", req)
+
+	// nothing changed
+	changed, err := p.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// invalid template keeps the previous version
+	write("short.tmpl", "Summarize {{.Unknown}}", start.Add(time.Minute))
+	changed, err = p.Reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	short, ok := p.Get("short")
+	require.True(t, ok)
+	assert.Equal(t, prompts[1].Version, short.Version)
+
+	// the embedded prompt may be overridden and restored
+	write("default.tmpl", "{{.Content}}", start)
+	changed, err = p.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	def, ok := p.Get(DefaultPromptName)
+	require.True(t, ok)
+	assert.Equal(t, "{{.Content}}", def.Text)
+	assert.NotEqual(t, defaultPrompt.Version, def.Version)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "default.tmpl")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "short.tmpl")))
+	changed, err = p.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []Prompt{defaultPrompt}, p.List())
+
+	// invalid templates are rejected on start
+	write("bad.tmpl", "{{.Title", start)
+	_, err = NewPrompts(slog.Default(), dir)
+	assert.Error(t, err)
+}
@@ -8,34 +8,58 @@ import (
 	"strings"
 
 	"github.com/Semior001/newsfeed/app/store"
-	cache "github.com/go-pkgz/expirable-cache/v2"
 	"golang.org/x/exp/slog"
 )
 
+// Summarizer makes a summary of the article.
+type Summarizer interface {
+	// Name identifies the summarizer and its settings in cache keys,
+	// so the name must change when the summaries would change.
+	Name() string
+	// BulletPoints returns the summary as a list of bullet points.
+	BulletPoints(ctx context.Context, article store.Article) (string, error)
+}
+
 // Service is a main application service.
 type Service struct {
-	log       *slog.Logger
-	cl        *http.Client
-	chatGPT   *ChatGPT
-	extractor Extractor
+	log        *slog.Logger
+	cl         *http.Client
+	summarizer Summarizer
+	fallback   Summarizer
+	extractor  Extractor
+	cache      Cache
 }
 
 // NewService creates new service.
-func NewService(lg *slog.Logger, cl *http.Client, chatGPT *ChatGPT, extractor Extractor) *Service {
+// Fallback summarizer is used when the main one fails, it may be nil.
+func NewService(
+	lg *slog.Logger,
+	cl *http.Client,
+	summarizer, fallback Summarizer,
+	extractor Extractor,
+	cache Cache,
+) *Service {
 	return &Service{
-		log:       lg,
-		cl:        cl,
-		chatGPT:   chatGPT,
-		extractor: extractor,
+		log:        lg,
+		cl:         cl,
+		summarizer: summarizer,
+		fallback:   fallback,
+		extractor:  extractor,
+		cache:      cache,
 	}
 }
 
-// GPTCacheStat returns cache stats.
-func (s *Service) GPTCacheStat() cache.Stats { return s.chatGPT.CacheStat() }
+// SummarizerName returns the name of the summarizer, that makes summaries now,
+// it changes with the prompt, when prompts are reloaded.
+func (s *Service) SummarizerName() string { return s.summarizer.Name() }
 
-// GetArticle shortens article.
-func (s *Service) GetArticle(ctx context.Context, u string) (store.Article, error) {
+// CacheStat returns stats of every tier of the summaries cache.
+func (s *Service) CacheStat() []CacheStat { return s.cache.Stat() }
+
+// GetArticle shortens article according to the preferences.
+func (s *Service) GetArticle(ctx context.Context, u string, prefs store.Preferences) (store.Article, error) {
 	s.log.DebugCtx(ctx, "aggregating article from", slog.String("url", u))
+	reportProgress(ctx, StageFetching)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
 	if err != nil {
@@ -57,16 +81,55 @@ func (s *Service) GetArticle(ctx context.Context, u string) (store.Article, erro
 		return store.Article{}, fmt.Errorf("bad status code: %d", resp.StatusCode)
 	}
 
+	reportProgress(ctx, StageExtracting)
 	article, err := s.extractor.Extract(resp.Body)
 	if err != nil {
 		return store.Article{}, fmt.Errorf("extract article: %w", err)
 	}
 	// remove trailing slash
 	article.URL = strings.TrimSuffix(u, "/")
+	article.Preferences = prefs
 
-	if article.BulletPoints, err = s.chatGPT.BulletPoints(ctx, article); err != nil {
-		return store.Article{}, fmt.Errorf("get bullet points: %w", err)
+	reportProgress(ctx, StageSummarizing)
+	if err = s.summarize(ctx, &article); err != nil {
+		return store.Article{}, err
 	}
 
 	return article, nil
 }
+
+func (s *Service) summarize(ctx context.Context, article *store.Article) (err error) {
+	article.Summarizer = s.summarizer.Name()
+	_, article.Extractive = s.summarizer.(*Extractive)
+
+	key := article.Summarizer + ":" + article.Preferences.Key() + ":" + store.NormalizeURL(article.URL)
+	if bp, ok := s.cache.Get(key); ok {
+		article.BulletPoints = bp
+		return nil
+	}
+
+	article.BulletPoints, err = s.summarizer.BulletPoints(ctx, *article)
+	if err == nil {
+		s.cache.Set(key, article.BulletPoints)
+		return nil
+	}
+
+	if s.fallback == nil || ctx.Err() != nil {
+		return fmt.Errorf("get bullet points: %w", err)
+	}
+
+	s.log.WarnCtx(ctx, "summarizer failed, falling back",
+		slog.String("summarizer", article.Summarizer),
+		slog.String("fallback", s.fallback.Name()),
+		slog.Any("err", err))
+
+	// fallback results are not cached, so that the main summarizer
+	// is tried again next time
+	article.Summarizer = s.fallback.Name()
+	_, article.Extractive = s.fallback.(*Extractive)
+	if article.BulletPoints, err = s.fallback.BulletPoints(ctx, *article); err != nil {
+		return fmt.Errorf("get bullet points with fallback: %w", err)
+	}
+
+	return nil
+}
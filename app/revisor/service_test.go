@@ -3,12 +3,13 @@ package revisor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/Semior001/newsfeed/app/store"
-	cache "github.com/go-pkgz/expirable-cache/v2"
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,10 +25,11 @@ func TestService_GetArticle(t *testing.T) {
 	defer ts.Close()
 
 	svc := Service{
-		log: slog.Default(),
-		cl:  ts.Client(),
-		chatGPT: &ChatGPT{
-			cache: cache.NewCache[string, string](),
+		log:   slog.Default(),
+		cl:    ts.Client(),
+		cache: NewLRUCache(100, 0),
+		summarizer: &ChatGPT{
+			model: openai.GPT3Dot5Turbo,
 			log:   slog.Default(),
 			cl: &OpenAIClientMock{
 				CreateChatCompletionFunc: func(
@@ -56,7 +58,7 @@ func TestService_GetArticle(t *testing.T) {
 		extractor: Extractor{},
 	}
 
-	article, err := svc.GetArticle(context.Background(), ts.URL)
+	article, err := svc.GetArticle(context.Background(), ts.URL, store.Preferences{})
 	require.NoError(t, err)
 
 	var expected store.Article
@@ -64,6 +66,79 @@ func TestService_GetArticle(t *testing.T) {
 	require.NoError(t, err)
 	expected.BulletPoints = "shortened content"
 	expected.URL = ts.URL
+	expected.Summarizer = openai.GPT3Dot5Turbo + ":" + defaultPrompt.Version
 
 	assert.Equal(t, expected, article)
 }
+
+func TestService_GetArticle_Fallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(articleHTML)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	svc := NewService(slog.Default(), ts.Client(),
+		&ChatGPT{
+			model: openai.GPT3Dot5Turbo,
+			log:   slog.Default(),
+			cl: &OpenAIClientMock{
+				CreateChatCompletionFunc: func(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+					return openai.ChatCompletionResponse{}, errors.New("service unavailable")
+				},
+			},
+		},
+		NewExtractive(3),
+		Extractor{},
+		NewLRUCache(100, 0),
+	)
+
+	article, err := svc.GetArticle(context.Background(), ts.URL, store.Preferences{})
+	require.NoError(t, err)
+
+	assert.True(t, article.Extractive)
+	assert.Equal(t, "extractive/3", article.Summarizer)
+	assert.Len(t, strings.Split(article.BulletPoints, "\n"), 3)
+
+	// fallback summaries are not cached
+	assert.Equal(t, 0, svc.CacheStat()[0].Size)
+}
+
+func TestService_GetArticle_Preferences(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(articleHTML)
+		require.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	mock := &OpenAIClientMock{
+		CreateChatCompletionFunc: func(context.Context, openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+			return openai.ChatCompletionResponse{
+				Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "summary"}}},
+			}, nil
+		},
+	}
+
+	svc := NewService(slog.Default(), ts.Client(),
+		&ChatGPT{model: openai.GPT3Dot5Turbo, log: slog.Default(), cl: mock, maxResponseTokens: 1000},
+		nil, Extractor{}, NewLRUCache(100, 0),
+	)
+
+	prefs := store.Preferences{Language: "en", Style: store.StyleTLDR}
+	article, err := svc.GetArticle(context.Background(), ts.URL, prefs)
+	require.NoError(t, err)
+	assert.Equal(t, prefs, article.Preferences)
+
+	calls := mock.CreateChatCompletionCalls()
+	require.Len(t, calls, 1)
+	prompt := calls[0].ChatCompletionRequest.Messages[0].Content
+	assert.Contains(t, prompt, "«English»")
+	assert.Contains(t, prompt, "одним предложением")
+
+	// the same preferences hit the cache, other preferences don't
+	_, err = svc.GetArticle(context.Background(), ts.URL, prefs)
+	require.NoError(t, err)
+	_, err = svc.GetArticle(context.Background(), ts.URL, store.Preferences{})
+	require.NoError(t, err)
+	assert.Len(t, mock.CreateChatCompletionCalls(), 2)
+}
@@ -0,0 +1,36 @@
+package revisor
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// chatMessageOverhead is the number of tokens the API adds to every request
+// with a single message: role and message separators, and the reply primer.
+const chatMessageOverhead = 7
+
+var (
+	encoderOnce sync.Once
+	encoder     *tiktoken.Tiktoken
+)
+
+// countTokens returns the number of tokens in the text in terms of cl100k
+// vocabulary, used by gpt-3.5-turbo and gpt-4 models.
+// The vocabulary is embedded into the binary and loaded on the first call.
+func countTokens(s string) int {
+	encoderOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+
+		enc, err := tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+		if err != nil {
+			// the vocabulary is embedded, so this can't happen unless the binary is broken
+			panic(fmt.Sprintf("load cl100k vocabulary: %v", err))
+		}
+		encoder = enc
+	})
+
+	return len(encoder.EncodeOrdinary(s))
+}
@@ -0,0 +1,18 @@
+package revisor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountTokens(t *testing.T) {
+	assert.Equal(t, 2, countTokens("hello world"))
+	assert.Equal(t, 0, countTokens(""))
+
+	// cyrillic and CJK texts take much more tokens than words
+	ru := "Буллит поинты необходимо написать на русском"
+	assert.Greater(t, countTokens(ru), 2*len(strings.Fields(ru)))
+	assert.Greater(t, countTokens("今天天气很好"), 1)
+}
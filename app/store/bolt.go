@@ -2,14 +2,40 @@ package store
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"path"
+	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
-const usersBktName = "users"
+const (
+	usersBktName     = "users"
+	feedsBktName     = "feeds"
+	feedItemsBktName = "feed_items"
+
+	// user -> feeds and feed -> users indices of subscriptions,
+	// each top-level key contains a nested bucket
+	subscriptionsBktName = "subscriptions"
+	subscribersBktName   = "subscribers"
+
+	// user -> articles waiting for the next digest
+	pendingBktName = "pending"
+
+	// normalized url -> article
+	articlesBktName = "articles"
+	// short id -> normalized url of the article
+	articleIDsBktName = "article_ids"
+	// user -> articles received by the user
+	historyBktName = "history"
+	// normalized url, summarizer and user -> rating of the summary
+	ratingsBktName = "ratings"
+	// summarizer id -> name of the summarizer of saved articles
+	summarizersBktName = "summarizers"
+)
 
 // Bolt is a storage that uses BoltDB as a backend.
 type Bolt struct {
@@ -24,7 +50,12 @@ func NewBolt(dir string) (*Bolt, error) {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		for _, name := range []string{usersBktName} {
+		for _, name := range []string{
+			usersBktName, feedsBktName, feedItemsBktName,
+			subscriptionsBktName, subscribersBktName, pendingBktName,
+			articlesBktName, articleIDsBktName, historyBktName,
+			ratingsBktName, summarizersBktName,
+		} {
 			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
 				return fmt.Errorf("create top-level bucket %s: %w", name, err)
 			}
@@ -108,7 +139,42 @@ func (b *Bolt) Get(_ context.Context, id string) (u User, err error) {
 	return u, nil
 }
 
-// Delete removes user from storage.
+// SetLastDigestAt updates the time of the last digest of the user.
+func (b *Bolt) SetLastDigestAt(_ context.Context, chatID string, t time.Time) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(usersBktName))
+
+		bts := bkt.Get([]byte(chatID))
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		var u User
+		if err := json.Unmarshal(bts, &u); err != nil {
+			return fmt.Errorf("unmarshal user: %w", err)
+		}
+
+		u.LastDigestAt = t
+
+		bts, err := json.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("marshal user: %w", err)
+		}
+
+		if err = bkt.Put([]byte(chatID), bts); err != nil {
+			return fmt.Errorf("put user to storage: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes user, its subscriptions, pending articles and history from storage.
 func (b *Bolt) Delete(_ context.Context, id string) error {
 	err := b.db.Update(func(tx *bolt.Tx) error {
 		bkt := tx.Bucket([]byte(usersBktName))
@@ -117,6 +183,437 @@ func (b *Bolt) Delete(_ context.Context, id string) error {
 			return fmt.Errorf("remove: %w", err)
 		}
 
+		for _, name := range []string{pendingBktName, historyBktName} {
+			if parent := tx.Bucket([]byte(name)); parent.Bucket([]byte(id)) != nil {
+				if err := parent.DeleteBucket([]byte(id)); err != nil {
+					return fmt.Errorf("remove %s of user: %w", name, err)
+				}
+			}
+		}
+
+		subs := tx.Bucket([]byte(subscriptionsBktName))
+		feeds := subs.Bucket([]byte(id))
+		if feeds == nil {
+			return nil
+		}
+
+		err := feeds.ForEach(func(feedURL, _ []byte) error {
+			if bkt := tx.Bucket([]byte(subscribersBktName)).Bucket(feedURL); bkt != nil {
+				return bkt.Delete([]byte(id))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("remove user from subscribers: %w", err)
+		}
+
+		if err = subs.DeleteBucket([]byte(id)); err != nil {
+			return fmt.Errorf("remove subscriptions: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// PutFeed puts feed to storage.
+func (b *Bolt) PutFeed(_ context.Context, f Feed) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(feedsBktName))
+
+		bts, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("marshal feed: %w", err)
+		}
+
+		if err := bkt.Put([]byte(f.URL), bts); err != nil {
+			return fmt.Errorf("put feed to storage: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// GetFeed returns feed from storage.
+func (b *Bolt) GetFeed(_ context.Context, url string) (f Feed, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(feedsBktName))
+
+		bts := bkt.Get([]byte(url))
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		if err := json.Unmarshal(bts, &f); err != nil {
+			return fmt.Errorf("unmarshal feed: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Feed{}, fmt.Errorf("view storage: %w", err)
+	}
+
+	return f, nil
+}
+
+// ListFeeds returns all feeds from storage.
+func (b *Bolt) ListFeeds(context.Context) ([]Feed, error) {
+	var result []Feed
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(feedsBktName))
+		err := bkt.ForEach(func(k, v []byte) error {
+			var f Feed
+			if err := json.Unmarshal(v, &f); err != nil {
+				return fmt.Errorf("unmarshal feed %s: %w", k, err)
+			}
+			result = append(result, f)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("foreach: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("view storage: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteFeed removes feed, its known items and subscriptions to it from storage.
+func (b *Bolt) DeleteFeed(_ context.Context, url string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(feedsBktName)).Delete([]byte(url)); err != nil {
+			return fmt.Errorf("remove feed: %w", err)
+		}
+
+		items := tx.Bucket([]byte(feedItemsBktName))
+		if items.Bucket([]byte(url)) != nil {
+			if err := items.DeleteBucket([]byte(url)); err != nil {
+				return fmt.Errorf("remove feed items: %w", err)
+			}
+		}
+
+		subscribers := tx.Bucket([]byte(subscribersBktName))
+		users := subscribers.Bucket([]byte(url))
+		if users == nil {
+			return nil
+		}
+
+		err := users.ForEach(func(chatID, _ []byte) error {
+			if bkt := tx.Bucket([]byte(subscriptionsBktName)).Bucket(chatID); bkt != nil {
+				return bkt.Delete([]byte(url))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("remove feed from subscriptions: %w", err)
+		}
+
+		if err = subscribers.DeleteBucket([]byte(url)); err != nil {
+			return fmt.Errorf("remove subscribers: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateFeedItems replaces the set of known item IDs of the feed
+// and returns IDs that were not known before.
+func (b *Bolt) UpdateFeedItems(_ context.Context, feedURL string, ids []string) (fresh []string, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		items := tx.Bucket([]byte(feedItemsBktName))
+
+		known := map[string]bool{}
+		if bkt := items.Bucket([]byte(feedURL)); bkt != nil {
+			err := bkt.ForEach(func(k, _ []byte) error {
+				known[string(k)] = true
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("foreach: %w", err)
+			}
+
+			if err = items.DeleteBucket([]byte(feedURL)); err != nil {
+				return fmt.Errorf("remove outdated items: %w", err)
+			}
+		}
+
+		bkt, err := items.CreateBucket([]byte(feedURL))
+		if err != nil {
+			return fmt.Errorf("create items bucket: %w", err)
+		}
+
+		for _, id := range ids {
+			if !known[id] {
+				fresh = append(fresh, id)
+				known[id] = true // to not report duplicates twice
+			}
+
+			if err := bkt.Put([]byte(id), []byte{}); err != nil {
+				return fmt.Errorf("put item %s: %w", id, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("update storage: %w", err)
+	}
+
+	return fresh, nil
+}
+
+// Subscribe subscribes user to the feed.
+func (b *Bolt) Subscribe(_ context.Context, chatID, feedURL string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		feeds, err := tx.Bucket([]byte(subscriptionsBktName)).CreateBucketIfNotExists([]byte(chatID))
+		if err != nil {
+			return fmt.Errorf("make subscriptions bucket: %w", err)
+		}
+
+		users, err := tx.Bucket([]byte(subscribersBktName)).CreateBucketIfNotExists([]byte(feedURL))
+		if err != nil {
+			return fmt.Errorf("make subscribers bucket: %w", err)
+		}
+
+		if err = feeds.Put([]byte(feedURL), []byte{}); err != nil {
+			return fmt.Errorf("put subscription: %w", err)
+		}
+
+		if err = users.Put([]byte(chatID), []byte{}); err != nil {
+			return fmt.Errorf("put subscriber: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// Unsubscribe removes user's subscription to the feed.
+func (b *Bolt) Unsubscribe(_ context.Context, chatID, feedURL string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if feeds := tx.Bucket([]byte(subscriptionsBktName)).Bucket([]byte(chatID)); feeds != nil {
+			if err := feeds.Delete([]byte(feedURL)); err != nil {
+				return fmt.Errorf("remove subscription: %w", err)
+			}
+		}
+
+		if users := tx.Bucket([]byte(subscribersBktName)).Bucket([]byte(feedURL)); users != nil {
+			if err := users.Delete([]byte(chatID)); err != nil {
+				return fmt.Errorf("remove subscriber: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// ListSubscriptions returns feeds the user is subscribed to, sorted by URL.
+func (b *Bolt) ListSubscriptions(_ context.Context, chatID string) ([]Feed, error) {
+	var result []Feed
+	err := b.db.View(func(tx *bolt.Tx) error {
+		subs := tx.Bucket([]byte(subscriptionsBktName)).Bucket([]byte(chatID))
+		if subs == nil {
+			return nil
+		}
+
+		feeds := tx.Bucket([]byte(feedsBktName))
+		err := subs.ForEach(func(k, _ []byte) error {
+			f := Feed{URL: string(k)}
+			if bts := feeds.Get(k); bts != nil {
+				if err := json.Unmarshal(bts, &f); err != nil {
+					return fmt.Errorf("unmarshal feed %s: %w", k, err)
+				}
+			}
+			result = append(result, f)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("foreach: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("view storage: %w", err)
+	}
+	return result, nil
+}
+
+// ListSubscribers returns users subscribed to the feed.
+func (b *Bolt) ListSubscribers(_ context.Context, feedURL string) ([]User, error) {
+	var result []User
+	err := b.db.View(func(tx *bolt.Tx) error {
+		subs := tx.Bucket([]byte(subscribersBktName)).Bucket([]byte(feedURL))
+		if subs == nil {
+			return nil
+		}
+
+		users := tx.Bucket([]byte(usersBktName))
+		err := subs.ForEach(func(k, _ []byte) error {
+			bts := users.Get(k)
+			if bts == nil {
+				return nil
+			}
+
+			var u User
+			if err := json.Unmarshal(bts, &u); err != nil {
+				return fmt.Errorf("unmarshal user %s: %w", k, err)
+			}
+			result = append(result, u)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("foreach: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("view storage: %w", err)
+	}
+	return result, nil
+}
+
+// AddPending queues the article for the user's next digest.
+func (b *Bolt) AddPending(_ context.Context, chatID string, a Article) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.Bucket([]byte(pendingBktName)).CreateBucketIfNotExists([]byte(chatID))
+		if err != nil {
+			return fmt.Errorf("make pending bucket: %w", err)
+		}
+
+		seq, err := bkt.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+
+		bts, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("marshal article: %w", err)
+		}
+
+		// big-endian keys keep articles in the order they were added
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		if err = bkt.Put(key, bts); err != nil {
+			return fmt.Errorf("put pending article: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// PopPending returns and removes all articles queued for the user's digest.
+func (b *Bolt) PopPending(_ context.Context, chatID string) ([]Article, error) {
+	var result []Article
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		pending := tx.Bucket([]byte(pendingBktName))
+		bkt := pending.Bucket([]byte(chatID))
+		if bkt == nil {
+			return nil
+		}
+
+		err := bkt.ForEach(func(k, v []byte) error {
+			var a Article
+			if err := json.Unmarshal(v, &a); err != nil {
+				return fmt.Errorf("unmarshal article %x: %w", k, err)
+			}
+			result = append(result, a)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("foreach: %w", err)
+		}
+
+		if err = pending.DeleteBucket([]byte(chatID)); err != nil {
+			return fmt.Errorf("remove pending articles: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("update storage: %w", err)
+	}
+	return result, nil
+}
+
+// HasPending returns true if there are articles queued for the user's digest.
+func (b *Bolt) HasPending(_ context.Context, chatID string) (has bool, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(pendingBktName)).Bucket([]byte(chatID))
+		if bkt == nil {
+			return nil
+		}
+
+		k, _ := bkt.Cursor().First()
+		has = k != nil
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("view storage: %w", err)
+	}
+
+	return has, nil
+}
+
+// PutArticle saves the article by its normalized URL.
+func (b *Bolt) PutArticle(_ context.Context, a Article) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(articlesBktName))
+
+		bts, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("marshal article: %w", err)
+		}
+
+		key := []byte(NormalizeURL(a.URL))
+		if err := bkt.Put(key, bts); err != nil {
+			return fmt.Errorf("put article to storage: %w", err)
+		}
+
+		if err := tx.Bucket([]byte(articleIDsBktName)).Put([]byte(ShortID(a.URL)), key); err != nil {
+			return fmt.Errorf("put article id to storage: %w", err)
+		}
+
+		if a.Summarizer == "" {
+			return nil
+		}
+
+		smrBkt := tx.Bucket([]byte(summarizersBktName))
+		if err := smrBkt.Put([]byte(SummarizerID(a.Summarizer)), []byte(a.Summarizer)); err != nil {
+			return fmt.Errorf("put summarizer to storage: %w", err)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -126,5 +623,226 @@ func (b *Bolt) Delete(_ context.Context, id string) error {
 	return nil
 }
 
+// GetArticle returns the article by URL, the URL is normalized before the lookup.
+func (b *Bolt) GetArticle(_ context.Context, url string) (a Article, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(articlesBktName))
+
+		bts := bkt.Get([]byte(NormalizeURL(url)))
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		if err := json.Unmarshal(bts, &a); err != nil {
+			return fmt.Errorf("unmarshal article: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Article{}, fmt.Errorf("view storage: %w", err)
+	}
+
+	return a, nil
+}
+
+// GetArticleByID returns the article by its ShortID.
+func (b *Bolt) GetArticleByID(_ context.Context, id string) (a Article, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket([]byte(articleIDsBktName)).Get([]byte(id))
+		if key == nil {
+			return ErrNotFound
+		}
+
+		bts := tx.Bucket([]byte(articlesBktName)).Get(key)
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		if err := json.Unmarshal(bts, &a); err != nil {
+			return fmt.Errorf("unmarshal article: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Article{}, fmt.Errorf("view storage: %w", err)
+	}
+
+	return a, nil
+}
+
+// GetSummarizer returns the name of the summarizer by its SummarizerID.
+func (b *Bolt) GetSummarizer(_ context.Context, id string) (name string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bts := tx.Bucket([]byte(summarizersBktName)).Get([]byte(id))
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		name = string(bts)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("view storage: %w", err)
+	}
+
+	return name, nil
+}
+
+// AddHistory records that the user has received the article.
+func (b *Bolt) AddHistory(_ context.Context, chatID string, e HistoryEntry) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.Bucket([]byte(historyBktName)).CreateBucketIfNotExists([]byte(chatID))
+		if err != nil {
+			return fmt.Errorf("make history bucket: %w", err)
+		}
+
+		seq, err := bkt.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+
+		e.URL = NormalizeURL(e.URL)
+		bts, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal history entry: %w", err)
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+
+		if err = bkt.Put(key, bts); err != nil {
+			return fmt.Errorf("put history entry: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// ListHistory returns articles received by the user, newest first.
+func (b *Bolt) ListHistory(_ context.Context, req HistoryRequest) ([]HistoryEntry, error) {
+	var result []HistoryEntry
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(historyBktName)).Bucket([]byte(req.ChatID))
+		if bkt == nil {
+			return nil
+		}
+
+		articles := tx.Bucket([]byte(articlesBktName))
+		queryWords := strings.Fields(strings.ToLower(req.Query))
+
+		c := bkt.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if req.Limit > 0 && len(result) >= req.Limit {
+				return nil
+			}
+
+			var e HistoryEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("unmarshal history entry %x: %w", k, err)
+			}
+
+			bts := articles.Get([]byte(e.URL))
+			if bts == nil {
+				continue // article was removed
+			}
+
+			if err := json.Unmarshal(bts, &e.Article); err != nil {
+				return fmt.Errorf("unmarshal article %s: %w", e.URL, err)
+			}
+
+			if e.Summary != nil {
+				e.Article = e.Article.WithSummary(*e.Summary)
+			}
+
+			if !e.Article.matches(queryWords) {
+				continue
+			}
+
+			result = append(result, e)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("view storage: %w", err)
+	}
+	return result, nil
+}
+
+// PutRating saves the rating of the summary, the previous rating
+// of the same user for the same summary is replaced.
+func (b *Bolt) PutRating(_ context.Context, r Rating) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		r.URL = NormalizeURL(r.URL)
+		bts, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("marshal rating: %w", err)
+		}
+
+		if err = tx.Bucket([]byte(ratingsBktName)).Put(ratingKey(r.ChatID, r.URL, r.Summarizer), bts); err != nil {
+			return fmt.Errorf("put rating to storage: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update storage: %w", err)
+	}
+
+	return nil
+}
+
+// GetRating returns the rating of the summary by the user.
+func (b *Bolt) GetRating(_ context.Context, chatID, url, summarizer string) (r Rating, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		bts := tx.Bucket([]byte(ratingsBktName)).Get(ratingKey(chatID, url, summarizer))
+		if bts == nil {
+			return ErrNotFound
+		}
+
+		if err := json.Unmarshal(bts, &r); err != nil {
+			return fmt.Errorf("unmarshal rating: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Rating{}, fmt.Errorf("view storage: %w", err)
+	}
+
+	return r, nil
+}
+
+// ListRatings returns all ratings.
+func (b *Bolt) ListRatings(context.Context) ([]Rating, error) {
+	var result []Rating
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(ratingsBktName)).ForEach(func(k, v []byte) error {
+			var r Rating
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("unmarshal rating %s: %w", k, err)
+			}
+			result = append(result, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("view storage: %w", err)
+	}
+	return result, nil
+}
+
+// ratingKey groups ratings by the article and the summarizer.
+func ratingKey(chatID, url, summarizer string) []byte {
+	return []byte(NormalizeURL(url) + "\n" + summarizer + "\n" + chatID)
+}
+
 // Close closes the storage.
 func (b *Bolt) Close() error { return b.db.Close() }
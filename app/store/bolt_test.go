@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBolt_Subscriptions(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	require.NoError(t, b.Put(ctx, User{ChatID: "1", Username: "alice"}))
+	require.NoError(t, b.Put(ctx, User{ChatID: "2", Username: "bob"}))
+	require.NoError(t, b.PutFeed(ctx, Feed{URL: "https://a.example.com/rss", Title: "A"}))
+	require.NoError(t, b.PutFeed(ctx, Feed{URL: "https://b.example.com/rss", Title: "B"}))
+
+	require.NoError(t, b.Subscribe(ctx, "1", "https://b.example.com/rss"))
+	require.NoError(t, b.Subscribe(ctx, "1", "https://a.example.com/rss"))
+	require.NoError(t, b.Subscribe(ctx, "2", "https://a.example.com/rss"))
+
+	feeds, err := b.ListSubscriptions(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []Feed{
+		{URL: "https://a.example.com/rss", Title: "A"},
+		{URL: "https://b.example.com/rss", Title: "B"},
+	}, feeds)
+
+	users, err := b.ListSubscribers(ctx, "https://a.example.com/rss")
+	require.NoError(t, err)
+	assert.Equal(t, []User{{ChatID: "1", Username: "alice"}, {ChatID: "2", Username: "bob"}}, users)
+
+	require.NoError(t, b.Unsubscribe(ctx, "1", "https://a.example.com/rss"))
+	users, err = b.ListSubscribers(ctx, "https://a.example.com/rss")
+	require.NoError(t, err)
+	assert.Equal(t, []User{{ChatID: "2", Username: "bob"}}, users)
+
+	// deleting user removes it from subscribers
+	require.NoError(t, b.Delete(ctx, "2"))
+	users, err = b.ListSubscribers(ctx, "https://a.example.com/rss")
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	// deleting feed removes it from subscriptions
+	require.NoError(t, b.DeleteFeed(ctx, "https://b.example.com/rss"))
+	feeds, err = b.ListSubscriptions(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, feeds)
+}
+
+func TestBolt_SetLastDigestAt(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	require.NoError(t, b.Put(ctx, User{ChatID: "1", Timezone: "Asia/Almaty"}))
+
+	ts := time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, b.SetLastDigestAt(ctx, "1", ts))
+
+	u, err := b.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, User{ChatID: "1", Timezone: "Asia/Almaty", LastDigestAt: ts}, u)
+
+	assert.ErrorIs(t, b.SetLastDigestAt(ctx, "2", ts), ErrNotFound)
+}
+
+func TestBolt_UpdateFeedItems(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	fresh, err := b.UpdateFeedItems(ctx, "feed", []string{"1", "2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, fresh)
+
+	fresh, err = b.UpdateFeedItems(ctx, "feed", []string{"2", "3", "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, fresh)
+
+	// "1" is forgotten as it's no longer in the feed
+	fresh, err = b.UpdateFeedItems(ctx, "feed", []string{"1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, fresh)
+}
+
+func TestBolt_Pending(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	articles, err := b.PopPending(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, articles)
+
+	for _, u := range []string{"a", "b", "c"} {
+		require.NoError(t, b.AddPending(ctx, "1", Article{URL: u}))
+	}
+	require.NoError(t, b.AddPending(ctx, "2", Article{URL: "d"}))
+
+	has, err := b.HasPending(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	articles, err = b.PopPending(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, []Article{{URL: "a"}, {URL: "b"}, {URL: "c"}}, articles)
+
+	articles, err = b.PopPending(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, articles)
+
+	has, err = b.HasPending(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	articles, err = b.PopPending(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, []Article{{URL: "d"}}, articles)
+}
+
+func TestBolt_History(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	a1 := Article{URL: "https://example.com/1/", Title: "Weather in Almaty", BulletPoints: "- snow"}
+	a2 := Article{URL: "https://example.com/2", Title: "Go 1.20 released", BulletPoints: "- generics"}
+	require.NoError(t, b.PutArticle(ctx, a1))
+	require.NoError(t, b.PutArticle(ctx, a2))
+
+	got, err := b.GetArticle(ctx, "https://EXAMPLE.com/1?utm_source=tg#top")
+	require.NoError(t, err)
+	assert.Equal(t, a1, got)
+
+	_, err = b.GetArticle(ctx, "https://example.com/3")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	got, err = b.GetArticleByID(ctx, ShortID("https://example.com/2/"))
+	require.NoError(t, err)
+	assert.Equal(t, a2, got)
+
+	_, err = b.GetArticleByID(ctx, ShortID("https://example.com/3"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// summarizers of replaced summaries are still known
+	require.NoError(t, b.PutArticle(ctx, Article{URL: a1.URL, Summarizer: "gpt-4:abcd"}))
+	require.NoError(t, b.PutArticle(ctx, a1))
+	name, err := b.GetSummarizer(ctx, SummarizerID("gpt-4:abcd"))
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4:abcd", name)
+
+	_, err = b.GetSummarizer(ctx, SummarizerID("extractive"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ts := time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, b.AddHistory(ctx, "1", HistoryEntry{URL: a1.URL, Source: SourceFeed, ReceivedAt: ts}))
+	require.NoError(t, b.AddHistory(ctx, "1", HistoryEntry{URL: a2.URL, Source: SourceRequest, ReceivedAt: ts.Add(time.Hour)}))
+
+	entries, err := b.ListHistory(ctx, HistoryRequest{ChatID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, []HistoryEntry{
+		{URL: "https://example.com/2", Source: SourceRequest, ReceivedAt: ts.Add(time.Hour), Article: a2},
+		{URL: "https://example.com/1", Source: SourceFeed, ReceivedAt: ts, Article: a1},
+	}, entries)
+
+	entries, err = b.ListHistory(ctx, HistoryRequest{ChatID: "1", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, a2, entries[0].Article)
+
+	entries, err = b.ListHistory(ctx, HistoryRequest{ChatID: "1", Query: "SNOW almaty"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, a1, entries[0].Article)
+
+	entries, err = b.ListHistory(ctx, HistoryRequest{ChatID: "2"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestBolt_HistorySummaries(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	ru := Article{URL: "https://example.com/1", Title: "Погода", BulletPoints: "- снег", Summarizer: "gpt-4:abcd"}
+	en := ru
+	en.BulletPoints, en.Preferences = "- snow", Preferences{Language: "en", Style: StyleTLDR}
+
+	ts := time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC)
+	for chatID, a := range map[string]Article{"1": ru, "2": en} {
+		require.NoError(t, b.PutArticle(ctx, a))
+		summary := a.Summary()
+		require.NoError(t, b.AddHistory(ctx, chatID, HistoryEntry{URL: a.URL, Source: SourceFeed, ReceivedAt: ts, Summary: &summary}))
+	}
+
+	// every user sees the summary they have received, whichever was stored last
+	for chatID, want := range map[string]Article{"1": ru, "2": en} {
+		entries, err := b.ListHistory(ctx, HistoryRequest{ChatID: chatID})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, want, entries[0].Article)
+	}
+
+	// search goes over the received summary
+	entries, err := b.ListHistory(ctx, HistoryRequest{ChatID: "1", Query: "snow"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = b.ListHistory(ctx, HistoryRequest{ChatID: "2", Query: "snow"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestBolt_Ratings(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := NewBolt(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	ts := time.Date(2023, 3, 21, 10, 0, 0, 0, time.UTC)
+	r1 := Rating{ChatID: "1", URL: "https://example.com/1/", Summarizer: "gpt-3.5-turbo:abcd", Positive: true, RatedAt: ts}
+	r2 := Rating{ChatID: "2", URL: "https://example.com/1", Summarizer: "gpt-3.5-turbo:abcd", RatedAt: ts}
+	require.NoError(t, b.PutRating(ctx, r1))
+	require.NoError(t, b.PutRating(ctx, r2))
+
+	// the user changes the mind
+	r2.Positive, r2.Reason = true, "inaccurate"
+	require.NoError(t, b.PutRating(ctx, r2))
+
+	got, err := b.GetRating(ctx, "1", "https://EXAMPLE.com/1", "gpt-3.5-turbo:abcd")
+	require.NoError(t, err)
+	r1.URL = "https://example.com/1"
+	assert.Equal(t, r1, got)
+
+	_, err = b.GetRating(ctx, "1", "https://example.com/1", "extractive")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ratings, err := b.ListRatings(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Rating{r1, r2}, ratings)
+}
@@ -3,7 +3,13 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
 )
 
 // ErrNotFound is an error that is returned when the requested entity is not found.
@@ -15,11 +21,170 @@ type Interface interface {
 	Get(ctx context.Context, chatID string) (User, error)
 	List(ctx context.Context, req ListRequest) ([]User, error)
 	Delete(ctx context.Context, chatID string) error
+	// SetLastDigestAt updates the time of the last digest of the user,
+	// the rest of the user's data is left as is.
+	SetLastDigestAt(ctx context.Context, chatID string, t time.Time) error
+
+	PutFeed(ctx context.Context, f Feed) error
+	GetFeed(ctx context.Context, url string) (Feed, error)
+	ListFeeds(ctx context.Context) ([]Feed, error)
+	DeleteFeed(ctx context.Context, url string) error
+	// UpdateFeedItems replaces the set of known item IDs of the feed
+	// and returns IDs that were not known before.
+	UpdateFeedItems(ctx context.Context, feedURL string, ids []string) (fresh []string, err error)
+
+	Subscribe(ctx context.Context, chatID, feedURL string) error
+	Unsubscribe(ctx context.Context, chatID, feedURL string) error
+	// ListSubscriptions returns feeds the user is subscribed to, sorted by URL.
+	ListSubscriptions(ctx context.Context, chatID string) ([]Feed, error)
+	ListSubscribers(ctx context.Context, feedURL string) ([]User, error)
+
+	// AddPending queues the article for the user's next digest.
+	AddPending(ctx context.Context, chatID string, a Article) error
+	// PopPending returns and removes all articles queued for the user's digest.
+	PopPending(ctx context.Context, chatID string) ([]Article, error)
+	// HasPending returns true if there are articles queued for the user's digest.
+	HasPending(ctx context.Context, chatID string) (bool, error)
+
+	// PutArticle saves the article by its normalized URL.
+	PutArticle(ctx context.Context, a Article) error
+	// GetArticle returns the article by URL, the URL is normalized before the lookup.
+	GetArticle(ctx context.Context, url string) (Article, error)
+	// GetArticleByID returns the article by its ShortID.
+	GetArticleByID(ctx context.Context, id string) (Article, error)
+	// GetSummarizer returns the name of the summarizer by its SummarizerID,
+	// summarizers are known from the saved articles.
+	GetSummarizer(ctx context.Context, id string) (string, error)
+	// AddHistory records that the user has received the article.
+	AddHistory(ctx context.Context, chatID string, e HistoryEntry) error
+	// ListHistory returns articles received by the user, newest first.
+	ListHistory(ctx context.Context, req HistoryRequest) ([]HistoryEntry, error)
+
+	// PutRating saves the rating of the summary, the previous rating
+	// of the same user for the same summary is replaced.
+	PutRating(ctx context.Context, r Rating) error
+	// GetRating returns the rating of the summary by the user.
+	GetRating(ctx context.Context, chatID, url, summarizer string) (Rating, error)
+	// ListRatings returns all ratings.
+	ListRatings(ctx context.Context) ([]Rating, error)
 }
 
 // ListRequest defines parameters for listing users from store.
 type ListRequest struct{}
 
+// HistoryRequest defines parameters for listing articles received by the user.
+type HistoryRequest struct {
+	ChatID string
+	// Limit is the maximum number of entries to return, unlimited if zero.
+	Limit int
+	// Query filters articles that contain all of its words
+	// in title, URL, summary or content, case-insensitive.
+	Query string
+}
+
+// HistoryEntry describes an article received by the user.
+type HistoryEntry struct {
+	URL        string    `json:"url"`
+	Source     Source    `json:"source"`
+	ReceivedAt time.Time `json:"received_at"`
+	// Summary is the summary the user has received, the stored article
+	// may be summarized again for other users. Empty for entries,
+	// recorded before summaries were kept in the history.
+	Summary *Summary `json:"summary,omitempty"`
+
+	// Article is filled by ListHistory with the received summary,
+	// it is not stored within the entry.
+	Article Article `json:"-"`
+}
+
+// Rating is the user's opinion about the summary of the article.
+type Rating struct {
+	ChatID string `json:"chat_id"`
+	URL    string `json:"url"`
+	// Summarizer is the summarizer of the rated summary, see Article.Summarizer.
+	Summarizer string `json:"summarizer"`
+	Positive   bool   `json:"positive"`
+	// Reason is an optional explanation of the rating.
+	Reason  string    `json:"reason"`
+	RatedAt time.Time `json:"rated_at"`
+}
+
+// Model returns the model from the summarizer of the rated summary.
+func (r Rating) Model() string {
+	if idx := strings.LastIndex(r.Summarizer, ":"); idx >= 0 {
+		return r.Summarizer[:idx]
+	}
+	return r.Summarizer
+}
+
+// PromptVersion returns the version of the prompt from the summarizer
+// of the rated summary, empty if the summarizer doesn't use prompts.
+func (r Rating) PromptVersion() string {
+	if idx := strings.LastIndex(r.Summarizer, ":"); idx >= 0 {
+		return r.Summarizer[idx+1:]
+	}
+	return ""
+}
+
+// Domain returns the host of the rated article without "www." prefix.
+func (r Rating) Domain() string {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Host == "" {
+		return r.URL
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// Source specifies how the user has received the article.
+type Source string
+
+// Sources of articles.
+const (
+	SourceRequest Source = "request" // user has sent a link
+	SourceFeed    Source = "feed"    // article came from the subscribed feed
+)
+
+// NormalizeURL returns the canonical form of URL to identify articles:
+// lower-cased scheme and host, without fragment, tracking parameters
+// and trailing slash. Unparseable URLs are returned as is.
+func NormalizeURL(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Host == "" {
+		return s
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+
+	q := u.Query()
+	for k := range q {
+		if strings.HasPrefix(k, "utm_") {
+			q.Del(k)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// ShortID returns a short identifier of the URL, that fits into
+// small payloads, such as data of bot buttons.
+func ShortID(u string) string {
+	h := sha256.Sum256([]byte(NormalizeURL(u)))
+	return hex.EncodeToString(h[:8])
+}
+
+// SummarizerID returns a short identifier of the summarizer name,
+// that fits into small payloads, such as data of bot buttons.
+func SummarizerID(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(h[:4])
+}
+
 // Article is a struct that contains the extracted article.
 type Article struct {
 	URL          string `json:"url"`
@@ -29,12 +194,218 @@ type Article struct {
 	Author       string `json:"author"`
 	ImageURL     string `json:"image_url"`
 	BulletPoints string `json:"bullet_points"`
+
+	// Summarizer identifies the model and the prompt the summary was made with.
+	Summarizer string `json:"summarizer"`
+	// Extractive is true if the summary consists of the article's own sentences,
+	// e.g. when the language model was not available.
+	Extractive bool `json:"extractive"`
+
+	// Preferences the summary was made with.
+	Preferences
+}
+
+// Summary is the summary of the article, made with the preferences by the summarizer.
+type Summary struct {
+	BulletPoints string `json:"bullet_points"`
+	Summarizer   string `json:"summarizer"`
+	Extractive   bool   `json:"extractive"`
+	Preferences
+}
+
+// Summary returns the summary of the article.
+func (a Article) Summary() Summary {
+	return Summary{
+		BulletPoints: a.BulletPoints,
+		Summarizer:   a.Summarizer,
+		Extractive:   a.Extractive,
+		Preferences:  a.Preferences,
+	}
+}
+
+// WithSummary returns the article with the summary replaced.
+func (a Article) WithSummary(s Summary) Article {
+	a.BulletPoints, a.Summarizer, a.Extractive, a.Preferences = s.BulletPoints, s.Summarizer, s.Extractive, s.Preferences
+	return a
+}
+
+// matches returns true if the article contains all the words
+// in its title, URL, summary or content.
+func (a Article) matches(lowerWords []string) bool {
+	if len(lowerWords) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(strings.Join([]string{a.Title, a.URL, a.BulletPoints, a.Content}, " "))
+	for _, w := range lowerWords {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+
+	return true
 }
 
 // User is a struct that contains the user's data.
+// The same person on different platforms is a different user,
+// as chat IDs of all platforms but the primary one are prefixed
+// with the name of the platform, e.g. "slack:D123".
 type User struct {
 	ChatID     string `json:"chat_id"`
 	Username   string `json:"username"`
 	Authorized bool   `json:"authorized"`
 	Subscribed bool   `json:"subscribed"`
+
+	// Platform is the messaging platform of the chat, empty for users
+	// registered before the bot has run on several platforms.
+	Platform string `json:"platform"`
+
+	// Title is set for group chats, in groups the whole chat is the user.
+	Title string `json:"title"`
+	// AutoSummarize is set for group chats, that want every posted link summarized.
+	AutoSummarize bool `json:"auto_summarize"`
+
+	// Timezone is an IANA time zone name, UTC if empty.
+	Timezone     string    `json:"timezone"`
+	Schedule     Schedule  `json:"schedule"`
+	LastDigestAt time.Time `json:"last_digest_at"`
+
+	Preferences
+}
+
+// Preferences define how the summaries are written for the user.
+type Preferences struct {
+	// Language is a code of the summary language from Languages, Russian if empty.
+	Language string `json:"language"`
+	Style    Style  `json:"style"`
+}
+
+// Key identifies the preferences in cache keys.
+func (p Preferences) Key() string {
+	lang, style := p.Language, p.Style
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+	if style == "" {
+		style = StyleBullets
+	}
+	return lang + "/" + string(style)
+}
+
+// LanguageName returns the native name of the summary language.
+func (p Preferences) LanguageName() string {
+	if name, ok := Languages[p.Language]; ok {
+		return name
+	}
+	return Languages[DefaultLanguage]
+}
+
+// DefaultLanguage is the language of summaries if the user hasn't chosen one.
+const DefaultLanguage = "ru"
+
+// Languages maps supported language codes to their native names.
+var Languages = map[string]string{
+	"ru": "Русский",
+	"en": "English",
+	"kk": "Қазақша",
+	"uk": "Українська",
+	"de": "Deutsch",
+	"fr": "Français",
+	"es": "Español",
+	"it": "Italiano",
+	"pt": "Português",
+	"tr": "Türkçe",
+	"zh": "中文",
+	"ja": "日本語",
+}
+
+// Style specifies the form of the summary.
+type Style string
+
+// Summary styles.
+const (
+	StyleBullets   Style = "bullets"   // 3-10 short bullet points
+	StyleParagraph Style = "paragraph" // a single paragraph
+	StyleTLDR      Style = "tldr"      // a single line
+	StyleDetailed  Style = "detailed"  // up to 15 detailed bullet points
+)
+
+// Styles lists all summary styles.
+var Styles = []Style{StyleBullets, StyleParagraph, StyleTLDR, StyleDetailed}
+
+// Location returns the user's time zone, or UTC, if it is not set or invalid.
+func (u User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// ScheduleMode specifies how often news updates are delivered to the user.
+type ScheduleMode string
+
+// Schedule modes.
+const (
+	// ScheduleImmediate delivers every summary as soon as it is ready.
+	ScheduleImmediate ScheduleMode = ""
+	ScheduleDaily     ScheduleMode = "daily"
+	ScheduleWeekly    ScheduleMode = "weekly"
+)
+
+// Schedule defines when the user receives a digest of news updates.
+type Schedule struct {
+	Mode ScheduleMode `json:"mode"`
+	// At is the time of day of the digest, in minutes since midnight.
+	At int `json:"at"`
+	// Weekday is a day of week of the digest, used only in weekly mode.
+	Weekday time.Weekday `json:"weekday"`
+}
+
+// Next returns the time of the first digest after the given moment
+// in the given location, or zero time for immediate schedule.
+func (s Schedule) Next(after time.Time, loc *time.Location) time.Time {
+	t := after.In(loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.At/60, s.At%60, 0, 0, loc)
+
+	switch s.Mode {
+	case ScheduleDaily:
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+	case ScheduleWeekly:
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+	default:
+		return time.Time{}
+	}
+
+	return next
+}
+
+// String returns human-readable representation of the schedule.
+func (s Schedule) String() string {
+	at := fmt.Sprintf("%02d:%02d", s.At/60, s.At%60)
+	switch s.Mode {
+	case ScheduleDaily:
+		return "daily at " + at
+	case ScheduleWeekly:
+		return fmt.Sprintf("weekly on %s at %s", s.Weekday, at)
+	default:
+		return "immediately"
+	}
+}
+
+// Feed is a struct that contains the data of a polled news feed.
+type Feed struct {
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	LastPolledAt time.Time `json:"last_polled_at"`
 }
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	almaty, err := time.LoadLocation("Asia/Almaty")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	// Tuesday, 10:00 in Almaty
+	after := time.Date(2023, 3, 21, 10, 0, 0, 0, almaty)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		expected time.Time
+	}{
+		{
+			name:     "immediate",
+			schedule: Schedule{},
+			expected: time.Time{},
+		},
+		{
+			name:     "daily, later today",
+			schedule: Schedule{Mode: ScheduleDaily, At: 18 * 60},
+			expected: time.Date(2023, 3, 21, 18, 0, 0, 0, almaty),
+		},
+		{
+			name:     "daily, exactly now is the next day",
+			schedule: Schedule{Mode: ScheduleDaily, At: 10 * 60},
+			expected: time.Date(2023, 3, 22, 10, 0, 0, 0, almaty),
+		},
+		{
+			name:     "weekly, later this week",
+			schedule: Schedule{Mode: ScheduleWeekly, At: 9*60 + 30, Weekday: time.Friday},
+			expected: time.Date(2023, 3, 24, 9, 30, 0, 0, almaty),
+		},
+		{
+			name:     "weekly, same weekday but earlier",
+			schedule: Schedule{Mode: ScheduleWeekly, At: 9 * 60, Weekday: time.Tuesday},
+			expected: time.Date(2023, 3, 28, 9, 0, 0, 0, almaty),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := tt.schedule.Next(after.UTC(), almaty)
+			assert.True(t, tt.expected.Equal(next), "expected %s, got %s", tt.expected, next)
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/news/1/":                     "https://example.com/news/1",
+		"HTTPS://Example.COM/News/1":                      "https://example.com/News/1",
+		"https://example.com/a?utm_source=tg&id=5#anchor": "https://example.com/a?id=5",
+		"https://example.com/":                            "https://example.com",
+		"not a url":                                       "not a url",
+	}
+
+	for in, expected := range tests {
+		assert.Equal(t, expected, NormalizeURL(in), in)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	assert.Equal(t, "ru/bullets", Preferences{}.Key())
+	assert.Equal(t, Preferences{}.Key(), Preferences{Language: "ru", Style: StyleBullets}.Key())
+	assert.Equal(t, "en/tldr", Preferences{Language: "en", Style: StyleTLDR}.Key())
+
+	assert.Equal(t, "Русский", Preferences{}.LanguageName())
+	assert.Equal(t, "English", Preferences{Language: "en"}.LanguageName())
+}
+
+func TestRating(t *testing.T) {
+	r := Rating{URL: "https://www.Example.com/1", Summarizer: "llama2:7b:abcd"}
+	assert.Equal(t, "llama2:7b", r.Model())
+	assert.Equal(t, "abcd", r.PromptVersion())
+	assert.Equal(t, "example.com", r.Domain())
+
+	r = Rating{URL: "https://example.com/1", Summarizer: "extractive"}
+	assert.Equal(t, "extractive", r.Model())
+	assert.Empty(t, r.PromptVersion())
+}
@@ -13,7 +13,16 @@ import (
 // API defines methods for an API interface to receive and send chat messages.
 type API interface {
 	Updates() <-chan Request
-	SendMessage(ctx context.Context, resp Response) error
+	// SendMessage sends the message and returns its ID.
+	SendMessage(ctx context.Context, resp Response) (msgID string, err error)
+	// EditMessage replaces the text of the previously sent message.
+	EditMessage(ctx context.Context, msgID string, resp Response) error
+	DeleteMessage(ctx context.Context, chatID, msgID string) error
+	// AnswerCallback stops the loading indicator of the pressed button
+	// and shows the text as a notification, if it is not empty.
+	AnswerCallback(ctx context.Context, callbackID, text string) error
+	// AnswerInline answers the inline query with the results of the response.
+	AnswerInline(ctx context.Context, resp Response) error
 }
 
 // Bot defines parameters for running a bot over some API.
@@ -41,7 +50,8 @@ func NewBot(h Handler, api API, opts ...Option) *Bot {
 	}
 }
 
-// Run starts updates listener.
+// Run starts updates listener, it returns when the context is done,
+// or when the updates channel of the API is closed.
 func (b *Bot) Run(ctx context.Context) {
 	wg := &sync.WaitGroup{}
 	wg.Add(b.Workers)
@@ -59,7 +69,10 @@ func (b *Bot) Run(ctx context.Context) {
 				select {
 				case <-ctx.Done():
 					return
-				case req := <-b.api.Updates():
+				case req, ok := <-b.api.Updates():
+					if !ok {
+						return
+					}
 					b.handleUpdate(ctx, req)
 				}
 			}
@@ -75,9 +88,32 @@ func (b *Bot) handleUpdate(ctx context.Context, req Request) {
 		b.Logger.ErrorCtx(ctx, "failed to handle request", slog.Any("err", err))
 	}
 
+	answered := false
 	for _, resp := range resps {
-		if err := b.api.SendMessage(ctx, resp); err != nil {
+		if resp.CallbackID != "" {
+			answered = answered || resp.CallbackID == req.CallbackID
+			if err := b.api.AnswerCallback(ctx, resp.CallbackID, resp.Text); err != nil {
+				b.Logger.WarnCtx(ctx, "failed to answer callback", slog.Any("err", err))
+			}
+			continue
+		}
+
+		if resp.InlineQueryID != "" {
+			if err := b.api.AnswerInline(ctx, resp); err != nil {
+				b.Logger.WarnCtx(ctx, "failed to answer inline query", slog.Any("err", err))
+			}
+			continue
+		}
+
+		if _, err := b.api.SendMessage(ctx, resp); err != nil {
 			b.Logger.WarnCtx(ctx, "failed to send message", slog.Any("err", err))
 		}
 	}
+
+	// the button keeps loading until the callback is answered
+	if req.Kind == RequestCallback && !answered {
+		if err := b.api.AnswerCallback(ctx, req.CallbackID, ""); err != nil {
+			b.Logger.WarnCtx(ctx, "failed to answer callback", slog.Any("err", err))
+		}
+	}
 }
@@ -0,0 +1,262 @@
+package botapi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"golang.org/x/exp/slog"
+)
+
+// consoleUsername is the username of the bot in the console,
+// e.g. to mention it in the group chat.
+const consoleUsername = "bot"
+
+// Commands of the console, the rest of lines are messages.
+const (
+	consolePress  = ":press "  // presses the button with the data
+	consoleInline = ":inline " // makes an inline query
+)
+
+// Console is a controller, that reads requests from the input line by line,
+// as if they were sent to the bot in the chat, and prints responses to the
+// output, e.g. to try handlers without a messaging platform.
+// Buttons are printed with their data and pressed with ":press <data>",
+// inline queries are made with ":inline <query>".
+type Console struct {
+	log     *slog.Logger
+	in      io.Reader
+	chat    botx.Chat
+	updates chan botx.Request
+
+	mu      sync.Mutex // guards the fields below
+	out     io.Writer
+	lastID  int
+	buttons map[string]string // data of the button -> ID of its message
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ConsoleParams defines parameters of the console.
+type ConsoleParams struct {
+	In  io.Reader
+	Out io.Writer
+	// Chat is the chat, all requests come from.
+	Chat botx.Chat
+}
+
+// NewConsole returns a new console bot controller.
+func NewConsole(lg *slog.Logger, params ConsoleParams, bufferSize int) *Console {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Console{
+		log:     lg,
+		in:      params.In,
+		out:     params.Out,
+		chat:    params.Chat,
+		updates: make(chan botx.Request, bufferSize),
+		buttons: map[string]string{},
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// Run reads requests until the input ends or Stop is called,
+// then the updates channel is closed.
+func (b *Console) Run() {
+	defer close(b.done)
+	defer close(b.updates)
+
+	// reading can't be interrupted, so the reader is left
+	// blocked on the input, when the console is stopped
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		sc := bufio.NewScanner(b.in)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-b.ctx.Done():
+				return
+			}
+		}
+
+		if err := sc.Err(); err != nil {
+			b.log.Warn("failed to read input", slog.Any("err", err))
+		}
+	}()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+
+			req, ok := b.makeRequest(strings.TrimSpace(line))
+			if !ok {
+				continue
+			}
+
+			select {
+			case b.updates <- req:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// makeRequest converts the line into a request, returns false if the line is empty.
+func (b *Console) makeRequest(line string) (botx.Request, bool) {
+	if line == "" {
+		return botx.Request{}, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	id := strconv.Itoa(b.lastID)
+
+	switch {
+	case strings.HasPrefix(line, consolePress):
+		data := strings.TrimSpace(strings.TrimPrefix(line, consolePress))
+		msgID, ok := b.buttons[data]
+		if !ok {
+			b.printf("(no button with data %q)\n", data)
+			return botx.Request{}, false
+		}
+
+		return botx.Request{
+			Kind:       botx.RequestCallback,
+			MessageID:  msgID,
+			Chat:       b.chat,
+			Text:       data,
+			CallbackID: "cb" + id,
+		}, true
+	case strings.HasPrefix(line, consoleInline):
+		return botx.Request{
+			Kind:          botx.RequestInline,
+			Chat:          botx.Chat{ID: b.chat.ID, Username: b.chat.Username, Type: botx.ChatPrivate},
+			Text:          strings.TrimSpace(strings.TrimPrefix(line, consoleInline)),
+			InlineQueryID: "q" + id,
+		}, true
+	default:
+		return botx.Request{MessageID: id, Chat: b.chat, Text: line}, true
+	}
+}
+
+// Username returns the username of the bot in the console.
+func (b *Console) Username() string { return consoleUsername }
+
+// Stop stops reading requests.
+func (b *Console) Stop() {
+	b.cancel()
+	<-b.done
+}
+
+// Updates returns updates channel.
+func (b *Console) Updates() <-chan botx.Request {
+	return b.updates
+}
+
+// SendMessage prints the message with its ID and buttons.
+func (b *Console) SendMessage(_ context.Context, resp botx.Response) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	id := strconv.Itoa(b.lastID)
+	b.printMessage(id, "", resp)
+
+	return id, nil
+}
+
+// EditMessage prints the new text of the message.
+func (b *Console) EditMessage(_ context.Context, msgID string, resp botx.Response) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.printMessage(msgID, " (edited)", resp)
+	return nil
+}
+
+// DeleteMessage prints that the message is deleted.
+func (b *Console) DeleteMessage(_ context.Context, chatID, msgID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.printf("%s#%s (deleted)\n", b.recipient(chatID), msgID)
+	return nil
+}
+
+// AnswerCallback prints the notification, if its text is not empty.
+func (b *Console) AnswerCallback(_ context.Context, _, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.printf("(notification) %s\n", text)
+	return nil
+}
+
+// AnswerInline prints results of the inline query.
+func (b *Console) AnswerInline(_ context.Context, resp botx.Response) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resp.Text != "" {
+		b.printf("(inline) %s\n", resp.Text)
+	}
+
+	for _, res := range resp.InlineResults {
+		b.printf("(inline result) %s: %s\n%s\n", res.Title, res.Description, res.ParseMode.Strip(res.Text))
+	}
+
+	return nil
+}
+
+// printMessage prints the message without formatting,
+// buttons are printed with their data to press them.
+func (b *Console) printMessage(id, note string, resp botx.Response) {
+	b.printf("%s#%s%s %s\n", b.recipient(resp.ChatID), id, note, resp.ParseMode.Strip(resp.Text))
+
+	for _, row := range resp.Buttons {
+		btns := make([]string, 0, len(row))
+		for _, btn := range row {
+			b.buttons[btn.Data] = id
+			btns = append(btns, fmt.Sprintf("[%s %s%s]", btn.Text, consolePress, btn.Data))
+		}
+		b.printf("    %s\n", strings.Join(btns, " "))
+	}
+}
+
+// recipient returns the prefix for messages to other chats, e.g. to admins.
+func (b *Console) recipient(chatID string) string {
+	if chatID == b.chat.ID {
+		return ""
+	}
+	return "to " + chatID + ": "
+}
+
+func (b *Console) printf(format string, args ...any) {
+	if _, err := fmt.Fprintf(b.out, format, args...); err != nil {
+		b.log.Warn("failed to print", slog.Any("err", err))
+	}
+}
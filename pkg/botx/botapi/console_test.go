@@ -0,0 +1,91 @@
+package botapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestConsole(t *testing.T) {
+	in, write := io.Pipe()
+	out := &bytes.Buffer{}
+	chat := botx.Chat{ID: "1", Username: "developer", Type: botx.ChatPrivate}
+
+	b := NewConsole(slog.Default(), ConsoleParams{In: in, Out: out, Chat: chat}, 10)
+	go b.Run()
+
+	receive := func(t *testing.T, line string) botx.Request {
+		_, err := io.WriteString(write, line+"\n")
+		require.NoError(t, err)
+
+		select {
+		case req := <-b.Updates():
+			return req
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no request received")
+			return botx.Request{}
+		}
+	}
+
+	ctx := context.Background()
+
+	req := receive(t, "https://example.com")
+	assert.Equal(t, botx.Request{MessageID: "1", Chat: chat, Text: "https://example.com"}, req)
+
+	msgID, err := b.SendMessage(ctx, botx.Response{
+		ChatID:    "1",
+		Text:      `*Title*\.`,
+		ParseMode: botx.ParseModeMarkdownV2,
+		Buttons:   [][]botx.Button{{{Text: "👍", Data: "fb:up:abc"}, {Text: "👎", Data: "fb:down:abc"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2", msgID)
+
+	req = receive(t, ":press fb:down:abc")
+	assert.Equal(t, botx.Request{
+		Kind:       botx.RequestCallback,
+		MessageID:  "2",
+		Chat:       chat,
+		Text:       "fb:down:abc",
+		CallbackID: "cb3",
+	}, req)
+
+	require.NoError(t, b.AnswerCallback(ctx, req.CallbackID, "Thank you!"))
+	require.NoError(t, b.EditMessage(ctx, "2", botx.Response{ChatID: "1", Text: "Title."}))
+	require.NoError(t, b.DeleteMessage(ctx, "1", "2"))
+	_, err = b.SendMessage(ctx, botx.Response{ChatID: "42", Text: "new user: developer"})
+	require.NoError(t, err)
+
+	req = receive(t, ":inline https://example.com")
+	assert.Equal(t, botx.Request{
+		Kind:          botx.RequestInline,
+		Chat:          chat,
+		Text:          "https://example.com",
+		InlineQueryID: "q5",
+	}, req)
+
+	// the end of input stops the console
+	require.NoError(t, write.Close())
+	select {
+	case _, ok := <-b.Updates():
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "updates are not closed")
+	}
+	b.Stop()
+
+	assert.Equal(t, "#2 Title.\n"+
+		"    [👍 :press fb:up:abc] [👎 :press fb:down:abc]\n"+
+		"(notification) Thank you!\n"+
+		"#2 (edited) Title.\n"+
+		"#2 (deleted)\n"+
+		"to 42: #4 new user: developer\n", out.String())
+}
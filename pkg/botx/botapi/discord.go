@@ -0,0 +1,715 @@
+package botapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// discordAPIURL is the base URL of discord REST API.
+	discordAPIURL = "https://discord.com/api/v10"
+	// maxDiscordMessageLength is the length of a part of long messages, discord limits
+	// messages by 2000 characters, the rest is left for the conversion of markdown.
+	maxDiscordMessageLength = 1800
+	// maxDiscordRetries limits retries of rate limited requests.
+	maxDiscordRetries = 3
+)
+
+// Types of interactions and responses to them, see
+// https://discord.com/developers/docs/interactions/receiving-and-responding
+const (
+	discordInteractionCommand   = 2
+	discordInteractionComponent = 3
+
+	discordResponseMessage        = 4
+	discordResponseDeferredUpdate = 6
+
+	discordFlagEphemeral = 1 << 6
+)
+
+// Codes of errors, that mean that the interaction is already answered or expired.
+const (
+	discordErrUnknownInteraction  = 10062
+	discordErrAlreadyAcknowledged = 40060
+)
+
+var (
+	// discordMentionRe matches mentions of users, roles and channels, and links
+	// with suppressed previews, e.g. <@123>, <@!123>, <#123>, <https://example.com>
+	discordMentionRe = regexp.MustCompile(`<(@!?|@&|#)(\d+)>|<(https?://[^\s>]+)>`)
+	// discordEscaper escapes markdown of discord, discord treats
+	// any punctuation after backslash as the literal character
+	discordEscaper   = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`, `>`, `\>`, `[`, `\[`, `]`, `\]`)
+	discordURLRe     = regexp.MustCompile(`https?://\S+`)
+	errDiscordInline = errors.New("discord doesn't support inline queries")
+)
+
+// Discord is a controller that handles requests from discord.
+// Messages and interactions are received from the gateway, messages with
+// commands, e.g. /start, and slash commands, registered by RegisterCommands,
+// are handled in the same way. Responses with cards are sent as embeds.
+type Discord struct {
+	log     *slog.Logger
+	cl      *http.Client
+	apiURL  string
+	token   string
+	userID  string
+	gw      *discordGateway
+	updates chan botx.Request
+
+	// names of channels are accessed only by the event loop
+	channels map[string]string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// DiscordParams defines parameters to connect to discord.
+type DiscordParams struct {
+	// Token is the token of the bot.
+	Token string
+	// APIURL is the base URL of REST API, used in tests.
+	APIURL string
+	// GatewayURL is the URL of the gateway, requested from the API if empty.
+	GatewayURL string
+}
+
+// NewDiscord returns a new discord bot controller.
+func NewDiscord(lg *slog.Logger, params DiscordParams, bufferSize int) (*Discord, error) {
+	if params.APIURL == "" {
+		params.APIURL = discordAPIURL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := &Discord{
+		log:      lg,
+		cl:       &http.Client{Timeout: 30 * time.Second},
+		apiURL:   strings.TrimSuffix(params.APIURL, "/"),
+		token:    params.Token,
+		updates:  make(chan botx.Request, bufferSize),
+		channels: map[string]string{},
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	var me discordUser
+	if err := b.do(ctx, http.MethodGet, "/users/@me", nil, &me); err != nil {
+		cancel()
+		return nil, fmt.Errorf("get bot user: %w", err)
+	}
+	b.userID = me.ID
+
+	gatewayURL := params.GatewayURL
+	if gatewayURL == "" {
+		var gw struct {
+			URL string `json:"url"`
+		}
+		if err := b.do(ctx, http.MethodGet, "/gateway/bot", nil, &gw); err != nil {
+			cancel()
+			return nil, fmt.Errorf("get gateway url: %w", err)
+		}
+		gatewayURL = gw.URL
+	}
+
+	b.gw = &discordGateway{
+		log:    lg,
+		url:    gatewayURL + "?v=10&encoding=json",
+		token:  params.Token,
+		events: make(chan discordDispatch, bufferSize),
+	}
+
+	return b, nil
+}
+
+// RegisterCommands registers slash commands of the bot, each command has
+// an optional text argument, that is passed after the command.
+func (b *Discord) RegisterCommands(ctx context.Context, cmds []botx.Command) error {
+	var app struct {
+		ID string `json:"id"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/oauth2/applications/@me", nil, &app); err != nil {
+		return fmt.Errorf("get application: %w", err)
+	}
+
+	body := make([]map[string]any, 0, len(cmds))
+	for _, cmd := range cmds {
+		body = append(body, map[string]any{
+			"name":        cmd.Name,
+			"description": cmd.Description,
+			"options": []map[string]any{{
+				"type":        3, // string
+				"name":        "args",
+				"description": "arguments of the command",
+			}},
+		})
+	}
+
+	if err := b.do(ctx, http.MethodPut, "/applications/"+app.ID+"/commands", body, nil); err != nil {
+		return fmt.Errorf("put commands: %w", err)
+	}
+
+	return nil
+}
+
+// Run runs discord bot listener until Stop is called.
+func (b *Discord) Run() {
+	defer close(b.done)
+
+	go b.gw.run(b.ctx)
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case ev := <-b.gw.events:
+			req, ok := b.handleDispatch(ev)
+			if !ok {
+				continue
+			}
+
+			select {
+			case b.updates <- req:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type discordMessage struct {
+	ID        string      `json:"id"`
+	ChannelID string      `json:"channel_id"`
+	GuildID   string      `json:"guild_id"`
+	Author    discordUser `json:"author"`
+	Content   string      `json:"content"`
+}
+
+type discordInteraction struct {
+	ID        string `json:"id"`
+	Token     string `json:"token"`
+	Type      int    `json:"type"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	// member is set in guilds, user in direct messages
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User    *discordUser `json:"user"`
+	Message *struct {
+		ID string `json:"id"`
+	} `json:"message"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value any    `json:"value"`
+		} `json:"options"`
+		CustomID string `json:"custom_id"`
+	} `json:"data"`
+}
+
+// handleDispatch converts the event into a request, returns false
+// if the event is not supported.
+func (b *Discord) handleDispatch(ev discordDispatch) (botx.Request, bool) {
+	switch ev.Type {
+	case "MESSAGE_CREATE":
+		var msg discordMessage
+		if err := json.Unmarshal(ev.Data, &msg); err != nil {
+			b.log.Warn("failed to decode message", slog.Any("err", err))
+			return botx.Request{}, false
+		}
+
+		if msg.Author.Bot || msg.Author.ID == b.userID {
+			return botx.Request{}, false
+		}
+
+		text := discordText(msg.Content)
+		if text == "" {
+			return botx.Request{}, false
+		}
+
+		return botx.Request{
+			MessageID: msg.ID,
+			Chat:      b.makeChat(msg.ChannelID, msg.GuildID, msg.Author),
+			Text:      text,
+		}, true
+	case "INTERACTION_CREATE":
+		var in discordInteraction
+		if err := json.Unmarshal(ev.Data, &in); err != nil {
+			b.log.Warn("failed to decode interaction", slog.Any("err", err))
+			return botx.Request{}, false
+		}
+
+		user := discordUser{}
+		switch {
+		case in.Member != nil:
+			user = in.Member.User
+		case in.User != nil:
+			user = *in.User
+		}
+
+		switch {
+		case in.Type == discordInteractionCommand:
+			return b.makeCommandRequest(in, user), true
+		case in.Type == discordInteractionComponent && in.Message != nil:
+			return botx.Request{
+				Kind:       botx.RequestCallback,
+				MessageID:  in.Message.ID,
+				Chat:       b.makeChat(in.ChannelID, in.GuildID, user),
+				Text:       in.Data.CustomID,
+				CallbackID: in.ID + ":" + in.Token,
+			}, true
+		}
+	}
+
+	return botx.Request{}, false
+}
+
+// makeCommandRequest converts the slash command into the command of the bot,
+// the interaction is answered right away, as discord requires an answer
+// within 3 seconds, responses of the bot are sent as usual messages.
+func (b *Discord) makeCommandRequest(in discordInteraction, user discordUser) botx.Request {
+	text := "/" + in.Data.Name
+	for _, opt := range in.Data.Options {
+		if v, ok := opt.Value.(string); ok && strings.TrimSpace(v) != "" {
+			text += " " + strings.TrimSpace(v)
+		}
+	}
+
+	if err := b.answerInteraction(b.ctx, in.ID, in.Token, "Working on "+text+"..."); err != nil {
+		b.log.Warn("failed to answer slash command", slog.String("command", text), slog.Any("err", err))
+	}
+
+	return botx.Request{
+		Chat: b.makeChat(in.ChannelID, in.GuildID, user),
+		Text: discordText(text),
+	}
+}
+
+// makeChat returns the chat of the channel, channels of guilds are groups,
+// names of guild channels are requested once.
+func (b *Discord) makeChat(channelID, guildID string, user discordUser) botx.Chat {
+	chat := botx.Chat{ID: channelID, Username: user.Username, Type: botx.ChatPrivate}
+	if guildID == "" {
+		return chat
+	}
+
+	chat.Type = botx.ChatGroup
+
+	name, ok := b.channels[channelID]
+	if !ok {
+		var ch struct {
+			Name string `json:"name"`
+		}
+		if err := b.do(b.ctx, http.MethodGet, "/channels/"+channelID, nil, &ch); err != nil {
+			b.log.Warn("failed to get channel", slog.String("channel_id", channelID), slog.Any("err", err))
+		}
+		name = ch.Name
+		b.channels[channelID] = name
+	}
+	chat.Title = name
+
+	return chat
+}
+
+// discordText converts mentions into @<user id>, so that they match the
+// username of the bot, and removes brackets around links.
+func discordText(text string) string {
+	text = discordMentionRe.ReplaceAllStringFunc(text, func(m string) string {
+		sm := discordMentionRe.FindStringSubmatch(m)
+		switch {
+		case sm[3] != "":
+			return sm[3]
+		case sm[1] == "#":
+			return "#" + sm[2]
+		default:
+			return "@" + sm[2]
+		}
+	})
+
+	return strings.TrimSpace(text)
+}
+
+// Username returns the user ID of the bot, mentions of the bot
+// are converted into @<user id> in requests.
+func (b *Discord) Username() string { return b.userID }
+
+// Stop stops discord bot listener.
+func (b *Discord) Stop() {
+	b.cancel()
+	<-b.done
+	close(b.updates)
+}
+
+// Updates returns updates channel.
+func (b *Discord) Updates() <-chan botx.Request {
+	return b.updates
+}
+
+// SendMessage sends message to the channel and returns its ID, long messages
+// are split into several ones, the ID of the first one is returned.
+// The card, if set, is sent as an embed instead of the text.
+func (b *Discord) SendMessage(ctx context.Context, resp botx.Response) (string, error) {
+	parts := b.messages(resp)
+
+	var firstID string
+	for i, msg := range parts {
+		// only the first part is a reply, the rest follow it
+		if i == 0 && resp.ReplyToMessageID != "" {
+			msg["message_reference"] = map[string]any{"message_id": resp.ReplyToMessageID, "fail_if_not_exists": false}
+		}
+
+		var sent discordMessage
+		if err := b.do(ctx, http.MethodPost, "/channels/"+resp.ChatID+"/messages", msg, &sent); err != nil {
+			return "", fmt.Errorf("send message part %d of %d: %w", i+1, len(parts), err)
+		}
+
+		if i == 0 {
+			firstID = sent.ID
+		}
+	}
+
+	return firstID, nil
+}
+
+// EditMessage replaces the message, if the new text is too long,
+// the rest of it is sent in new messages.
+func (b *Discord) EditMessage(ctx context.Context, msgID string, resp botx.Response) error {
+	parts := b.messages(resp)
+
+	if err := b.do(ctx, http.MethodPatch, "/channels/"+resp.ChatID+"/messages/"+msgID, parts[0], nil); err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+
+	for i, msg := range parts[1:] {
+		if err := b.do(ctx, http.MethodPost, "/channels/"+resp.ChatID+"/messages", msg, nil); err != nil {
+			return fmt.Errorf("send part %d of edited message: %w", i+2, err)
+		}
+	}
+
+	return nil
+}
+
+// messages returns bodies of messages of the response, the buttons are
+// attached to the last one, the previous embeds and buttons are removed
+// on edits by empty lists.
+func (b *Discord) messages(resp botx.Response) []map[string]any {
+	newMessage := func(content string, embeds []any) map[string]any {
+		return map[string]any{
+			"content":    content,
+			"embeds":     embeds,
+			"components": []any{},
+			// summaries of articles may contain anything, e.g. @everyone
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		}
+	}
+
+	var msgs []map[string]any
+	if resp.Card != nil {
+		msgs = append(msgs, newMessage("", []any{makeEmbed(*resp.Card)}))
+	} else {
+		for _, part := range splitMessage(resp.Text, resp.ParseMode, maxDiscordMessageLength) {
+			msgs = append(msgs, newMessage(discordMarkdown(part, resp.ParseMode), []any{}))
+		}
+	}
+
+	msgs[len(msgs)-1]["components"] = makeComponents(resp.Buttons)
+
+	return msgs
+}
+
+// DeleteMessage deletes the message.
+func (b *Discord) DeleteMessage(ctx context.Context, chatID, msgID string) error {
+	if err := b.do(ctx, http.MethodDelete, "/channels/"+chatID+"/messages/"+msgID, nil, nil); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback answers the click on the button, the text is sent as an
+// ephemeral message, visible only to the user, who clicked the button.
+// Repeated answers to the same interaction are ignored.
+func (b *Discord) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	id, token, ok := strings.Cut(callbackID, ":")
+	if !ok {
+		return fmt.Errorf("invalid callback id %q", callbackID)
+	}
+
+	if err := b.answerInteraction(ctx, id, token, text); err != nil && !isInteractionAnsweredError(err) {
+		return fmt.Errorf("answer callback: %w", err)
+	}
+
+	return nil
+}
+
+// answerInteraction answers the interaction with the ephemeral message,
+// or just acknowledges it, if the text is empty.
+func (b *Discord) answerInteraction(ctx context.Context, id, token, text string) error {
+	body := map[string]any{"type": discordResponseDeferredUpdate}
+	if text != "" {
+		body = map[string]any{
+			"type": discordResponseMessage,
+			"data": map[string]any{"content": discordEscape(text), "flags": discordFlagEphemeral},
+		}
+	}
+
+	return b.do(ctx, http.MethodPost, "/interactions/"+id+"/"+token+"/callback", body, nil)
+}
+
+// AnswerInline returns an error, as discord has no inline queries.
+func (b *Discord) AnswerInline(context.Context, botx.Response) error {
+	return errDiscordInline
+}
+
+// makeEmbed converts the card into an embed, fields are cut to the limits of discord.
+func makeEmbed(card botx.Card) map[string]any {
+	embed := map[string]any{
+		"title":       truncate(card.Title, 256),
+		"url":         card.URL,
+		"description": truncate(discordEscape(card.Description), 4096),
+	}
+	if card.Author != "" {
+		embed["author"] = map[string]any{"name": truncate(card.Author, 256)}
+	}
+	if card.Footer != "" {
+		embed["footer"] = map[string]any{"text": truncate(card.Footer, 2048)}
+	}
+
+	return embed
+}
+
+// makeComponents converts buttons into action rows, the data of the button
+// is its custom ID. Discord allows 5 rows of 5 buttons, the rest is dropped.
+func makeComponents(buttons [][]botx.Button) []any {
+	rows := []any{}
+	for i, row := range buttons {
+		if i == 5 {
+			break
+		}
+
+		btns := []any{}
+		for j, btn := range row {
+			if j == 5 {
+				break
+			}
+			btns = append(btns, map[string]any{
+				"type":      2, // button
+				"style":     2, // secondary
+				"label":     truncate(btn.Text, 80),
+				"custom_id": btn.Data,
+			})
+		}
+
+		rows = append(rows, map[string]any{"type": 1, "components": btns})
+	}
+
+	return rows
+}
+
+// truncate cuts the text to the limit of characters.
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// discordError is an error returned by discord API.
+type discordError struct {
+	Status     int
+	Code       int     `json:"code"`
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+}
+
+func (e *discordError) Error() string {
+	return fmt.Sprintf("discord error %d (code %d): %s", e.Status, e.Code, e.Message)
+}
+
+// isInteractionAnsweredError returns true if the interaction has already been answered or expired.
+func isInteractionAnsweredError(err error) bool {
+	var dErr *discordError
+	return errors.As(err, &dErr) &&
+		(dErr.Code == discordErrAlreadyAcknowledged || dErr.Code == discordErrUnknownInteraction)
+}
+
+// do makes a request to the REST API and decodes the response into result,
+// rate limited requests are retried after the time discord asks to wait.
+func (b *Discord) do(ctx context.Context, method, path string, body, result any) error {
+	var bts []byte
+	if body != nil {
+		var err error
+		if bts, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("marshal body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := b.doOnce(ctx, method, path, bts, result)
+
+		var dErr *discordError
+		if !errors.As(err, &dErr) || dErr.Status != http.StatusTooManyRequests || attempt == maxDiscordRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(dErr.RetryAfter * float64(time.Second))):
+		}
+	}
+}
+
+func (b *Discord) doOnce(ctx context.Context, method, path string, body []byte, result any) error {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.apiURL+path, rd)
+	if err != nil {
+		return fmt.Errorf("make request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bot "+b.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		dErr := &discordError{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(dErr)
+		return dErr
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// discordEscape escapes markdown in the text, links are left as is,
+// as discord doesn't recognize links with escaped characters.
+func discordEscape(text string) string {
+	sb := &strings.Builder{}
+	last := 0
+	for _, m := range discordURLRe.FindAllStringIndex(text, -1) {
+		_, _ = sb.WriteString(discordEscaper.Replace(text[last:m[0]]))
+		_, _ = sb.WriteString(text[m[0]:m[1]])
+		last = m[1]
+	}
+	_, _ = sb.WriteString(discordEscaper.Replace(text[last:]))
+
+	return sb.String()
+}
+
+// discordMarkdown converts the text formatted in the parse mode into discord markdown.
+func discordMarkdown(text string, mode botx.ParseMode) string {
+	switch mode {
+	case botx.ParseModeMarkdownV2:
+		return markdownV2ToDiscord(text)
+	case botx.ParseModeHTML:
+		return htmlToDiscord(text)
+	default:
+		return discordEscape(text)
+	}
+}
+
+// markdownV2ToDiscord converts telegram's markdown, which differs from the discord's one
+// in bold and strikethrough markers, escapes outside of code are the same.
+func markdownV2ToDiscord(text string) string {
+	markers := map[string]string{"*": "**", "_": "_", "__": "__", "~": "~~", "||": "||", "`": "`", "```": "```"}
+	code := false // backslashes are shown as is in discord's code, so it is not escaped
+
+	sb := &strings.Builder{}
+	for _, tok := range botx.TokenizeMarkdownV2(text) {
+		switch tok.Kind {
+		case botx.MarkdownV2Text:
+			if code {
+				_, _ = sb.WriteString(tok.Text)
+				continue
+			}
+			_, _ = sb.WriteString(discordEscape(tok.Text))
+		case botx.MarkdownV2LinkOpen:
+			_, _ = sb.WriteString("[")
+		case botx.MarkdownV2LinkClose:
+			u := strings.NewReplacer("(", "%28", ")", "%29").Replace(tok.Text)
+			_, _ = sb.WriteString("](" + u + ")")
+		default:
+			code = tok.Kind == botx.MarkdownV2Open && (tok.Text == "`" || tok.Text == "```")
+			_, _ = sb.WriteString(markers[tok.Text])
+		}
+	}
+
+	return sb.String()
+}
+
+// htmlToDiscord converts telegram's subset of HTML, unknown tags are dropped.
+func htmlToDiscord(text string) string {
+	markers := map[string]string{
+		"b": "**", "strong": "**",
+		"i": "*", "em": "*",
+		"u": "__", "ins": "__",
+		"s": "~~", "strike": "~~", "del": "~~",
+		"tg-spoiler": "||",
+		"code":       "`", "pre": "```",
+	}
+
+	sb := &strings.Builder{}
+	last, href := 0, ""
+	for _, m := range htmlTokenRe.FindAllStringSubmatchIndex(text, -1) {
+		_, _ = sb.WriteString(discordEscape(html.UnescapeString(text[last:m[0]])))
+		last = m[1]
+
+		closing, tag, attrs := text[m[2]:m[3]] == "/", strings.ToLower(text[m[4]:m[5]]), text[m[6]:m[7]]
+		switch {
+		case tag == "a" && closing:
+			_, _ = sb.WriteString("](" + href + ")")
+		case tag == "a":
+			href = ""
+			if hm := htmlHrefRe.FindStringSubmatch(attrs); hm != nil {
+				href = strings.NewReplacer("(", "%28", ")", "%29").Replace(html.UnescapeString(hm[1]))
+			}
+			_, _ = sb.WriteString("[")
+		default:
+			_, _ = sb.WriteString(markers[tag])
+		}
+	}
+	_, _ = sb.WriteString(discordEscape(html.UnescapeString(text[last:])))
+
+	return sb.String()
+}
@@ -0,0 +1,183 @@
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/exp/slog"
+)
+
+// Opcodes of the discord gateway, see
+// https://discord.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-opcodes
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatAck   = 11
+)
+
+// discordIntents are the events the bot receives: messages in guilds
+// and direct messages with their content.
+const discordIntents = 1<<9 | 1<<12 | 1<<15
+
+// discordReconnectDelay is the delay before the next connection to the gateway.
+const discordReconnectDelay = 5 * time.Second
+
+// discordPayload is a message of the gateway.
+type discordPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// discordDispatch is an event dispatched by the gateway.
+type discordDispatch struct {
+	Type string
+	Data json.RawMessage
+}
+
+// discordGateway keeps the connection to the discord gateway and sends
+// dispatched events to the channel. Sessions are not resumed, a new session
+// is identified after the connection is lost.
+type discordGateway struct {
+	log    *slog.Logger
+	url    string
+	token  string
+	events chan discordDispatch
+}
+
+// run connects to the gateway and reconnects to it until the context is done.
+func (g *discordGateway) run(ctx context.Context) {
+	for {
+		err := g.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		g.log.Warn("discord gateway session ended, reconnecting", slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(discordReconnectDelay):
+		}
+	}
+}
+
+// session identifies a new gateway session and reads its events until
+// the connection is closed or the gateway asks to reconnect.
+func (g *discordGateway) session(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, g.url, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// unblock reading, when the bot is stopped
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	var hello discordPayload
+	if err = conn.ReadJSON(&hello); err != nil {
+		return fmt.Errorf("read hello: %w", err)
+	}
+
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err = json.Unmarshal(hello.D, &helloData); hello.Op != discordOpHello || err != nil || helloData.HeartbeatInterval <= 0 {
+		return fmt.Errorf("unexpected hello %d: %s", hello.Op, hello.D)
+	}
+
+	// gorilla's connection supports one concurrent writer
+	var mu sync.Mutex
+	var seq *int64
+	send := func(op int, d any) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		bts, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf("marshal payload: %w", err)
+		}
+		return conn.WriteJSON(discordPayload{Op: op, D: bts})
+	}
+	heartbeat := func() error {
+		mu.Lock()
+		s := seq
+		mu.Unlock()
+		return send(discordOpHeartbeat, s)
+	}
+
+	err = send(discordOpIdentify, map[string]any{
+		"token":   g.token,
+		"intents": discordIntents,
+		"properties": map[string]string{
+			"os":      "linux",
+			"browser": "newsfeed",
+			"device":  "newsfeed",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("identify: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(helloData.HeartbeatInterval) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := heartbeat(); err != nil {
+					g.log.Warn("failed to send heartbeat", slog.Any("err", err))
+				}
+			}
+		}
+	}()
+
+	for {
+		var p discordPayload
+		if err = conn.ReadJSON(&p); err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+
+		switch p.Op {
+		case discordOpDispatch:
+			mu.Lock()
+			seq = p.S
+			mu.Unlock()
+
+			select {
+			case g.events <- discordDispatch{Type: p.T, Data: p.D}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case discordOpHeartbeat:
+			if err = heartbeat(); err != nil {
+				return fmt.Errorf("send requested heartbeat: %w", err)
+			}
+		case discordOpReconnect, discordOpInvalidSession:
+			return errors.New("gateway requested reconnect")
+		case discordOpHeartbeatAck:
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestDiscord(t *testing.T) {
+	srv := newFakeDiscord(t)
+
+	b, err := NewDiscord(slog.Default(), DiscordParams{
+		Token:      "token",
+		APIURL:     srv.URL + "/api",
+		GatewayURL: "ws" + strings.TrimPrefix(srv.URL, "http") + "/gateway",
+	}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "100", b.Username())
+
+	go b.Run()
+	defer b.Stop()
+
+	identify := <-srv.identified
+	assert.Equal(t, "token", identify["token"])
+	assert.EqualValues(t, discordIntents, identify["intents"])
+
+	receive := func(t *testing.T, typ, data string) botx.Request {
+		srv.dispatch <- discordPayload{Op: discordOpDispatch, T: typ, D: json.RawMessage(data)}
+		select {
+		case req := <-b.Updates():
+			return req
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no request received")
+			return botx.Request{}
+		}
+	}
+
+	t.Run("message", func(t *testing.T) {
+		// messages of bots are skipped
+		srv.dispatch <- discordPayload{Op: discordOpDispatch, T: "MESSAGE_CREATE",
+			D: json.RawMessage(`{"id":"1","channel_id":"10","author":{"id":"2","bot":true},"content":"hi"}`)}
+
+		req := receive(t, "MESSAGE_CREATE", `{"id":"2","channel_id":"20","guild_id":"30",
+			"author":{"id":"3","username":"alice"},"content":"<@!100> look <https://example.com/a>"}`)
+		assert.Equal(t, botx.Request{
+			MessageID: "2",
+			Chat:      botx.Chat{ID: "20", Username: "alice", Title: "general", Type: botx.ChatGroup},
+			Text:      "@100 look https://example.com/a",
+		}, req)
+	})
+
+	t.Run("slash command", func(t *testing.T) {
+		req := receive(t, "INTERACTION_CREATE", `{"id":"5","token":"tkn","type":2,"channel_id":"10",
+			"user":{"id":"3","username":"alice"},
+			"data":{"name":"subscribe","options":[{"name":"args","type":3,"value":"https://example.com/feed"}]}}`)
+		assert.Equal(t, botx.Request{
+			Chat: botx.Chat{ID: "10", Username: "alice", Type: botx.ChatPrivate},
+			Text: "/subscribe https://example.com/feed",
+		}, req)
+
+		call := srv.call(t)
+		assert.Equal(t, "POST /api/interactions/5/tkn/callback", call.route)
+		assert.JSONEq(t, `{"type":4,"data":{"content":"Working on /subscribe https://example.com/feed...","flags":64}}`, call.body)
+	})
+
+	t.Run("button", func(t *testing.T) {
+		req := receive(t, "INTERACTION_CREATE", `{"id":"6","token":"tkn","type":3,"channel_id":"10",
+			"user":{"id":"3","username":"alice"},"message":{"id":"50"},"data":{"custom_id":"fb:up:abc"}}`)
+		assert.Equal(t, botx.Request{
+			Kind:       botx.RequestCallback,
+			MessageID:  "50",
+			Chat:       botx.Chat{ID: "10", Username: "alice", Type: botx.ChatPrivate},
+			Text:       "fb:up:abc",
+			CallbackID: "6:tkn",
+		}, req)
+
+		require.NoError(t, b.AnswerCallback(context.Background(), req.CallbackID, ""))
+		call := srv.call(t)
+		assert.Equal(t, "POST /api/interactions/6/tkn/callback", call.route)
+		assert.JSONEq(t, `{"type":6}`, call.body)
+
+		// repeated answer is ignored
+		require.NoError(t, b.AnswerCallback(context.Background(), req.CallbackID, "Thank you!"))
+		call = srv.call(t)
+		assert.JSONEq(t, `{"type":4,"data":{"content":"Thank you!","flags":64}}`, call.body)
+	})
+
+	t.Run("send card", func(t *testing.T) {
+		srv.mu.Lock()
+		srv.rateLimited = true
+		srv.mu.Unlock()
+
+		id, err := b.SendMessage(context.Background(), botx.Response{
+			ChatID:           "10",
+			ReplyToMessageID: "2",
+			Text:             "*ignored*",
+			ParseMode:        botx.ParseModeMarkdownV2,
+			Card:             &botx.Card{Title: "Title", URL: "https://example.com/a", Description: "- point_one"},
+			Buttons:          [][]botx.Button{{{Text: "👍", Data: "fb:up:abc"}}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "60", id)
+
+		assert.Equal(t, "POST /api/channels/10/messages", srv.call(t).route) // rate limited
+		call := srv.call(t)
+		assert.Equal(t, "POST /api/channels/10/messages", call.route)
+		assert.JSONEq(t, `{
+			"content": "",
+			"embeds": [{"title":"Title","url":"https://example.com/a","description":"- point\\_one"}],
+			"components": [{"type":1,"components":[{"type":2,"style":2,"label":"👍","custom_id":"fb:up:abc"}]}],
+			"allowed_mentions": {"parse":[]},
+			"message_reference": {"message_id":"2","fail_if_not_exists":false}
+		}`, call.body)
+	})
+
+	t.Run("edit message", func(t *testing.T) {
+		require.NoError(t, b.EditMessage(context.Background(), "60", botx.Response{
+			ChatID:    "10",
+			Text:      `*done*\.`,
+			ParseMode: botx.ParseModeMarkdownV2,
+		}))
+
+		call := srv.call(t)
+		assert.Equal(t, "PATCH /api/channels/10/messages/60", call.route)
+		assert.JSONEq(t, `{"content":"**done**.","embeds":[],"components":[],"allowed_mentions":{"parse":[]}}`, call.body)
+	})
+
+	t.Run("register commands", func(t *testing.T) {
+		require.NoError(t, b.RegisterCommands(context.Background(), []botx.Command{
+			{Name: "start", Description: "subscribe to news updates"},
+		}))
+
+		assert.Equal(t, "GET /api/oauth2/applications/@me", srv.call(t).route)
+		call := srv.call(t)
+		assert.Equal(t, "PUT /api/applications/200/commands", call.route)
+		assert.JSONEq(t, `[{"name":"start","description":"subscribe to news updates","options":[
+			{"type":3,"name":"args","description":"arguments of the command"}]}]`, call.body)
+	})
+}
+
+func TestDiscordText(t *testing.T) {
+	assert.Equal(t, "@1 and @2 in #3, @4 see https://example.com",
+		discordText(" <@1> and <@!2> in <#3>, <@&4> see <https://example.com> "))
+}
+
+func TestDiscordMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		mode botx.ParseMode
+		want string
+	}{
+		{name: "plain", text: "a *b* https://example.com/a_b_c", mode: botx.ParseModePlain, want: `a \*b\* https://example.com/a_b_c`},
+		{
+			name: "markdown",
+			text: `*bold* _italic_ ~strike~ \*not bold\* ` + "`a*b \\\\ c`",
+			mode: botx.ParseModeMarkdownV2,
+			want: `**bold** _italic_ ~~strike~~ \*not bold\* ` + "`a*b \\ c`",
+		},
+		{
+			name: "markdown link",
+			text: `*[the site](https://example.com/a_(b\))*`,
+			mode: botx.ParseModeMarkdownV2,
+			want: `**[the site](https://example.com/a_%28b%29)**`,
+		},
+		{
+			name: "html",
+			text: `<b>bold</b> <a href="https://example.com/?a=1&amp;b=2">1 * 2</a> <tg-spoiler>x</tg-spoiler>`,
+			mode: botx.ParseModeHTML,
+			want: `**bold** [1 \* 2](https://example.com/?a=1&b=2) ||x||`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, discordMarkdown(tt.text, tt.mode))
+		})
+	}
+}
+
+// fakeDiscord is a local discord, that serves REST API and the gateway.
+type fakeDiscord struct {
+	*httptest.Server
+	identified chan map[string]any
+	dispatch   chan discordPayload
+	calls      chan fakeDiscordCall
+
+	mu          sync.Mutex
+	rateLimited bool // the next message is rate limited
+	answered    map[string]bool
+}
+
+type fakeDiscordCall struct {
+	route string
+	body  string
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	s := &fakeDiscord{
+		identified: make(chan map[string]any, 1),
+		dispatch:   make(chan discordPayload),
+		calls:      make(chan fakeDiscordCall, 10),
+		answered:   map[string]bool{},
+	}
+
+	write := func(w http.ResponseWriter, status int, body string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gateway" {
+			s.gateway(t, w, r)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bot token" {
+			write(w, http.StatusUnauthorized, `{"code":0,"message":"401: Unauthorized"}`)
+			return
+		}
+
+		route := r.Method + " " + r.URL.Path
+		switch route {
+		case "GET /api/users/@me":
+			write(w, http.StatusOK, `{"id":"100","username":"newsfeed","bot":true}`)
+			return
+		case "GET /api/channels/20":
+			write(w, http.StatusOK, `{"id":"20","name":"general"}`)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		s.calls <- fakeDiscordCall{route: route, body: string(body)}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(route, "/callback"):
+			if s.answered[r.URL.Path] {
+				write(w, http.StatusBadRequest, `{"code":40060,"message":"Interaction has already been acknowledged."}`)
+				return
+			}
+			s.answered[r.URL.Path] = true
+			w.WriteHeader(http.StatusNoContent)
+		case route == "POST /api/channels/10/messages" && s.rateLimited:
+			s.rateLimited = false
+			write(w, http.StatusTooManyRequests, `{"message":"You are being rate limited.","retry_after":0.01}`)
+		case route == "GET /api/oauth2/applications/@me":
+			write(w, http.StatusOK, `{"id":"200"}`)
+		default:
+			write(w, http.StatusOK, `{"id":"60"}`)
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *fakeDiscord) gateway(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "10", r.URL.Query().Get("v"))
+	require.NoError(t, conn.WriteJSON(discordPayload{Op: discordOpHello, D: json.RawMessage(`{"heartbeat_interval":45000}`)}))
+
+	var identify struct {
+		Op int            `json:"op"`
+		D  map[string]any `json:"d"`
+	}
+	require.NoError(t, conn.ReadJSON(&identify))
+	require.Equal(t, discordOpIdentify, identify.Op)
+	s.identified <- identify.D
+
+	// the connection is closed by the client, when the bot stops
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	seq := int64(0)
+	for {
+		select {
+		case p := <-s.dispatch:
+			seq++
+			p.S = &seq
+			assert.NoError(t, conn.WriteJSON(p))
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (s *fakeDiscord) call(t *testing.T) fakeDiscordCall {
+	select {
+	case c := <-s.calls:
+		return c
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no call received")
+		return fakeDiscordCall{}
+	}
+}
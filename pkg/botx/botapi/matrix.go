@@ -0,0 +1,613 @@
+package botapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// maxMatrixMessageLength is the length of a part of long messages, matrix limits
+	// events by 65536 bytes, the rest is left for the formatted body and JSON.
+	maxMatrixMessageLength = 16000
+	// matrixSyncTimeout is the time the homeserver holds the sync request,
+	// if there are no new events.
+	matrixSyncTimeout = 30 * time.Second
+	// matrixRetryDelay is the delay before the next sync after the failed one.
+	matrixRetryDelay = 5 * time.Second
+	// matrixHTMLFormat is the format of the formatted body of messages.
+	matrixHTMLFormat = "org.matrix.custom.html"
+)
+
+// matrixSyncFilter limits the sync to messages and the data needed to
+// recognize the type and the name of the room.
+const matrixSyncFilter = `{"room":{"timeline":{"types":["m.room.message","m.room.name"]},` +
+	`"state":{"types":["m.room.name"],"lazy_load_members":true}},` +
+	`"presence":{"types":[]},"account_data":{"types":[]}}`
+
+var (
+	matrixReplyRe   = regexp.MustCompile(`(?s)<mx-reply>.*?</mx-reply>`)
+	matrixLinkRe    = regexp.MustCompile(`(?s)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	matrixBreakRe   = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>`)
+	matrixTagRe     = regexp.MustCompile(`<[^>]*>`)
+	errMatrixInline = errors.New("matrix doesn't support inline queries")
+)
+
+// matrixMentionPrefix is the prefix of links to users, that clients use for mentions.
+const matrixMentionPrefix = "https://matrix.to/#/"
+
+// Matrix is a controller that handles requests from a matrix homeserver.
+// Updates are received by long polling the /sync endpoint of the client-server API,
+// the bot joins rooms it is invited to. Matrix has no buttons, so buttons
+// of responses are not shown.
+type Matrix struct {
+	log       *slog.Logger
+	cl        *http.Client
+	params    MatrixParams
+	userID    string
+	updates   chan botx.Request
+	txnPrefix string
+	txnID     int64
+
+	// rooms are accessed only by the sync loop
+	rooms map[string]*matrixRoom
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type matrixRoom struct {
+	name    string
+	members int // zero if unknown
+}
+
+// MatrixParams defines parameters to connect to the matrix homeserver.
+type MatrixParams struct {
+	// URL is the base URL of the homeserver, e.g. https://matrix.example.com
+	URL string
+	// Token is the access token of the bot user.
+	Token string
+	// SyncTokenPath is a file to persist the sync token, so that the bot
+	// continues from the last received event after restart. If empty,
+	// events sent while the bot was offline are skipped.
+	SyncTokenPath string
+}
+
+// NewMatrix returns a new matrix bot controller.
+func NewMatrix(lg *slog.Logger, params MatrixParams, bufferSize int) (*Matrix, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := &Matrix{
+		log:       lg,
+		cl:        &http.Client{Timeout: matrixSyncTimeout + 30*time.Second},
+		params:    params,
+		updates:   make(chan botx.Request, bufferSize),
+		txnPrefix: strconv.FormatInt(time.Now().UnixNano(), 36),
+		rooms:     map[string]*matrixRoom{},
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/account/whoami", nil, nil, &whoami); err != nil {
+		cancel()
+		return nil, fmt.Errorf("get user id: %w", err)
+	}
+	b.userID = whoami.UserID
+
+	return b, nil
+}
+
+// Run runs matrix bot listener until Stop is called.
+func (b *Matrix) Run() {
+	defer close(b.done)
+
+	since, err := b.loadSyncToken()
+	if err != nil {
+		b.log.Warn("failed to load sync token, skipping missed events", slog.Any("err", err))
+	}
+
+	for {
+		resp, err := b.sync(since)
+		switch {
+		case b.ctx.Err() != nil:
+			return
+		case err != nil:
+			b.log.Warn("failed to sync", slog.Any("err", err))
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(matrixRetryDelay):
+			}
+			continue
+		}
+
+		// the first sync returns the recent history, it is not replayed
+		if !b.handleSync(resp, since != "") {
+			return
+		}
+
+		since = resp.NextBatch
+		if err = b.saveSyncToken(since); err != nil {
+			b.log.Warn("failed to save sync token", slog.Any("err", err))
+		}
+	}
+}
+
+// matrixSync is a response of the /sync endpoint.
+type matrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Summary struct {
+				JoinedMembers *int `json:"m.joined_member_count"`
+			} `json:"summary"`
+			State struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"state"`
+			Timeline struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+type matrixEvent struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	Sender  string          `json:"sender"`
+	Content json.RawMessage `json:"content"`
+}
+
+type matrixContent struct {
+	MsgType       string          `json:"msgtype"`
+	Body          string          `json:"body"`
+	Format        string          `json:"format,omitempty"`
+	FormattedBody string          `json:"formatted_body,omitempty"`
+	RelatesTo     *matrixRelation `json:"m.relates_to,omitempty"`
+	NewContent    *matrixContent  `json:"m.new_content,omitempty"`
+}
+
+type matrixRelation struct {
+	RelType   string           `json:"rel_type,omitempty"`
+	EventID   string           `json:"event_id,omitempty"`
+	InReplyTo *matrixInReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+type matrixInReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+func (b *Matrix) sync(since string) (matrixSync, error) {
+	q := url.Values{"filter": {matrixSyncFilter}}
+	if since != "" {
+		q.Set("since", since)
+		q.Set("timeout", strconv.FormatInt(matrixSyncTimeout.Milliseconds(), 10))
+	}
+
+	var resp matrixSync
+	if err := b.do(b.ctx, http.MethodGet, "/sync", q, nil, &resp); err != nil {
+		return matrixSync{}, err
+	}
+
+	return resp, nil
+}
+
+// handleSync joins the rooms the bot is invited to and sends messages
+// as requests, if replay is set, returns false if the bot is stopped.
+func (b *Matrix) handleSync(resp matrixSync, replay bool) bool {
+	for roomID := range resp.Rooms.Invite {
+		if err := b.do(b.ctx, http.MethodPost, "/join/"+url.PathEscape(roomID), nil, struct{}{}, nil); err != nil {
+			b.log.Warn("failed to join room", slog.String("room_id", roomID), slog.Any("err", err))
+		}
+	}
+
+	// rooms are handled in the same order on every sync
+	roomIDs := make([]string, 0, len(resp.Rooms.Join))
+	for roomID := range resp.Rooms.Join {
+		roomIDs = append(roomIDs, roomID)
+	}
+	sort.Strings(roomIDs)
+
+	for _, roomID := range roomIDs {
+		joined := resp.Rooms.Join[roomID]
+		room := b.room(roomID)
+		if joined.Summary.JoinedMembers != nil {
+			room.members = *joined.Summary.JoinedMembers
+		}
+
+		for _, ev := range append(joined.State.Events, joined.Timeline.Events...) {
+			if ev.Type == "m.room.name" {
+				var c struct{ Name string }
+				if err := json.Unmarshal(ev.Content, &c); err == nil {
+					room.name = c.Name
+				}
+			}
+		}
+
+		if !replay {
+			continue
+		}
+
+		for _, ev := range joined.Timeline.Events {
+			req, ok := b.makeRequest(roomID, ev)
+			if !ok {
+				continue
+			}
+
+			select {
+			case b.updates <- req:
+			case <-b.ctx.Done():
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (b *Matrix) room(roomID string) *matrixRoom {
+	room, ok := b.rooms[roomID]
+	if !ok {
+		room = &matrixRoom{}
+		b.rooms[roomID] = room
+	}
+	return room
+}
+
+// makeRequest converts the message event into a request, returns false
+// if the event is not a text message of a user.
+func (b *Matrix) makeRequest(roomID string, ev matrixEvent) (botx.Request, bool) {
+	if ev.Type != "m.room.message" || ev.Sender == b.userID {
+		return botx.Request{}, false
+	}
+
+	var c matrixContent
+	if err := json.Unmarshal(ev.Content, &c); err != nil {
+		b.log.Warn("failed to decode message", slog.String("event_id", ev.EventID), slog.Any("err", err))
+		return botx.Request{}, false
+	}
+
+	// notices are sent by bots, edits repeat the messages
+	if c.MsgType != "m.text" || (c.RelatesTo != nil && c.RelatesTo.RelType == "m.replace") {
+		return botx.Request{}, false
+	}
+
+	text, urls := matrixText(c)
+	if text == "" {
+		return botx.Request{}, false
+	}
+
+	return botx.Request{
+		MessageID: ev.EventID,
+		Chat: botx.Chat{
+			ID:       roomID,
+			Username: ev.Sender,
+			Title:    b.room(roomID).name,
+			Type:     b.chatType(roomID),
+		},
+		Text: text,
+		URLs: urls,
+	}, true
+}
+
+// chatType returns private for rooms of the bot with a single user,
+// members of the room are requested if the sync hasn't reported them yet.
+func (b *Matrix) chatType(roomID string) botx.ChatType {
+	room := b.room(roomID)
+	if room.members == 0 {
+		var resp struct {
+			Joined map[string]json.RawMessage `json:"joined"`
+		}
+		err := b.do(b.ctx, http.MethodGet, "/rooms/"+url.PathEscape(roomID)+"/joined_members", nil, nil, &resp)
+		if err != nil {
+			b.log.Warn("failed to get room members", slog.String("room_id", roomID), slog.Any("err", err))
+			return botx.ChatGroup
+		}
+		room.members = len(resp.Joined)
+	}
+
+	if room.members <= 2 {
+		return botx.ChatPrivate
+	}
+	return botx.ChatGroup
+}
+
+// matrixText returns the text of the message and links from it, mentions
+// are turned into @<user id>, so that they match the username of the bot.
+// Quotes of replied messages are dropped.
+func matrixText(c matrixContent) (string, []string) {
+	if c.Format != matrixHTMLFormat || c.FormattedBody == "" {
+		// the fallback of the reply is the quote followed by an empty line
+		body := c.Body
+		if strings.HasPrefix(body, "> ") {
+			if _, rest, found := strings.Cut(body, "\n\n"); found {
+				body = rest
+			}
+		}
+		return strings.TrimSpace(body), nil
+	}
+
+	var urls []string
+
+	text := matrixReplyRe.ReplaceAllString(c.FormattedBody, "")
+	text = matrixLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		sm := matrixLinkRe.FindStringSubmatch(m)
+		href := html.UnescapeString(sm[1])
+
+		if strings.HasPrefix(href, matrixMentionPrefix+"@") || strings.HasPrefix(href, matrixMentionPrefix+"%40") {
+			if user, err := url.PathUnescape(strings.TrimPrefix(href, matrixMentionPrefix)); err == nil {
+				return user
+			}
+		}
+
+		urls = append(urls, href)
+		return sm[2]
+	})
+	text = matrixBreakRe.ReplaceAllString(text, "\n")
+	text = matrixTagRe.ReplaceAllString(text, "")
+
+	return strings.TrimSpace(html.UnescapeString(text)), urls
+}
+
+// Username returns the user ID of the bot without the leading "@".
+func (b *Matrix) Username() string { return strings.TrimPrefix(b.userID, "@") }
+
+// Stop stops matrix bot listener.
+func (b *Matrix) Stop() {
+	b.cancel()
+	<-b.done
+	close(b.updates)
+}
+
+// Updates returns updates channel.
+func (b *Matrix) Updates() <-chan botx.Request {
+	return b.updates
+}
+
+// SendMessage sends message to the room and returns its event ID,
+// long messages are split into several ones, the ID of the first one is returned.
+func (b *Matrix) SendMessage(ctx context.Context, resp botx.Response) (string, error) {
+	var firstID string
+	parts := splitMessage(resp.Text, resp.ParseMode, maxMatrixMessageLength)
+	for i, part := range parts {
+		c := matrixMessage(part, resp.ParseMode)
+		// only the first part is a reply, the rest follow it
+		if i == 0 && resp.ReplyToMessageID != "" {
+			c.RelatesTo = &matrixRelation{InReplyTo: &matrixInReplyTo{EventID: resp.ReplyToMessageID}}
+		}
+
+		id, err := b.send(ctx, resp.ChatID, c)
+		if err != nil {
+			return "", fmt.Errorf("send message part %d of %d: %w", i+1, len(parts), err)
+		}
+
+		if i == 0 {
+			firstID = id
+		}
+	}
+
+	return firstID, nil
+}
+
+// EditMessage replaces the text of the message, if the new text is too long,
+// the rest of it is sent in new messages.
+func (b *Matrix) EditMessage(ctx context.Context, msgID string, resp botx.Response) error {
+	parts := splitMessage(resp.Text, resp.ParseMode, maxMatrixMessageLength)
+
+	newContent := matrixMessage(parts[0], resp.ParseMode)
+
+	// clients, that don't support edits, show the fallback with the asterisk
+	c := newContent
+	c.Body = "* " + c.Body
+	if c.FormattedBody != "" {
+		c.FormattedBody = "* " + c.FormattedBody
+	}
+	c.NewContent = &newContent
+	c.RelatesTo = &matrixRelation{RelType: "m.replace", EventID: msgID}
+
+	if _, err := b.send(ctx, resp.ChatID, c); err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+
+	if len(parts) == 1 {
+		return nil
+	}
+
+	resp.Text = strings.Join(parts[1:], "\n\n")
+	resp.ReplyToMessageID = ""
+	if _, err := b.SendMessage(ctx, resp); err != nil {
+		return fmt.Errorf("send the rest of edited message: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMessage redacts the message.
+func (b *Matrix) DeleteMessage(ctx context.Context, chatID, msgID string) error {
+	path := "/rooms/" + url.PathEscape(chatID) + "/redact/" + url.PathEscape(msgID) + "/" + b.nextTxnID()
+	if err := b.do(ctx, http.MethodPut, path, nil, struct{}{}, nil); err != nil {
+		return fmt.Errorf("redact message: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback does nothing, as matrix has no buttons.
+func (b *Matrix) AnswerCallback(context.Context, string, string) error { return nil }
+
+// AnswerInline returns an error, as matrix has no inline queries.
+func (b *Matrix) AnswerInline(context.Context, botx.Response) error {
+	return errMatrixInline
+}
+
+// send sends the message event to the room and returns its ID.
+func (b *Matrix) send(ctx context.Context, roomID string, c matrixContent) (string, error) {
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + b.nextTxnID()
+	if err := b.do(ctx, http.MethodPut, path, nil, c, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.EventID, nil
+}
+
+// nextTxnID returns a unique transaction ID, the homeserver uses it
+// to deduplicate retried requests.
+func (b *Matrix) nextTxnID() string {
+	return b.txnPrefix + "." + strconv.FormatInt(atomic.AddInt64(&b.txnID, 1), 10)
+}
+
+// matrixError is an error returned by the homeserver.
+type matrixError struct {
+	Status  int
+	Code    string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+func (e *matrixError) Error() string {
+	return fmt.Sprintf("matrix error %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// do makes a request to the client-server API and decodes the response into result.
+func (b *Matrix) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	u := strings.TrimSuffix(b.params.URL, "/") + "/_matrix/client/v3" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var rd io.Reader
+	if body != nil {
+		bts, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal body: %w", err)
+		}
+		rd = bytes.NewReader(bts)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return fmt.Errorf("make request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.params.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		mErr := &matrixError{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(mErr)
+		return mErr
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Matrix) loadSyncToken() (string, error) {
+	if b.params.SyncTokenPath == "" {
+		return "", nil
+	}
+
+	bts, err := os.ReadFile(b.params.SyncTokenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+
+	return strings.TrimSpace(string(bts)), nil
+}
+
+func (b *Matrix) saveSyncToken(token string) error {
+	if b.params.SyncTokenPath == "" {
+		return nil
+	}
+
+	if err := os.WriteFile(b.params.SyncTokenPath, []byte(token), 0o600); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	return nil
+}
+
+// matrixMessage returns the content of the text message, the plain body
+// is shown by clients without formatting support and in notifications.
+func matrixMessage(text string, mode botx.ParseMode) matrixContent {
+	c := matrixContent{MsgType: "m.text", Body: mode.Strip(text)}
+
+	switch mode {
+	case botx.ParseModeMarkdownV2:
+		c.Format, c.FormattedBody = matrixHTMLFormat, markdownV2ToHTML(text)
+	case botx.ParseModeHTML:
+		// telegram's HTML is a subset of matrix's one, except for spoilers
+		text = strings.ReplaceAll(text, "<tg-spoiler>", "<span data-mx-spoiler>")
+		c.Format, c.FormattedBody = matrixHTMLFormat, strings.ReplaceAll(text, "</tg-spoiler>", "</span>")
+	}
+
+	return c
+}
+
+// markdownV2ToHTML converts telegram's markdown into HTML.
+func markdownV2ToHTML(text string) string {
+	tags := map[string]string{"*": "b", "_": "i", "__": "u", "~": "s", "||": "span", "`": "code", "```": "pre"}
+
+	sb := &strings.Builder{}
+	for _, tok := range botx.TokenizeMarkdownV2(text) {
+		switch {
+		case tok.Kind == botx.MarkdownV2Text:
+			_, _ = sb.WriteString(html.EscapeString(tok.Text))
+		case tok.Kind == botx.MarkdownV2LinkOpen:
+			_, _ = sb.WriteString(`<a href="` + html.EscapeString(tok.Text) + `">`)
+		case tok.Kind == botx.MarkdownV2LinkClose:
+			_, _ = sb.WriteString("</a>")
+		case tok.Kind == botx.MarkdownV2Open && tok.Text == "||":
+			_, _ = sb.WriteString("<span data-mx-spoiler>")
+		case tok.Kind == botx.MarkdownV2Open:
+			_, _ = sb.WriteString("<" + tags[tok.Text] + ">")
+		default:
+			_, _ = sb.WriteString("</" + tags[tok.Text] + ">")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,287 @@
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestMatrix(t *testing.T) {
+	srv := newFakeHomeserver(t)
+	srv.batches = []string{
+		// the first sync returns the history, that must not be replayed
+		`{"next_batch":"s1","rooms":{
+			"invite":{"!new:example.org":{}},
+			"join":{"!dm:example.org":{"summary":{"m.joined_member_count":2},"timeline":{"events":[
+				{"type":"m.room.message","event_id":"$old","sender":"@alice:example.org",
+				 "content":{"msgtype":"m.text","body":"old message"}}]}}}}}`,
+		`{"next_batch":"s2","rooms":{"join":{
+			"!dm:example.org":{"timeline":{"events":[
+				{"type":"m.room.message","event_id":"$bot","sender":"@bot:example.org",
+				 "content":{"msgtype":"m.text","body":"message of the bot"}},
+				{"type":"m.room.message","event_id":"$1","sender":"@alice:example.org",
+				 "content":{"msgtype":"m.text","body":"https://example.com/article"}}]}},
+			"!group:example.org":{
+				"summary":{"m.joined_member_count":5},
+				"state":{"events":[{"type":"m.room.name","content":{"name":"Readers"}}]},
+				"timeline":{"events":[
+				{"type":"m.room.message","event_id":"$2","sender":"@bob:example.org",
+				 "content":{"msgtype":"m.text","body":"> <@alice:example.org> hi\n\nNewsfeed: look",
+				 "format":"org.matrix.custom.html",
+				 "formatted_body":"<mx-reply><blockquote>hi</blockquote></mx-reply><a href=\"https://matrix.to/#/@bot:example.org\">Newsfeed</a>: look <a href=\"https://example.com/?a=1&amp;b=2\">here</a>"}}]}}}}}`,
+	}
+
+	tokenPath := filepath.Join(t.TempDir(), "matrix_sync_token")
+
+	b, err := NewMatrix(slog.Default(), MatrixParams{URL: srv.URL, Token: "secret", SyncTokenPath: tokenPath}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "bot:example.org", b.Username())
+
+	go b.Run()
+
+	receive := func() botx.Request {
+		select {
+		case req := <-b.Updates():
+			return req
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no request received")
+			return botx.Request{}
+		}
+	}
+
+	assert.Equal(t, botx.Request{
+		MessageID: "$1",
+		Chat:      botx.Chat{ID: "!dm:example.org", Username: "@alice:example.org", Type: botx.ChatPrivate},
+		Text:      "https://example.com/article",
+	}, receive())
+
+	assert.Equal(t, botx.Request{
+		MessageID: "$2",
+		Chat: botx.Chat{
+			ID:       "!group:example.org",
+			Username: "@bob:example.org",
+			Title:    "Readers",
+			Type:     botx.ChatGroup,
+		},
+		Text: "@bot:example.org: look here",
+		URLs: []string{"https://example.com/?a=1&b=2"},
+	}, receive())
+
+	assert.Equal(t, []string{"/_matrix/client/v3/join/!new:example.org"}, srv.callPaths("/join/"))
+
+	t.Run("send message", func(t *testing.T) {
+		id, err := b.SendMessage(context.Background(), botx.Response{
+			ChatID:           "!dm:example.org",
+			ReplyToMessageID: "$1",
+			Text:             `*Title*\. [link](https://example.com/a\))`,
+			ParseMode:        botx.ParseModeMarkdownV2,
+			Buttons:          [][]botx.Button{{{Text: "👍", Data: "fb:up:abc"}}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "$sent", id)
+
+		c := srv.lastMessage(t)
+		assert.Equal(t, "m.text", c.MsgType)
+		assert.Equal(t, "Title. link (https://example.com/a))", c.Body)
+		assert.Equal(t, matrixHTMLFormat, c.Format)
+		assert.Equal(t, `<b>Title</b>. <a href="https://example.com/a)">link</a>`, c.FormattedBody)
+		assert.Equal(t, &matrixRelation{InReplyTo: &matrixInReplyTo{EventID: "$1"}}, c.RelatesTo)
+	})
+
+	t.Run("edit message", func(t *testing.T) {
+		require.NoError(t, b.EditMessage(context.Background(), "$sent", botx.Response{
+			ChatID: "!dm:example.org",
+			Text:   "done",
+		}))
+
+		c := srv.lastMessage(t)
+		assert.Equal(t, "* done", c.Body)
+		assert.Equal(t, &matrixRelation{RelType: "m.replace", EventID: "$sent"}, c.RelatesTo)
+		assert.Equal(t, &matrixContent{MsgType: "m.text", Body: "done"}, c.NewContent)
+	})
+
+	t.Run("delete message", func(t *testing.T) {
+		require.NoError(t, b.DeleteMessage(context.Background(), "!dm:example.org", "$sent"))
+		paths := srv.callPaths("/redact/")
+		require.Len(t, paths, 1)
+		assert.True(t, strings.HasPrefix(paths[0], "/_matrix/client/v3/rooms/!dm:example.org/redact/$sent/"), paths[0])
+	})
+
+	b.Stop()
+
+	// the sync token is persisted, so the restarted bot continues from it
+	token, err := os.ReadFile(tokenPath)
+	require.NoError(t, err)
+	assert.Equal(t, "s2", string(token))
+
+	syncs := len(srv.since())
+
+	b, err = NewMatrix(slog.Default(), MatrixParams{URL: srv.URL, Token: "secret", SyncTokenPath: tokenPath}, 10)
+	require.NoError(t, err)
+	go b.Run()
+	require.Eventually(t, func() bool { return len(srv.since()) > syncs }, 5*time.Second, 10*time.Millisecond)
+	b.Stop()
+
+	since := srv.since()
+	assert.Equal(t, []string{"", "s1", "s2"}, since[:3])
+	assert.Equal(t, "s2", since[syncs])
+}
+
+func TestMatrixText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content matrixContent
+		want    string
+		urls    []string
+	}{
+		{name: "plain", content: matrixContent{Body: " hello "}, want: "hello"},
+		{name: "reply fallback", content: matrixContent{Body: "> <@a:b> quote\n> more\n\nanswer"}, want: "answer"},
+		{
+			name: "html",
+			content: matrixContent{
+				Body:          "ignored",
+				Format:        matrixHTMLFormat,
+				FormattedBody: `<p>see <a href="https://example.com">this</a><br/>and <a href="https://matrix.to/#/%40bot%3Aexample.org">bot</a> &amp; co</p>`,
+			},
+			want: "see this\nand @bot:example.org & co",
+			urls: []string{"https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, urls := matrixText(tt.content)
+			assert.Equal(t, tt.want, text)
+			assert.Equal(t, tt.urls, urls)
+		})
+	}
+}
+
+func TestMarkdownV2ToHTML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: `plain \- text \<b\>`, want: "plain - text &lt;b&gt;"},
+		{text: `*bold _italic_* __under__ ~strike~ ||spoiler||`,
+			want: `<b>bold <i>italic</i></b> <u>under</u> <s>strike</s> <span data-mx-spoiler>spoiler</span>`},
+		{text: "`code *not bold*` ```pre```", want: "<code>code *not bold*</code> <pre>pre</pre>"},
+		{text: `*[link](https://example.com/?a=1&b=2)*`, want: `<b><a href="https://example.com/?a=1&amp;b=2">link</a></b>`},
+		{text: `*bold _italic`, want: `<b>bold <i>italic</i></b>`},
+		{text: "`unclosed code", want: "<code>unclosed code</code>"},
+		{text: `[_link](https://example.com) after`, want: `<a href="https://example.com"><i>link</i></a> after`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, markdownV2ToHTML(tt.text))
+		})
+	}
+}
+
+// fakeHomeserver is a local matrix homeserver, that returns batches
+// of events on sync and records requests of the bot.
+type fakeHomeserver struct {
+	*httptest.Server
+	batches []string // responses to consecutive syncs, empty afterwards
+
+	mu       sync.Mutex
+	sinces   []string
+	paths    []string
+	messages []matrixContent
+}
+
+func newFakeHomeserver(t *testing.T) *fakeHomeserver {
+	s := &fakeHomeserver{}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"invalid token"}`))
+			return
+		}
+
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/_matrix/client/v3/account/whoami":
+			_, _ = w.Write([]byte(`{"user_id":"@bot:example.org"}`))
+		case r.URL.Path == "/_matrix/client/v3/sync":
+			s.sync(w, r)
+		case strings.Contains(r.URL.Path, "/send/m.room.message/"):
+			var c matrixContent
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&c))
+			s.mu.Lock()
+			s.messages = append(s.messages, c)
+			s.mu.Unlock()
+			_, _ = w.Write([]byte(`{"event_id":"$sent"}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *fakeHomeserver) sync(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.sinces = append(s.sinces, r.URL.Query().Get("since"))
+	var batch string
+	if len(s.batches) > 0 {
+		batch, s.batches = s.batches[0], s.batches[1:]
+	}
+	s.mu.Unlock()
+
+	if batch == "" {
+		// long polling without new events
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+		batch = `{"next_batch":"s2"}`
+	}
+
+	_, _ = w.Write([]byte(batch))
+}
+
+func (s *fakeHomeserver) since() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.sinces...)
+}
+
+func (s *fakeHomeserver) callPaths(substr string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []string
+	for _, p := range s.paths {
+		if strings.Contains(p, substr) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (s *fakeHomeserver) lastMessage(t *testing.T) matrixContent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.NotEmpty(t, s.messages)
+	return s.messages[len(s.messages)-1]
+}
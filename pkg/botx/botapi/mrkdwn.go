@@ -0,0 +1,88 @@
+package botapi
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+var (
+	// slack requires only these characters to be escaped, see
+	// https://api.slack.com/reference/surfaces/formatting#escaping
+	mrkdwnEscaper = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`)
+
+	htmlTokenRe  = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z-]*)([^>]*)>`)
+	htmlHrefRe   = regexp.MustCompile(`href="([^"]*)"`)
+	htmlMarkdown = map[string]string{
+		"b": "*", "strong": "*",
+		"i": "_", "em": "_",
+		"s": "~", "strike": "~", "del": "~",
+		"code": "`", "pre": "```",
+	}
+)
+
+// mrkdwn converts the text formatted in the parse mode into slack mrkdwn, see
+// https://api.slack.com/reference/surfaces/formatting#basics
+// Formatting that slack doesn't support, like underline and spoilers, is dropped.
+func mrkdwn(text string, mode botx.ParseMode) string {
+	switch mode {
+	case botx.ParseModeMarkdownV2:
+		return markdownV2ToMrkdwn(text)
+	case botx.ParseModeHTML:
+		return htmlToMrkdwn(text)
+	default:
+		return mrkdwnEscaper.Replace(text)
+	}
+}
+
+// markdownV2ToMrkdwn converts telegram's markdown, which is already close to mrkdwn,
+// the difference is in links, escaping and unsupported markers.
+func markdownV2ToMrkdwn(text string) string {
+	// spoilers are not supported and underline is shown as italic
+	markers := map[string]string{"*": "*", "_": "_", "__": "_", "~": "~", "||": "", "`": "`", "```": "```"}
+
+	sb := &strings.Builder{}
+	for _, tok := range botx.TokenizeMarkdownV2(text) {
+		switch tok.Kind {
+		case botx.MarkdownV2Text:
+			_, _ = sb.WriteString(mrkdwnEscaper.Replace(tok.Text))
+		case botx.MarkdownV2LinkOpen:
+			_, _ = sb.WriteString("<" + tok.Text + "|")
+		case botx.MarkdownV2LinkClose:
+			_, _ = sb.WriteString(">")
+		default:
+			_, _ = sb.WriteString(markers[tok.Text])
+		}
+	}
+
+	return sb.String()
+}
+
+// htmlToMrkdwn converts telegram's subset of HTML, unknown tags are dropped.
+func htmlToMrkdwn(text string) string {
+	sb := &strings.Builder{}
+	last := 0
+	for _, m := range htmlTokenRe.FindAllStringSubmatchIndex(text, -1) {
+		_, _ = sb.WriteString(mrkdwnEscaper.Replace(html.UnescapeString(text[last:m[0]])))
+		last = m[1]
+
+		closing, tag, attrs := text[m[2]:m[3]] == "/", strings.ToLower(text[m[4]:m[5]]), text[m[6]:m[7]]
+		switch {
+		case tag == "a" && closing:
+			_, _ = sb.WriteString(">")
+		case tag == "a":
+			href := ""
+			if hm := htmlHrefRe.FindStringSubmatch(attrs); hm != nil {
+				href = html.UnescapeString(hm[1])
+			}
+			_, _ = sb.WriteString("<" + href + "|")
+		default:
+			_, _ = sb.WriteString(htmlMarkdown[tag])
+		}
+	}
+	_, _ = sb.WriteString(mrkdwnEscaper.Replace(html.UnescapeString(text[last:])))
+
+	return sb.String()
+}
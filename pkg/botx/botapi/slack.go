@@ -0,0 +1,420 @@
+package botapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"golang.org/x/exp/slog"
+)
+
+// maxSlackMessageLength is the maximum length of a part of slack message,
+// slack limits the text of a section block by 3000 characters,
+// the rest is left for escaping during the conversion to mrkdwn.
+const maxSlackMessageLength = 2500
+
+var (
+	// slackLinkRe matches links, mentions and channel references in the text,
+	// e.g. <https://example.com|example>, <@U123>, <#C123|general>
+	slackLinkRe      = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+	slackUnescaper   = strings.NewReplacer(`&lt;`, `<`, `&gt;`, `>`, `&amp;`, `&`)
+	errSlackNoInline = errors.New("slack doesn't support inline queries")
+)
+
+// Slack is a controller that handles requests from slack.
+// Events are received over the Socket Mode connection, so the bot
+// doesn't need a public URL.
+type Slack struct {
+	log       *slog.Logger
+	api       *slack.Client
+	sm        *socketmode.Client
+	command   string
+	botUserID string
+	updates   chan botx.Request
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu sync.Mutex
+	// unanswered interactions by their IDs, slack has no notifications
+	// like telegram, so answers are sent as ephemeral messages
+	callbacks map[string]slackCallback
+}
+
+type slackCallback struct {
+	channelID string
+	userID    string
+}
+
+// SlackParams defines parameters to connect to slack.
+type SlackParams struct {
+	// Token is a bot token (xoxb-...), used to call Web API methods.
+	Token string
+	// AppToken is an app-level token (xapp-...) with connections:write scope,
+	// used to open Socket Mode connections.
+	AppToken string
+	// Command is the slash command of the bot, e.g. /newsfeed,
+	// its first word is treated as the command of the bot.
+	Command string
+	// APIURL is the base URL of slack Web API, used in tests.
+	APIURL string
+}
+
+// NewSlack returns a new slack bot controller.
+func NewSlack(lg *slog.Logger, params SlackParams, bufferSize int) (*Slack, error) {
+	opts := []slack.Option{slack.OptionAppLevelToken(params.AppToken)}
+	if params.APIURL != "" {
+		opts = append(opts, slack.OptionAPIURL(params.APIURL))
+	}
+
+	api := slack.New(params.Token, opts...)
+
+	auth, err := api.AuthTest()
+	if err != nil {
+		return nil, fmt.Errorf("test auth: %w", err)
+	}
+
+	stdlibLogger := slog.NewLogLogger(lg.Handler(), slog.LevelWarn)
+	stdlibLogger.SetPrefix("slack-socketmode: ")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Slack{
+		log:       lg,
+		api:       api,
+		sm:        socketmode.New(api, socketmode.OptionLog(stdlibLogger)),
+		command:   params.Command,
+		botUserID: auth.UserID,
+		updates:   make(chan botx.Request, bufferSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		callbacks: map[string]slackCallback{},
+	}, nil
+}
+
+// Run runs slack bot listener until Stop is called.
+func (b *Slack) Run() {
+	defer close(b.done)
+
+	go func() {
+		if err := b.sm.RunContext(b.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			b.log.Error("socket mode connection failed", slog.Any("err", err))
+			b.cancel()
+		}
+	}()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case evt := <-b.sm.Events:
+			req, ok := b.handleEvent(evt)
+			if !ok {
+				continue
+			}
+
+			select {
+			case b.updates <- req:
+			case <-b.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// handleEvent acknowledges the event and converts it into a request,
+// returns false if the event is not supported.
+func (b *Slack) handleEvent(evt socketmode.Event) (botx.Request, bool) {
+	// slack redelivers events, that are not acknowledged within 3 seconds
+	if evt.Request != nil && evt.Request.EnvelopeID != "" {
+		b.sm.Ack(*evt.Request)
+	}
+
+	switch evt.Type {
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+		b.log.Warn("socket mode connection error", slog.Any("err", evt.Data))
+	case socketmode.EventTypeEventsAPI:
+		ev, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok || ev.Type != slackevents.CallbackEvent {
+			return botx.Request{}, false
+		}
+
+		if msg, ok := ev.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+			return b.makeMessageRequest(msg)
+		}
+	case socketmode.EventTypeSlashCommand:
+		if cmd, ok := evt.Data.(slack.SlashCommand); ok {
+			return b.makeCommandRequest(cmd), true
+		}
+	case socketmode.EventTypeInteractive:
+		cb, ok := evt.Data.(slack.InteractionCallback)
+		if !ok || cb.Type != slack.InteractionTypeBlockActions || len(cb.ActionCallback.BlockActions) == 0 {
+			return botx.Request{}, false
+		}
+
+		return b.makeCallbackRequest(evt.Request.EnvelopeID, cb), true
+	}
+
+	return botx.Request{}, false
+}
+
+// makeMessageRequest converts the message into a request, returns false
+// for messages of bots and service messages, like edits and joins.
+func (b *Slack) makeMessageRequest(msg *slackevents.MessageEvent) (botx.Request, bool) {
+	if msg.SubType != "" || msg.BotID != "" || msg.User == "" || msg.User == b.botUserID {
+		return botx.Request{}, false
+	}
+
+	text, urls := slackText(msg.Text)
+	if text == "" {
+		return botx.Request{}, false
+	}
+
+	// messages of the thread are replied in the same thread
+	id := msg.TimeStamp
+	if msg.ThreadTimeStamp != "" {
+		id = msg.ThreadTimeStamp
+	}
+
+	return botx.Request{
+		MessageID: id,
+		Chat:      botx.Chat{ID: msg.Channel, Username: msg.User, Type: slackChatType(msg.Channel)},
+		Text:      text,
+		URLs:      urls,
+	}, true
+}
+
+// makeCommandRequest converts the slash command into a command of the bot,
+// e.g. "/newsfeed subscribe <url>" turns into "/subscribe <url>", other
+// slash commands of the app are passed as is.
+func (b *Slack) makeCommandRequest(cmd slack.SlashCommand) botx.Request {
+	text, urls := slackText(strings.TrimSpace(cmd.Text))
+
+	switch {
+	case cmd.Command != b.command:
+		text = strings.TrimSpace(cmd.Command + " " + text)
+	case text == "":
+		text = "/start"
+	default:
+		text = "/" + text
+	}
+
+	return botx.Request{
+		Chat: botx.Chat{
+			ID:       cmd.ChannelID,
+			Username: cmd.UserID,
+			Title:    cmd.ChannelName,
+			Type:     slackChatType(cmd.ChannelID),
+		},
+		Text: text,
+		URLs: urls,
+	}
+}
+
+// makeCallbackRequest converts the click on the button into a callback request.
+func (b *Slack) makeCallbackRequest(envelopeID string, cb slack.InteractionCallback) botx.Request {
+	b.mu.Lock()
+	b.callbacks[envelopeID] = slackCallback{channelID: cb.Channel.ID, userID: cb.User.ID}
+	b.mu.Unlock()
+
+	return botx.Request{
+		Kind:       botx.RequestCallback,
+		MessageID:  cb.Container.MessageTs,
+		Chat:       botx.Chat{ID: cb.Channel.ID, Username: cb.User.ID, Type: slackChatType(cb.Channel.ID)},
+		Text:       cb.ActionCallback.BlockActions[0].Value,
+		CallbackID: envelopeID,
+	}
+}
+
+// slackText converts the text of slack message into the plain text and
+// returns links from it, mentions are turned into @<user id>,
+// so that they match the username of the bot.
+func slackText(text string) (string, []string) {
+	var urls []string
+
+	text = slackLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		sm := slackLinkRe.FindStringSubmatch(m)
+		target, label := sm[1], sm[2]
+
+		switch {
+		case strings.HasPrefix(target, "@"):
+			return target
+		case strings.HasPrefix(target, "#"), strings.HasPrefix(target, "!"):
+			if label != "" {
+				return label
+			}
+			return "@" + strings.TrimPrefix(target, "!")
+		default:
+			urls = append(urls, slackUnescaper.Replace(target))
+			if label != "" {
+				return label
+			}
+			return target
+		}
+	})
+
+	return strings.TrimSpace(slackUnescaper.Replace(text)), urls
+}
+
+// slackChatType returns the type of the chat by the prefix of its ID,
+// IDs of direct messages start with "D", channels with "C" or "G".
+func slackChatType(channelID string) botx.ChatType {
+	if strings.HasPrefix(channelID, "D") {
+		return botx.ChatPrivate
+	}
+	return botx.ChatGroup
+}
+
+// Username returns the user ID of the bot, mentions of the bot
+// are converted into @<user id> in requests.
+func (b *Slack) Username() string { return b.botUserID }
+
+// Stop stops slack bot listener.
+func (b *Slack) Stop() {
+	b.cancel()
+	<-b.done
+	close(b.updates)
+}
+
+// Updates returns updates channel.
+func (b *Slack) Updates() <-chan botx.Request {
+	return b.updates
+}
+
+// SendMessage sends message to slack channel and returns its timestamp,
+// long messages are split into several ones, the timestamp of the first one is returned.
+// In channels replies are sent to the thread of the message, in direct messages
+// they are sent as usual messages.
+func (b *Slack) SendMessage(ctx context.Context, resp botx.Response) (string, error) {
+	var firstID string
+	parts := splitMessage(resp.Text, resp.ParseMode, maxSlackMessageLength)
+	for i, part := range parts {
+		text := mrkdwn(part, resp.ParseMode)
+
+		opts := []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionDisableLinkUnfurl()}
+		// all parts follow the first one into the thread
+		if thread := slackThread(resp.ChatID, resp.ReplyToMessageID); thread != "" {
+			opts = append(opts, slack.MsgOptionTS(thread))
+		}
+		// buttons are placed under the last part
+		if i == len(parts)-1 && len(resp.Buttons) > 0 {
+			opts = append(opts, slack.MsgOptionBlocks(makeBlocks(text, resp.Buttons)...))
+		}
+
+		_, ts, err := b.api.PostMessageContext(ctx, resp.ChatID, opts...)
+		if err != nil {
+			return "", fmt.Errorf("send message part %d of %d: %w", i+1, len(parts), err)
+		}
+
+		if i == 0 {
+			firstID = ts
+		}
+	}
+
+	return firstID, nil
+}
+
+// EditMessage replaces the text of the message, if the new text is too long,
+// the rest of it is sent in new messages.
+func (b *Slack) EditMessage(ctx context.Context, msgID string, resp botx.Response) error {
+	parts := splitMessage(resp.Text, resp.ParseMode, maxSlackMessageLength)
+	text := mrkdwn(parts[0], resp.ParseMode)
+
+	// empty blocks remove buttons of the previous version of the message
+	blocks := []slack.Block{}
+	if len(parts) == 1 && len(resp.Buttons) > 0 {
+		blocks = makeBlocks(text, resp.Buttons)
+	}
+
+	_, _, _, err := b.api.UpdateMessageContext(ctx, resp.ChatID, msgID,
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(blocks...),
+	)
+	if err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+
+	if len(parts) == 1 {
+		return nil
+	}
+
+	resp.Text = strings.Join(parts[1:], "\n\n")
+	resp.ReplyToMessageID = ""
+	if _, err = b.SendMessage(ctx, resp); err != nil {
+		return fmt.Errorf("send the rest of edited message: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMessage deletes the message.
+func (b *Slack) DeleteMessage(ctx context.Context, chatID, msgID string) error {
+	if _, _, err := b.api.DeleteMessageContext(ctx, chatID, msgID); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback answers the click on the button, the text is sent as an
+// ephemeral message, visible only to the user, who clicked the button.
+// Repeated answers to the same interaction are ignored.
+func (b *Slack) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	b.mu.Lock()
+	cb, ok := b.callbacks[callbackID]
+	delete(b.callbacks, callbackID)
+	b.mu.Unlock()
+
+	if !ok || text == "" {
+		return nil
+	}
+
+	_, err := b.api.PostEphemeralContext(ctx, cb.channelID, cb.userID, slack.MsgOptionText(mrkdwnEscaper.Replace(text), false))
+	if err != nil {
+		return fmt.Errorf("answer callback: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerInline returns an error, as slack has no inline queries.
+func (b *Slack) AnswerInline(context.Context, botx.Response) error {
+	return errSlackNoInline
+}
+
+// slackThread returns the thread to reply in, direct messages are not threaded.
+func slackThread(chatID, replyTo string) string {
+	if slackChatType(chatID) == botx.ChatPrivate {
+		return ""
+	}
+	return replyTo
+}
+
+// makeBlocks returns the section with the text and a row of buttons for each row of buttons,
+// the data of the button is passed in its value, as action IDs must be unique within the message.
+func makeBlocks(text string, buttons [][]botx.Button) []slack.Block {
+	blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)}
+	for i, row := range buttons {
+		elements := make([]slack.BlockElement, 0, len(row))
+		for j, btn := range row {
+			elements = append(elements, slack.NewButtonBlockElement(
+				fmt.Sprintf("btn:%d:%d", i, j),
+				btn.Data,
+				slack.NewTextBlockObject(slack.PlainTextType, btn.Text, true, false),
+			))
+		}
+		blocks = append(blocks, slack.NewActionBlock("", elements...))
+	}
+
+	return blocks
+}
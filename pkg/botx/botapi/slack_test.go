@@ -0,0 +1,270 @@
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestSlack(t *testing.T) {
+	srv := newFakeSlack(t)
+
+	b, err := NewSlack(slog.Default(), SlackParams{
+		Token:    "xoxb-token",
+		AppToken: "xapp-token",
+		Command:  "/newsfeed",
+		APIURL:   srv.URL + "/api/",
+	}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "UBOT", b.Username())
+
+	go b.Run()
+	defer b.Stop()
+
+	receive := func(t *testing.T, envelope string) botx.Request {
+		srv.events <- envelope
+		select {
+		case req := <-b.Updates():
+			return req
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no request received")
+			return botx.Request{}
+		}
+	}
+
+	t.Run("message", func(t *testing.T) {
+		req := receive(t, `{"type":"events_api","envelope_id":"e1","payload":{"type":"event_callback","event":{
+			"type":"message","user":"U1","channel":"C1","channel_type":"channel","ts":"1.2","thread_ts":"1.1",
+			"text":"<@UBOT> look &lt;here&gt; <https://example.com/a?x=1&amp;y=2|example>"}}}`)
+		assert.Equal(t, botx.Request{
+			MessageID: "1.1",
+			Chat:      botx.Chat{ID: "C1", Username: "U1", Type: botx.ChatGroup},
+			Text:      "@UBOT look <here> example",
+			URLs:      []string{"https://example.com/a?x=1&y=2"},
+		}, req)
+		assert.Equal(t, "e1", <-srv.acks)
+	})
+
+	t.Run("slash command", func(t *testing.T) {
+		req := receive(t, `{"type":"slash_commands","envelope_id":"e2","payload":{
+			"command":"/newsfeed","text":"subscribe https://example.com/feed",
+			"channel_id":"D1","channel_name":"directmessage","user_id":"U1"}}`)
+		assert.Equal(t, botx.Request{
+			Chat: botx.Chat{ID: "D1", Username: "U1", Title: "directmessage", Type: botx.ChatPrivate},
+			Text: "/subscribe https://example.com/feed",
+		}, req)
+		assert.Equal(t, "e2", <-srv.acks)
+	})
+
+	t.Run("button", func(t *testing.T) {
+		req := receive(t, `{"type":"interactive","envelope_id":"e3","payload":{
+			"type":"block_actions","user":{"id":"U1"},"channel":{"id":"D1"},
+			"container":{"type":"message","message_ts":"2.1"},
+			"actions":[{"type":"button","block_id":"b1","action_id":"btn:0:0","value":"fb:up:abc"}]}}`)
+		assert.Equal(t, botx.Request{
+			Kind:       botx.RequestCallback,
+			MessageID:  "2.1",
+			Chat:       botx.Chat{ID: "D1", Username: "U1", Type: botx.ChatPrivate},
+			Text:       "fb:up:abc",
+			CallbackID: "e3",
+		}, req)
+		assert.Equal(t, "e3", <-srv.acks)
+
+		require.NoError(t, b.AnswerCallback(context.Background(), "e3", "Thank you!"))
+		form := <-srv.calls
+		assert.Equal(t, "chat.postEphemeral", form.Get("method"))
+		assert.Equal(t, "D1", form.Get("channel"))
+		assert.Equal(t, "U1", form.Get("user"))
+		assert.Equal(t, "Thank you!", form.Get("text"))
+
+		// repeated answer is ignored
+		require.NoError(t, b.AnswerCallback(context.Background(), "e3", "Thank you!"))
+		assert.Empty(t, srv.calls)
+	})
+
+	t.Run("send message", func(t *testing.T) {
+		id, err := b.SendMessage(context.Background(), botx.Response{
+			ChatID:           "C1",
+			ReplyToMessageID: "1.1",
+			Text:             `*Title*\. [link](https://example.com/a\))`,
+			ParseMode:        botx.ParseModeMarkdownV2,
+			Buttons:          [][]botx.Button{{{Text: "👍", Data: "fb:up:abc"}}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "3.1", id)
+
+		form := <-srv.calls
+		assert.Equal(t, "chat.postMessage", form.Get("method"))
+		assert.Equal(t, "C1", form.Get("channel"))
+		assert.Equal(t, "1.1", form.Get("thread_ts"))
+		assert.Equal(t, "*Title*. <https://example.com/a)|link>", form.Get("text"))
+
+		var blocks []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(form.Get("blocks")), &blocks))
+		require.Len(t, blocks, 2)
+		assert.Equal(t, "section", blocks[0]["type"])
+		assert.Equal(t, "actions", blocks[1]["type"])
+		assert.Contains(t, form.Get("blocks"), `"value":"fb:up:abc"`)
+	})
+
+	t.Run("edit message removes buttons", func(t *testing.T) {
+		require.NoError(t, b.EditMessage(context.Background(), "3.1", botx.Response{ChatID: "D1", Text: "done"}))
+
+		form := <-srv.calls
+		assert.Equal(t, "chat.update", form.Get("method"))
+		assert.Equal(t, "3.1", form.Get("ts"))
+		assert.Equal(t, "done", form.Get("text"))
+		assert.Equal(t, "[]", form.Get("blocks"))
+	})
+
+	t.Run("inline is not supported", func(t *testing.T) {
+		assert.Error(t, b.AnswerInline(context.Background(), botx.Response{}))
+	})
+}
+
+func TestSlackText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+		urls []string
+	}{
+		{name: "plain", text: "hello", want: "hello"},
+		{name: "escaped", text: "a &amp; b &lt;c&gt;", want: "a & b <c>"},
+		{name: "link", text: "<https://example.com>", want: "https://example.com", urls: []string{"https://example.com"}},
+		{name: "mention", text: "<@U1|john> hi", want: "@U1 hi"},
+		{name: "channel", text: "see <#C1|general>", want: "see general"},
+		{name: "special mention", text: "<!here> look", want: "@here look"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, urls := slackText(tt.text)
+			assert.Equal(t, tt.want, text)
+			assert.Equal(t, tt.urls, urls)
+		})
+	}
+}
+
+func TestMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		mode botx.ParseMode
+		want string
+	}{
+		{name: "plain", text: "a < b & c", mode: botx.ParseModePlain, want: "a &lt; b &amp; c"},
+		{
+			name: "markdown",
+			text: `*bold* _italic_ __under__ ||spoiler|| \- 1 \< 2 ` + "`code`",
+			mode: botx.ParseModeMarkdownV2,
+			want: "*bold* _italic_ _under_ spoiler - 1 &lt; 2 `code`",
+		},
+		{
+			name: "markdown link",
+			text: `[the \[site\]](https://example.com/?a=1\)) end\.`,
+			mode: botx.ParseModeMarkdownV2,
+			want: "<https://example.com/?a=1)|the [site]> end.",
+		},
+		{
+			name: "html",
+			text: `<b>bold</b> <a href="https://example.com/?a=1&amp;b=2">link</a> 1 &lt; 2 <u>u</u>`,
+			mode: botx.ParseModeHTML,
+			want: "*bold* <https://example.com/?a=1&b=2|link> 1 &lt; 2 u",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mrkdwn(tt.text, tt.mode))
+		})
+	}
+}
+
+// fakeSlack is a local slack server, that serves Web API methods
+// and a Socket Mode connection.
+type fakeSlack struct {
+	*httptest.Server
+	events chan string     // envelopes to send over the socket
+	acks   chan string     // envelope IDs of acknowledgements
+	calls  chan url.Values // forms of Web API calls with "method" set
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	s := &fakeSlack{
+		events: make(chan string),
+		acks:   make(chan string, 10),
+		calls:  make(chan url.Values, 10),
+	}
+
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth.test", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"ok": true, "user_id": "UBOT", "bot_id": "BBOT"})
+	})
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xapp-token", r.Header.Get("Authorization"))
+		writeJSON(w, map[string]any{"ok": true, "url": "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"})
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form := r.PostForm
+		form.Set("method", strings.TrimPrefix(r.URL.Path, "/api/"))
+		s.calls <- form
+		writeJSON(w, map[string]any{"ok": true, "channel": form.Get("channel"), "ts": "3.1"})
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		// socket mode client sends the origin of slack
+		upgrader := &websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","num_connections":1}`)))
+
+		// the connection is closed by the client, when the bot stops
+		closed := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case evt := <-s.events:
+					assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(evt)))
+				case <-closed:
+					return
+				}
+			}
+		}()
+
+		for {
+			var ack struct {
+				EnvelopeID string `json:"envelope_id"`
+			}
+			if err := conn.ReadJSON(&ack); err != nil {
+				close(closed)
+				return
+			}
+			s.acks <- ack.EnvelopeID
+		}
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
@@ -0,0 +1,196 @@
+package botapi
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+)
+
+// maxMessageLength is the maximum length of a telegram message in UTF-16 code units.
+const maxMessageLength = 4096
+
+// splitMessage splits the text into parts not longer than limit UTF-16 code units,
+// preferably on paragraph boundaries, then on line boundaries, then on spaces.
+// Formatting entities and escape sequences are never split, unless a single
+// entity is longer than the limit.
+func splitMessage(text string, mode botx.ParseMode, limit int) []string {
+	runes := []rune(text)
+
+	// offsets[i] is the length of runes[:i] in UTF-16 code units
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + len(utf16.Encode([]rune{r}))
+	}
+
+	if offsets[len(runes)] <= limit {
+		return []string{text}
+	}
+
+	// inside[i] is true if the split before runes[i] would break an entity
+	inside := make([]bool, len(runes)+1)
+	for _, span := range entitySpans(runes, mode) {
+		for i := span[0] + 1; i < span[1]; i++ {
+			inside[i] = true
+		}
+	}
+
+	var parts []string
+	start := 0
+	for start < len(runes) {
+		// the farthest end of the part within the limit
+		end := start
+		for end < len(runes) && offsets[end+1]-offsets[start] <= limit {
+			end++
+		}
+
+		if end < len(runes) {
+			end = splitPoint(runes, inside, start, end)
+		}
+
+		if part := strings.TrimSpace(string(runes[start:end])); part != "" {
+			parts = append(parts, part)
+		}
+		start = end
+	}
+
+	return parts
+}
+
+// splitPoint returns the best position to split runes[start:end],
+// end is returned if there is no better place.
+func splitPoint(runes []rune, inside []bool, start, end int) int {
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		sepRunes := []rune(sep)
+		// separator itself may exceed the limit, it is trimmed anyway
+		i := end
+		if i+len(sepRunes) > len(runes) {
+			i = len(runes) - len(sepRunes)
+		}
+		for ; i > start; i-- {
+			if !inside[i] && string(runes[i:i+len(sepRunes)]) == sep {
+				return i + len(sepRunes)
+			}
+		}
+	}
+
+	for i := end; i > start; i-- {
+		if !inside[i] {
+			return i
+		}
+	}
+
+	// the entity is longer than the limit, nothing to do but to cut it
+	return end
+}
+
+// entitySpans returns [start, end) rune ranges of formatting entities.
+func entitySpans(runes []rune, mode botx.ParseMode) [][2]int {
+	switch mode {
+	case botx.ParseModeMarkdownV2:
+		return markdownSpans(runes)
+	case botx.ParseModeHTML:
+		return htmlSpans(runes)
+	default:
+		return nil
+	}
+}
+
+// markdownSpans returns [start, end) rune ranges of markdown entities
+// and escape sequences, entities are made of tokens of botx.TokenizeMarkdownV2.
+func markdownSpans(runes []rune) [][2]int {
+	text := string(runes)
+
+	// runeIdx[i] is the index of the rune, that starts at the byte offset i
+	runeIdx := make([]int, len(text)+1)
+	n := 0
+	for i := range text {
+		runeIdx[i] = n
+		n++
+	}
+	runeIdx[len(text)] = n
+
+	var spans [][2]int
+	var open []botx.MarkdownV2Token
+	for _, tok := range botx.TokenizeMarkdownV2(text) {
+		switch tok.Kind {
+		case botx.MarkdownV2Open, botx.MarkdownV2LinkOpen:
+			open = append(open, tok)
+		case botx.MarkdownV2Close, botx.MarkdownV2LinkClose:
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+
+			// the marker is not closed till the end, it is likely not a marker,
+			// e.g. in snake_case, so the text after it may be split
+			if tok.Start == len(text) && tok.End == len(text) {
+				continue
+			}
+
+			spans = append(spans, [2]int{runeIdx[start.Start], runeIdx[tok.End]})
+		case botx.MarkdownV2Text:
+			for i := tok.Start; i < tok.End; i++ {
+				if text[i] != '\\' || i+1 >= tok.End {
+					continue
+				}
+
+				_, size := utf8.DecodeRuneInString(text[i+1:])
+				spans = append(spans, [2]int{runeIdx[i], runeIdx[i+1+size]})
+				i += size
+			}
+		}
+	}
+
+	return spans
+}
+
+// indexFrom returns the index of the first occurrence of substr
+// in runes, starting from the given index, or -1.
+func indexFrom(runes []rune, from int, substr string) int {
+	sub := []rune(substr)
+	for i := from; i+len(sub) <= len(runes); i++ {
+		if string(runes[i:i+len(sub)]) == substr {
+			return i
+		}
+	}
+	return -1
+}
+
+// htmlSpans returns [start, end) rune ranges of HTML elements,
+// from the opening to the closing tag, and of character references.
+func htmlSpans(runes []rune) [][2]int {
+	var spans [][2]int
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '&':
+			end := indexFrom(runes, i+1, ";")
+			if end < 0 {
+				continue
+			}
+
+			spans = append(spans, [2]int{i, end + 1})
+			i = end
+		case '<':
+			end := indexFrom(runes, i+1, ">")
+			if end < 0 {
+				continue
+			}
+
+			name := strings.FieldsFunc(string(runes[i+1:end]), func(r rune) bool { return r == ' ' || r == '/' })
+			if len(name) == 0 {
+				continue
+			}
+
+			closing := "</" + name[0] + ">"
+			if closeIdx := indexFrom(runes, end+1, closing); closeIdx >= 0 {
+				end = closeIdx + len([]rune(closing)) - 1
+			}
+
+			spans = append(spans, [2]int{i, end + 1})
+			i = end
+		}
+	}
+
+	return spans
+}
@@ -0,0 +1,111 @@
+package botapi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "short",
+			text:  "hello world",
+			limit: 20,
+			want:  []string{"hello world"},
+		},
+		{
+			name:  "paragraphs",
+			text:  "first paragraph\nsecond line\n\nthird paragraph",
+			limit: 30,
+			want:  []string{"first paragraph\nsecond line", "third paragraph"},
+		},
+		{
+			name:  "lines",
+			text:  "first line\nsecond line\nthird line",
+			limit: 25,
+			want:  []string{"first line\nsecond line", "third line"},
+		},
+		{
+			name:  "words",
+			text:  "one two three four five",
+			limit: 10,
+			want:  []string{"one two", "three four", "five"},
+		},
+		{
+			name:  "entity is not split",
+			text:  "see *bold text here* and more",
+			limit: 16,
+			want:  []string{"see", "*bold text here*", "and more"},
+		},
+		{
+			name:  "link is not split",
+			text:  "read [the source](https://example.com/a b) now",
+			limit: 40,
+			want:  []string{"read", "[the source](https://example.com/a b)", "now"},
+		},
+		{
+			name:  "escape sequence is not split",
+			text:  `abcdefghi\_jk`,
+			limit: 10,
+			want:  []string{"abcdefghi", `\_jk`},
+		},
+		{
+			name:  "escaped marker doesn't close entity",
+			text:  `x *ab \* cd ef*`,
+			limit: 14,
+			want:  []string{"x", `*ab \* cd ef*`},
+		},
+		{
+			name:  "unclosed marker is not an entity",
+			text:  "a_b ccc ddd",
+			limit: 6,
+			want:  []string{"a_b", "ccc", "ddd"},
+		},
+		{
+			name:  "entity with multibyte text",
+			text:  "*привет мир* x",
+			limit: 12,
+			want:  []string{"*привет мир*", "x"},
+		},
+		{
+			name:  "too long entity is cut",
+			text:  "`" + strings.Repeat("a", 10) + "`",
+			limit: 8,
+			want:  []string{"`aaaaaaa", "aaa`"},
+		},
+		{
+			name:  "utf-16 length",
+			text:  strings.Repeat("😀", 4),
+			limit: 4,
+			want:  []string{"😀😀", "😀😀"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, splitMessage(tt.text, botx.ParseModeMarkdownV2, tt.limit))
+		})
+	}
+}
+
+func TestSplitMessage_ParseModes(t *testing.T) {
+	text := "see <b>bold text here</b> &amp; more"
+
+	assert.Equal(t, []string{"see", "<b>bold text here</b>", "&amp; more"},
+		splitMessage(text, botx.ParseModeHTML, 22))
+
+	// tags are not special in plain text
+	assert.Equal(t, []string{"see <b>bold", "text here</b>", "&amp; more"},
+		splitMessage(text, botx.ParseModePlain, 15))
+
+	assert.Equal(t, []string{"a ||spoiler text||", "__underlined text__"},
+		splitMessage("a ||spoiler text|| __underlined text__", botx.ParseModeMarkdownV2, 20))
+}
@@ -3,8 +3,14 @@ package botapi
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/Semior001/newsfeed/pkg/botx"
 
@@ -12,10 +18,34 @@ import (
 	"golang.org/x/exp/slog"
 )
 
+// inlineCacheTime is the time in seconds, for which telegram caches
+// answers to inline queries, results may be not ready on the first query,
+// so they must not be cached for long.
+const inlineCacheTime = 1
+
+// inlineStartParameter is sent to the bot within /start command, when the user
+// opens the private chat with the bot from the inline query.
+const inlineStartParameter = "inline"
+
+// secretTokenHeader contains the secret of the webhook in every update from Telegram.
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
 // Telegram is a controller that handles requests from telegram.
+// Updates are received either by long polling with Run, or by webhook
+// with ServeHTTP, after the webhook is registered with SetWebhook.
 type Telegram struct {
+	log     *slog.Logger
 	api     *tgbotapi.BotAPI
 	updates chan botx.Request
+	secret  string
+}
+
+// WebhookParams defines parameters to receive updates by webhook.
+type WebhookParams struct {
+	// URL is a public HTTPS URL, Telegram sends updates to.
+	URL string
+	// Secret is sent by Telegram within every update to verify its origin.
+	Secret string
 }
 
 // NewTelegram returns a new telegram bot controller.
@@ -33,13 +63,19 @@ func NewTelegram(lg *slog.Logger, token string, bufferSize int) (*Telegram, erro
 	}
 
 	return &Telegram{
+		log:     lg,
 		api:     api,
 		updates: make(chan botx.Request, bufferSize),
 	}, nil
 }
 
-// Run runs telegram bot listener until context is dead.
+// Run runs telegram bot listener with long polling until Stop is called.
 func (b *Telegram) Run() {
+	// telegram doesn't allow long polling while the webhook is set
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		b.log.Warn("failed to delete webhook", slog.Any("err", err))
+	}
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := b.api.GetUpdatesChan(u)
@@ -50,21 +86,177 @@ func (b *Telegram) Run() {
 			return
 		}
 
-		if update.Message == nil || update.Message.Chat == nil || update.Message.Text == "" {
-			continue
+		if req, ok := makeRequest(update); ok {
+			b.updates <- req
 		}
+	}
+}
 
-		b.updates <- botx.Request{
+// SetWebhook registers the webhook, so that Telegram sends updates to ServeHTTP.
+// The secret is required, otherwise anyone could send updates to the webhook.
+func (b *Telegram) SetWebhook(params WebhookParams) error {
+	if params.Secret == "" {
+		return errors.New("webhook secret is required")
+	}
+
+	b.secret = params.Secret
+
+	p := tgbotapi.Params{}
+	p.AddNonEmpty("url", params.URL)
+	p.AddNonEmpty("secret_token", params.Secret)
+
+	if _, err := b.api.MakeRequest("setWebhook", p); err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+
+	return nil
+}
+
+// ServeHTTP receives updates from Telegram by webhook.
+func (b *Telegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// no updates are expected, if the webhook is not registered
+	if b.secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(b.secret)) != 1 {
+		b.log.WarnCtx(r.Context(), "webhook request with invalid secret", slog.String("remote_addr", r.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		b.log.WarnCtx(r.Context(), "failed to decode update", slog.Any("err", err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	req, ok := makeRequest(update)
+	if !ok {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	select {
+	case b.updates <- req:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		// telegram will retry the update later
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// makeRequest converts telegram update into a request,
+// returns false if the update is not supported.
+func makeRequest(update tgbotapi.Update) (botx.Request, bool) {
+	if q := update.InlineQuery; q != nil {
+		if q.From == nil {
+			return botx.Request{}, false
+		}
+
+		// user ID is the same as the ID of the private chat with the user
+		return botx.Request{
+			Kind: botx.RequestInline,
 			Chat: botx.Chat{
-				ID:       strconv.FormatInt(update.Message.Chat.ID, 10),
-				Username: update.Message.Chat.UserName,
+				ID:       strconv.FormatInt(q.From.ID, 10),
+				Username: q.From.UserName,
+				Type:     botx.ChatPrivate,
 			},
-			Text: update.Message.Text,
+			Text:          q.Query,
+			InlineQueryID: q.ID,
+		}, true
+	}
+
+	if cb := update.CallbackQuery; cb != nil {
+		// messages older than 48 hours come without the content
+		if cb.Message == nil || cb.Message.Chat == nil {
+			return botx.Request{}, false
+		}
+
+		return botx.Request{
+			Kind:       botx.RequestCallback,
+			MessageID:  strconv.Itoa(cb.Message.MessageID),
+			Chat:       makeChat(cb.Message.Chat),
+			Text:       cb.Data,
+			CallbackID: cb.ID,
+		}, true
+	}
+
+	msg := update.Message
+	if msg == nil || msg.Chat == nil {
+		return botx.Request{}, false
+	}
+
+	// forwarded posts with media have the text in the caption
+	text, entities := msg.Text, msg.Entities
+	if text == "" {
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+
+	if text == "" {
+		return botx.Request{}, false
+	}
+
+	return botx.Request{
+		MessageID: strconv.Itoa(msg.MessageID),
+		Chat:      makeChat(msg.Chat),
+		Text:      text,
+		URLs:      entityURLs(text, entities),
+	}, true
+}
+
+// entityURLs returns links from url and text_link entities of the text.
+func entityURLs(text string, entities []tgbotapi.MessageEntity) []string {
+	var urls []string
+	var utf16Text []uint16
+
+	for _, e := range entities {
+		switch e.Type {
+		case "text_link":
+			urls = append(urls, e.URL)
+		case "url":
+			// offsets of entities are in UTF-16 code units
+			if utf16Text == nil {
+				utf16Text = utf16.Encode([]rune(text))
+			}
+
+			if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(utf16Text) {
+				continue
+			}
+
+			urls = append(urls, string(utf16.Decode(utf16Text[e.Offset:e.Offset+e.Length])))
 		}
 	}
+
+	return urls
+}
+
+// makeChat converts telegram chat, supergroups are treated as groups.
+func makeChat(chat *tgbotapi.Chat) botx.Chat {
+	result := botx.Chat{
+		ID:       strconv.FormatInt(chat.ID, 10),
+		Username: chat.UserName,
+		Title:    chat.Title,
+		Type:     botx.ChatPrivate,
+	}
+
+	switch {
+	case chat.IsGroup(), chat.IsSuperGroup():
+		result.Type = botx.ChatGroup
+	case chat.IsChannel():
+		result.Type = botx.ChatChannel
+	}
+
+	return result
 }
 
-// Stop stops telegram bot listener.
+// Username returns the username of the bot.
+func (b *Telegram) Username() string { return b.api.Self.UserName }
+
+// Stop stops telegram bot listener, in webhook mode
+// it must be called after the HTTP server is shut down.
 func (b *Telegram) Stop() {
 	b.api.StopReceivingUpdates()
 	close(b.updates)
@@ -75,8 +267,59 @@ func (b *Telegram) Updates() <-chan botx.Request {
 	return b.updates
 }
 
-// SendMessage sends message to telegram user.
-func (b *Telegram) SendMessage(ctx context.Context, resp botx.Response) error {
+// SendMessage sends message to telegram user and returns its ID,
+// long messages are split into several ones, the ID of the first one is returned.
+func (b *Telegram) SendMessage(ctx context.Context, resp botx.Response) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	chatID, err := strconv.ParseInt(resp.ChatID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("parse chat id: %w", err)
+	}
+
+	replyTo := 0
+	if resp.ReplyToMessageID != "" {
+		if replyTo, err = strconv.Atoi(resp.ReplyToMessageID); err != nil {
+			return "", fmt.Errorf("parse reply to message id: %w", err)
+		}
+	}
+
+	var firstID string
+	parts := splitMessage(resp.Text, resp.ParseMode, maxMessageLength)
+	for i, part := range parts {
+		// only the first part is a reply, the rest follow it
+		msg, err := b.sendFormatted(ctx, resp.ParseMode, part, func(text, parseMode string) tgbotapi.Chattable {
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.ParseMode = parseMode
+			msg.DisableWebPagePreview = true
+			if i == 0 {
+				msg.ReplyToMessageID = replyTo
+			}
+			// buttons are placed under the last part
+			if i == len(parts)-1 {
+				msg.ReplyMarkup = makeKeyboard(resp.Buttons)
+			}
+			return msg
+		})
+		if err != nil {
+			return "", fmt.Errorf("send message part %d of %d: %w", i+1, len(parts), err)
+		}
+
+		if i == 0 {
+			firstID = strconv.Itoa(msg.MessageID)
+		}
+	}
+
+	return firstID, nil
+}
+
+// EditMessage replaces the text of the message, if the new text is too long,
+// the rest of it is sent in new messages.
+func (b *Telegram) EditMessage(ctx context.Context, msgID string, resp botx.Response) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -88,18 +331,179 @@ func (b *Telegram) SendMessage(ctx context.Context, resp botx.Response) error {
 		return fmt.Errorf("parse chat id: %w", err)
 	}
 
-	msg := tgbotapi.NewMessage(chatID, resp.Text)
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	msg.DisableWebPagePreview = true
-	if resp.ReplyToMessageID != "" {
-		if msg.ReplyToMessageID, err = strconv.Atoi(resp.ReplyToMessageID); err != nil {
-			return fmt.Errorf("parse reply to message id: %w", err)
+	id, err := strconv.Atoi(msgID)
+	if err != nil {
+		return fmt.Errorf("parse message id: %w", err)
+	}
+
+	parts := splitMessage(resp.Text, resp.ParseMode, maxMessageLength)
+
+	_, err = b.sendFormatted(ctx, resp.ParseMode, parts[0], func(text, parseMode string) tgbotapi.Chattable {
+		edit := tgbotapi.NewEditMessageText(chatID, id, text)
+		edit.ParseMode = parseMode
+		edit.DisableWebPagePreview = true
+		if len(parts) == 1 {
+			edit.ReplyMarkup = makeKeyboard(resp.Buttons)
 		}
+		return edit
+	})
+	if err != nil && !isNotModifiedError(err) {
+		return fmt.Errorf("edit message: %w", err)
+	}
+
+	if len(parts) == 1 {
+		return nil
+	}
+
+	resp.Text = strings.Join(parts[1:], "\n\n")
+	resp.ReplyToMessageID = ""
+	if _, err = b.SendMessage(ctx, resp); err != nil {
+		return fmt.Errorf("send the rest of edited message: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteMessage deletes the message.
+func (b *Telegram) DeleteMessage(ctx context.Context, chatID, msgID string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	cid, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse chat id: %w", err)
+	}
+
+	id, err := strconv.Atoi(msgID)
+	if err != nil {
+		return fmt.Errorf("parse message id: %w", err)
+	}
+
+	if _, err = b.api.Request(tgbotapi.NewDeleteMessage(cid, id)); err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerCallback answers the callback query, the text is shown as a notification.
+// Repeated answers to the same query are ignored.
+func (b *Telegram) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if _, err := b.api.Request(tgbotapi.NewCallback(callbackID, text)); err != nil && !isQueryAnsweredError(err) {
+		return fmt.Errorf("answer callback: %w", err)
+	}
+
+	return nil
+}
+
+// AnswerInline answers the inline query with articles, texts of
+// the articles are cut to the maximum length of the message.
+func (b *Telegram) AnswerInline(ctx context.Context, resp botx.Response) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	results := make([]interface{}, 0, len(resp.InlineResults))
+	for _, r := range resp.InlineResults {
+		results = append(results, tgbotapi.InlineQueryResultArticle{
+			Type:        "article",
+			ID:          r.ID,
+			Title:       r.Title,
+			Description: r.Description,
+			InputMessageContent: tgbotapi.InputTextMessageContent{
+				Text:                  splitMessage(r.Text, r.ParseMode, maxMessageLength)[0],
+				ParseMode:             string(r.ParseMode),
+				DisableWebPagePreview: true,
+			},
+		})
+	}
+
+	cfg := tgbotapi.InlineConfig{
+		InlineQueryID: resp.InlineQueryID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		// results depend on the preferences of the user
+		IsPersonal: true,
+	}
+	if resp.Text != "" {
+		cfg.SwitchPMText = resp.Text
+		cfg.SwitchPMParameter = inlineStartParameter
 	}
 
-	if _, err = b.api.Send(msg); err != nil {
-		return fmt.Errorf("send message: %w", err)
+	if _, err := b.api.Request(cfg); err != nil {
+		return fmt.Errorf("answer inline query: %w", err)
 	}
 
 	return nil
 }
+
+// makeKeyboard converts buttons into an inline keyboard, returns nil
+// if there are no buttons, as telegram rejects empty keyboards.
+func makeKeyboard(buttons [][]botx.Button) *tgbotapi.InlineKeyboardMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+	for _, row := range buttons {
+		kbRow := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, btn := range row {
+			kbRow = append(kbRow, tgbotapi.NewInlineKeyboardButtonData(btn.Text, btn.Data))
+		}
+		rows = append(rows, kbRow)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// sendFormatted sends the message built by the function,
+// and sends it again as plain text if telegram rejects its formatting.
+func (b *Telegram) sendFormatted(
+	ctx context.Context,
+	mode botx.ParseMode,
+	text string,
+	build func(text, parseMode string) tgbotapi.Chattable,
+) (tgbotapi.Message, error) {
+	msg, err := b.api.Send(build(text, string(mode)))
+	if err == nil || mode == botx.ParseModePlain || !isEntitiesError(err) {
+		return msg, err
+	}
+
+	b.log.WarnCtx(ctx, "telegram rejected formatting, sending as plain text",
+		slog.String("parse_mode", string(mode)), slog.Any("err", err))
+
+	return b.api.Send(build(mode.Strip(text), string(botx.ParseModePlain)))
+}
+
+// isEntitiesError returns true if telegram couldn't parse the formatting of the message.
+func isEntitiesError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(tgErr.Message, "can't parse entities")
+}
+
+// isNotModifiedError returns true if the edited message is the same as before.
+func isNotModifiedError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(tgErr.Message, "message is not modified")
+}
+
+// isQueryAnsweredError returns true if the callback query has already been answered or expired.
+func isQueryAnsweredError(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusBadRequest &&
+		strings.Contains(tgErr.Message, "query is too old")
+}
@@ -0,0 +1,129 @@
+package botapi
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Semior001/newsfeed/pkg/botx"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+//go:embed data/test/update.json
+var updateJSON []byte
+
+//go:embed data/test/callback.json
+var callbackJSON []byte
+
+//go:embed data/test/inline_query.json
+var inlineQueryJSON []byte
+
+//go:embed data/test/group_message.json
+var groupMessageJSON []byte
+
+func TestTelegram_ServeHTTP(t *testing.T) {
+	b := &Telegram{
+		log:     slog.Default(),
+		updates: make(chan botx.Request, 1),
+		secret:  "secret",
+	}
+
+	ts := httptest.NewServer(b)
+	defer ts.Close()
+
+	post := func(secret string, body []byte) int {
+		req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(secretTokenHeader, secret)
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, post("wrong", updateJSON))
+		assert.Equal(t, http.StatusUnauthorized, post("", updateJSON))
+		assert.Empty(t, b.updates)
+	})
+
+	t.Run("no secret", func(t *testing.T) {
+		b.secret = ""
+		defer func() { b.secret = "secret" }()
+
+		assert.Equal(t, http.StatusUnauthorized, post("", updateJSON))
+		assert.Empty(t, b.updates)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("secret", []byte("{")))
+		assert.Empty(t, b.updates)
+	})
+
+	t.Run("unsupported update", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("secret", []byte(`{"update_id": 1}`)))
+		assert.Empty(t, b.updates)
+	})
+
+	t.Run("message", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("secret", updateJSON))
+		require.Len(t, b.updates, 1)
+		assert.Equal(t, botx.Request{
+			MessageID: "1365",
+			Chat:      botx.Chat{ID: "1111111", Username: "testuser", Type: botx.ChatPrivate},
+			Text:      "https://example.com/article",
+		}, <-b.updates)
+	})
+	t.Run("group message", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("secret", groupMessageJSON))
+		require.Len(t, b.updates, 1)
+		assert.Equal(t, botx.Request{
+			MessageID: "42",
+			Chat:      botx.Chat{ID: "-1001234567890", Type: botx.ChatGroup, Title: "Test group"},
+			Text:      "/start@newsfeedbot",
+		}, <-b.updates)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("secret", callbackJSON))
+		require.Len(t, b.updates, 1)
+		assert.Equal(t, botx.Request{
+			Kind:       botx.RequestCallback,
+			MessageID:  "1366",
+			Chat:       botx.Chat{ID: "1111111", Username: "testuser", Type: botx.ChatPrivate},
+			Text:       "fb:up:0123456789abcdef",
+			CallbackID: "4382bfdwdsb323b2d9",
+		}, <-b.updates)
+	})
+	t.Run("inline query", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post("secret", inlineQueryJSON))
+		require.Len(t, b.updates, 1)
+		assert.Equal(t, botx.Request{
+			Kind:          botx.RequestInline,
+			Chat:          botx.Chat{ID: "1111111", Username: "testuser", Type: botx.ChatPrivate},
+			Text:          "https://example.com/article",
+			InlineQueryID: "1234567890123456789",
+		}, <-b.updates)
+	})
+}
+
+func TestEntityURLs(t *testing.T) {
+	text := "Новость 👉 example.com/a и подробности"
+	entities := []tgbotapi.MessageEntity{
+		{Type: "bold", Offset: 0, Length: 7},
+		// the emoji takes two UTF-16 code units
+		{Type: "url", Offset: 11, Length: 13},
+		{Type: "text_link", Offset: 27, Length: 11, URL: "https://example.com/b"},
+		{Type: "url", Offset: 100, Length: 5},
+	}
+
+	assert.Equal(t, []string{"example.com/a", "https://example.com/b"}, entityURLs(text, entities))
+	assert.Empty(t, entityURLs("no links", nil))
+}
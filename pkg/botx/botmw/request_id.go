@@ -2,7 +2,6 @@ package botmw
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/Semior001/newsfeed/pkg/botx"
 	"github.com/Semior001/newsfeed/pkg/logx"
@@ -34,7 +33,7 @@ func AppendRequestIDOnError() botx.Middleware {
 
 			hasRequester := false
 			for i := range resps {
-				resps[i].Text += fmt.Sprintf("\n\nRequest ID: `%s`", reqID)
+				resps[i].Text += "\n\nRequest ID: " + resps[i].ParseMode.Code(reqID)
 				if resps[i].ChatID == req.Chat.ID {
 					hasRequester = true
 				}
@@ -43,9 +42,9 @@ func AppendRequestIDOnError() botx.Middleware {
 			if !hasRequester {
 				resps = append(resps, botx.Response{
 					ChatID: req.Chat.ID,
-					Text: fmt.Sprintf("Something went wrong. "+
-						"Please, ask admin for help."+
-						"\n\nRequest ID: `%s`", reqID),
+					Text: "Something went wrong\\. Please, ask admin for help\\." +
+						"\n\nRequest ID: " + botx.ParseModeMarkdownV2.Code(reqID),
+					ParseMode: botx.ParseModeMarkdownV2,
 				})
 			}
 
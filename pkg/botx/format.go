@@ -0,0 +1,114 @@
+package botx
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ParseMode specifies how the text of the response is formatted.
+type ParseMode string
+
+// Parse modes.
+const (
+	// ParseModePlain is a text without any formatting.
+	ParseModePlain ParseMode = ""
+	// ParseModeMarkdownV2 is a telegram's flavour of markdown,
+	// see https://core.telegram.org/bots/api#markdownv2-style
+	ParseModeMarkdownV2 ParseMode = "MarkdownV2"
+	// ParseModeHTML is a subset of HTML tags,
+	// see https://core.telegram.org/bots/api#html-style
+	ParseModeHTML ParseMode = "HTML"
+)
+
+var (
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`,
+		`_`, `\_`,
+		`*`, `\*`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`(`, `\(`,
+		`)`, `\)`,
+		`~`, `\~`,
+		"`", "\\`",
+		`>`, `\>`,
+		`#`, `\#`,
+		`+`, `\+`,
+		`-`, `\-`,
+		`=`, `\=`,
+		`|`, `\|`,
+		`{`, `\{`,
+		`}`, `\}`,
+		`.`, `\.`,
+		`!`, `\!`,
+	)
+	// inside (...) part of links and inside code only these are special
+	markdownV2URLEscaper  = strings.NewReplacer(`\`, `\\`, `)`, `\)`)
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+)
+
+// Escape escapes the text, so that it is displayed as is.
+func (m ParseMode) Escape(s string) string {
+	switch m {
+	case ParseModeMarkdownV2:
+		return markdownV2Escaper.Replace(s)
+	case ParseModeHTML:
+		return html.EscapeString(s)
+	default:
+		return s
+	}
+}
+
+// EscapeURL escapes the URL to be used as a link target.
+func (m ParseMode) EscapeURL(s string) string {
+	switch m {
+	case ParseModeMarkdownV2:
+		return markdownV2URLEscaper.Replace(s)
+	case ParseModeHTML:
+		return html.EscapeString(s)
+	default:
+		return s
+	}
+}
+
+// Code formats the text as inline code.
+func (m ParseMode) Code(s string) string {
+	switch m {
+	case ParseModeMarkdownV2:
+		return "`" + markdownV2CodeEscaper.Replace(s) + "`"
+	case ParseModeHTML:
+		return "<code>" + html.EscapeString(s) + "</code>"
+	default:
+		return s
+	}
+}
+
+var (
+	htmlLinkRe = regexp.MustCompile(`(?s)<a\s+href="([^"]*)"\s*>(.*?)</a>`)
+	htmlTagRe  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Strip removes formatting from the text, leaving the text as it is displayed,
+// links are kept in parentheses after their text.
+func (m ParseMode) Strip(s string) string {
+	switch m {
+	case ParseModeMarkdownV2:
+		sb := &strings.Builder{}
+		for _, tok := range TokenizeMarkdownV2(s) {
+			switch tok.Kind {
+			case MarkdownV2Text:
+				_, _ = sb.WriteString(tok.Text)
+			case MarkdownV2LinkClose:
+				_, _ = sb.WriteString(" (" + tok.Text + ")")
+			}
+		}
+		return sb.String()
+	case ParseModeHTML:
+		s = htmlLinkRe.ReplaceAllString(s, "$2 ($1)")
+		s = htmlTagRe.ReplaceAllString(s, "")
+		return html.UnescapeString(s)
+	default:
+		return s
+	}
+}
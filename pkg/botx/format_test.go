@@ -0,0 +1,29 @@
+package botx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMode_Escape(t *testing.T) {
+	const text = `Price: 10-15$ (approx.) *not* <b>bold</b> & a_b!`
+
+	assert.Equal(t, `Price: 10\-15$ \(approx\.\) \*not\* <b\>bold</b\> & a\_b\!`, ParseModeMarkdownV2.Escape(text))
+	assert.Equal(t, `Price: 10-15$ (approx.) *not* &lt;b&gt;bold&lt;/b&gt; &amp; a_b!`, ParseModeHTML.Escape(text))
+	assert.Equal(t, text, ParseModePlain.Escape(text))
+
+	// escaped text is displayed as is
+	assert.Equal(t, text, ParseModeMarkdownV2.Strip(ParseModeMarkdownV2.Escape(text)))
+	assert.Equal(t, text, ParseModeHTML.Strip(ParseModeHTML.Escape(text)))
+}
+
+func TestParseMode_Strip(t *testing.T) {
+	assert.Equal(t, "Title\nsource (https://example.com/a_(b))",
+		ParseModeMarkdownV2.Strip("*Title*\n[source](https://example.com/a_(b\\))"))
+	assert.Equal(t, "Title & co\nsource (https://example.com/?a=1&b=2)",
+		ParseModeHTML.Strip(`<b>Title &amp; co</b>`+"\n"+`<a href="https://example.com/?a=1&amp;b=2">source</a>`))
+	assert.Equal(t, "id: `abc`", ParseModePlain.Strip("id: `abc`"))
+	assert.Equal(t, "`a\\`b`", ParseModeMarkdownV2.Code("a`b"))
+	assert.Equal(t, "<code>a&lt;b</code>", ParseModeHTML.Code("a<b"))
+}
@@ -17,26 +17,125 @@ func (h Handler) With(mvs ...func(Handler) Handler) Handler {
 	return base
 }
 
+// Command is a command of the bot, shown to users on platforms,
+// that support it, e.g. discord slash commands.
+type Command struct {
+	Name        string // without the leading slash
+	Description string
+}
+
 // Response is a response from handler.
 type Response struct {
 	ReplyToMessageID string
 	ChatID           string
 	Text             string
+	ParseMode        ParseMode
+	// Buttons are attached under the message, each slice is a row of buttons.
+	Buttons [][]Button
+	// Card, if set, is shown instead of the text on platforms,
+	// that support rich messages, like discord embeds.
+	Card *Card
+
+	// CallbackID makes the response an answer to the callback query
+	// with such ID, the text is shown to the user as a notification.
+	CallbackID string
+
+	// InlineQueryID makes the response an answer to the inline query
+	// with such ID, the text, if set, is shown above the results on
+	// the button, that opens the private chat with the bot.
+	InlineQueryID string
+	InlineResults []InlineResult
+}
+
+// InlineResult is a message, that the user can choose to send
+// in response to the inline query.
+type InlineResult struct {
+	ID          string
+	Title       string
+	Description string
+	Text        string
+	ParseMode   ParseMode
+}
+
+// Card is a structured message, e.g. a summary of the article,
+// all fields are plain text.
+type Card struct {
+	Title       string
+	URL         string
+	Author      string
+	Description string
+	Footer      string
 }
 
+// Button is an inline button, pressing it makes a callback request.
+type Button struct {
+	Text string
+	// Data is sent in the text of the callback request,
+	// it is used to route the callback, see Router.AddCallback.
+	Data string
+}
+
+// RequestKind specifies the kind of the request.
+type RequestKind int
+
+// Kinds of requests.
+const (
+	// RequestMessage is a text message from the user.
+	RequestMessage RequestKind = iota
+	// RequestCallback is made when the user presses a button,
+	// its text contains the data of the button.
+	RequestCallback
+	// RequestInline is made when the user types the bot's username
+	// in any chat, its text contains the query after the username,
+	// the chat is the private chat with the user.
+	RequestInline
+)
+
 // Request is a request for handler.
 type Request struct {
+	Kind RequestKind
+	// MessageID is the ID of the message, for callbacks
+	// it is the ID of the message with the pressed button.
 	MessageID string
 	Chat      Chat
 	Text      string
+	// URLs are links from the formatting of the message, e.g. links
+	// hidden under the text, which are not present in the text itself.
+	URLs []string
+	// CallbackID identifies the callback query to answer it.
+	CallbackID string
+	// InlineQueryID identifies the inline query to answer it.
+	InlineQueryID string
+	// Addressed is set by the router for messages, that are sent
+	// in the private chat, are commands, or mention the bot in groups.
+	Addressed bool
 }
 
 // Chat contains chat information.
 type Chat struct {
 	ID       string
 	Username string
+	// Type of the chat, private if empty.
+	Type ChatType
+	// Title of the group or channel.
+	Title string
+	// Platform is the name of the messaging platform of the chat, set by Mux.
+	Platform string
 }
 
+// ChatType specifies the type of the chat.
+type ChatType string
+
+// Chat types.
+const (
+	ChatPrivate ChatType = "private"
+	ChatGroup   ChatType = "group"
+	ChatChannel ChatType = "channel"
+)
+
+// IsGroup returns true if the chat is a group chat.
+func (c Chat) IsGroup() bool { return c.Type == ChatGroup }
+
 // NotFound is a default handler for not found commands.
 func NotFound(_ context.Context, req Request) ([]Response, error) {
 	return []Response{{
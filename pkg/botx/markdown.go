@@ -0,0 +1,253 @@
+package botx
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MarkdownV2TokenKind specifies the kind of the token.
+type MarkdownV2TokenKind int
+
+// Kinds of tokens.
+const (
+	// MarkdownV2Text is a text with escapes removed.
+	MarkdownV2Text MarkdownV2TokenKind = iota
+	// MarkdownV2Open opens the formatting of the marker, e.g. "*" or "||".
+	MarkdownV2Open
+	// MarkdownV2Close closes the formatting of the marker.
+	MarkdownV2Close
+	// MarkdownV2LinkOpen starts the text of the link.
+	MarkdownV2LinkOpen
+	// MarkdownV2LinkClose ends the text of the link.
+	MarkdownV2LinkClose
+)
+
+// MarkdownV2Token is a part of the text in telegram's markdown.
+type MarkdownV2Token struct {
+	Kind MarkdownV2TokenKind
+	// Text is the unescaped text for MarkdownV2Text, the marker for
+	// MarkdownV2Open and MarkdownV2Close, and the unescaped URL for links.
+	Text string
+	// Start and End are byte offsets of the token in the source text, the
+	// link close spans "](url)". Markers, that are closed or reopened by the
+	// tokenizer to keep tokens balanced, are empty at the place they're made.
+	Start, End int
+}
+
+// markdownV2Markers are formatting markers, longer ones go first.
+var markdownV2Markers = []string{"```", "`", "||", "__", "*", "_", "~"}
+
+// TokenizeMarkdownV2 splits the text in telegram's markdown into tokens.
+// Markers inside code are a part of its text. Tokens are balanced: markers,
+// that are left open, are closed at the end of the text or of the link,
+// and overlapping ones are closed and reopened, so that the tokens
+// can be converted into well-formed HTML.
+func TokenizeMarkdownV2(s string) []MarkdownV2Token {
+	t := &markdownV2Tokenizer{}
+
+	for i := 0; i < len(s); {
+		limit := len(s) // code and links don't go beyond the end of the link
+		if t.link != nil {
+			limit = t.link.end
+		}
+
+		switch {
+		case t.link != nil && i == t.link.end:
+			next := t.link.next
+			t.closeTo(t.link.depth, i)
+			i = next
+		case s[i] == '\\' && i+1 < len(s):
+			r, size := utf8.DecodeRuneInString(s[i+1:])
+			t.write(i, string(r))
+			i += 1 + size
+		case s[i] == '[' && t.link == nil:
+			link, ok := parseMarkdownV2Link(s, i)
+			if !ok {
+				t.write(i, s[i:i+1])
+				i++
+				continue
+			}
+
+			link.depth = len(t.open)
+			t.link = &link
+			t.open = append(t.open, "[")
+			t.emit(MarkdownV2LinkOpen, link.url, i, i+1)
+			i++
+		case s[i] == '`':
+			marker := "`"
+			if strings.HasPrefix(s[i:limit], "```") {
+				marker = "```"
+			}
+
+			code, next, closed := scanMarkdownV2Code(s[:limit], i+len(marker), marker)
+			t.emit(MarkdownV2Open, marker, i, i+len(marker))
+			if code != "" {
+				t.write(i+len(marker), code)
+			}
+
+			end := next
+			if closed {
+				end -= len(marker)
+			}
+			t.emit(MarkdownV2Close, marker, end, next)
+			i = next
+		default:
+			if marker, ok := markdownV2Marker(s[i:limit]); ok {
+				t.toggle(marker, i, i+len(marker))
+				i += len(marker)
+				continue
+			}
+
+			t.write(i, s[i:i+1])
+			i++
+		}
+	}
+
+	t.closeTo(0, len(s))
+	t.flush(len(s))
+
+	return t.tokens
+}
+
+type markdownV2Tokenizer struct {
+	tokens    []MarkdownV2Token
+	text      strings.Builder // pending text
+	textStart int             // offset of the pending text in the source
+	open      []string        // open markers, "[" for the link
+	link      *markdownV2Link // the link being written
+}
+
+type markdownV2Link struct {
+	url   string
+	end   int // index of the closing bracket of the text
+	next  int // index after the URL
+	depth int // number of markers open before the link
+}
+
+// toggle closes the marker at [start, end), if it is open inside
+// the current link, otherwise opens it.
+func (t *markdownV2Tokenizer) toggle(marker string, start, end int) {
+	for i := len(t.open) - 1; i >= 0 && t.open[i] != "["; i-- {
+		if t.open[i] != marker {
+			continue
+		}
+
+		above := append([]string(nil), t.open[i+1:]...)
+		t.closeTo(i+1, start)
+		t.open = t.open[:i]
+		t.emit(MarkdownV2Close, marker, start, end)
+		for _, m := range above {
+			t.emit(MarkdownV2Open, m, end, end)
+			t.open = append(t.open, m)
+		}
+		return
+	}
+
+	t.emit(MarkdownV2Open, marker, start, end)
+	t.open = append(t.open, marker)
+}
+
+// closeTo closes open markers at the offset, leaving the first n of them open.
+func (t *markdownV2Tokenizer) closeTo(n, at int) {
+	for len(t.open) > n {
+		marker := t.open[len(t.open)-1]
+		t.open = t.open[:len(t.open)-1]
+
+		if marker == "[" {
+			t.emit(MarkdownV2LinkClose, t.link.url, t.link.end, t.link.next)
+			t.link = nil
+			continue
+		}
+
+		t.emit(MarkdownV2Close, marker, at, at)
+	}
+}
+
+// write adds the unescaped text, that starts at the offset, to the pending text.
+func (t *markdownV2Tokenizer) write(at int, text string) {
+	if t.text.Len() == 0 {
+		t.textStart = at
+	}
+	_, _ = t.text.WriteString(text)
+}
+
+func (t *markdownV2Tokenizer) emit(kind MarkdownV2TokenKind, text string, start, end int) {
+	t.flush(start)
+	t.tokens = append(t.tokens, MarkdownV2Token{Kind: kind, Text: text, Start: start, End: end})
+}
+
+// flush emits the pending text, that ends at the offset.
+func (t *markdownV2Tokenizer) flush(end int) {
+	if t.text.Len() == 0 {
+		return
+	}
+
+	t.tokens = append(t.tokens, MarkdownV2Token{
+		Kind:  MarkdownV2Text,
+		Text:  t.text.String(),
+		Start: t.textStart,
+		End:   end,
+	})
+	t.text.Reset()
+}
+
+// markdownV2Marker returns the formatting marker at the start of s.
+func markdownV2Marker(s string) (string, bool) {
+	for _, m := range markdownV2Markers {
+		if strings.HasPrefix(s, m) {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// parseMarkdownV2Link parses the link "[text](url)", that starts at the index.
+func parseMarkdownV2Link(s string, start int) (markdownV2Link, bool) {
+	end := indexMarkdownV2(s, start+1, ']')
+	if end < 0 || !strings.HasPrefix(s[end+1:], "(") {
+		return markdownV2Link{}, false
+	}
+
+	urlEnd := indexMarkdownV2(s, end+2, ')')
+	if urlEnd < 0 {
+		return markdownV2Link{}, false
+	}
+
+	url, _, _ := scanMarkdownV2Code(s[:urlEnd], end+2, "")
+	return markdownV2Link{url: url, end: end, next: urlEnd + 1}, true
+}
+
+// indexMarkdownV2 returns the index of the first unescaped byte c
+// in s starting from the index, or -1 if there is none.
+func indexMarkdownV2(s string, from int, c byte) int {
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// scanMarkdownV2Code returns the unescaped text from the index up to
+// the closing marker and the index after it, the text runs to the end
+// of s, if the marker is empty or is not closed.
+func scanMarkdownV2Code(s string, from int, marker string) (text string, next int, closed bool) {
+	sb := &strings.Builder{}
+	for i := from; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			r, size := utf8.DecodeRuneInString(s[i+1:])
+			_, _ = sb.WriteRune(r)
+			i += 1 + size
+		case marker != "" && strings.HasPrefix(s[i:], marker):
+			return sb.String(), i + len(marker), true
+		default:
+			_ = sb.WriteByte(s[i])
+			i++
+		}
+	}
+	return sb.String(), len(s), false
+}
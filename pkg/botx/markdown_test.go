@@ -0,0 +1,111 @@
+package botx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenizeMarkdownV2(t *testing.T) {
+	text := func(s string) MarkdownV2Token { return MarkdownV2Token{Kind: MarkdownV2Text, Text: s} }
+	open := func(s string) MarkdownV2Token { return MarkdownV2Token{Kind: MarkdownV2Open, Text: s} }
+	closing := func(s string) MarkdownV2Token { return MarkdownV2Token{Kind: MarkdownV2Close, Text: s} }
+
+	tests := []struct {
+		name string
+		text string
+		want []MarkdownV2Token
+	}{
+		{name: "escapes", text: `a \* b\.`, want: []MarkdownV2Token{text("a * b.")}},
+		{
+			name: "nested",
+			text: `*bold __under__* ||x||`,
+			want: []MarkdownV2Token{
+				open("*"), text("bold "), open("__"), text("under"), closing("__"), closing("*"),
+				text(" "), open("||"), text("x"), closing("||"),
+			},
+		},
+		{
+			name: "code",
+			text: "`a*b \\` c` ```pre```",
+			want: []MarkdownV2Token{
+				open("`"), text("a*b ` c"), closing("`"), text(" "), open("```"), text("pre"), closing("```"),
+			},
+		},
+		{
+			name: "link",
+			text: `[a *b*](https://example.com/a_(b\))`,
+			want: []MarkdownV2Token{
+				{Kind: MarkdownV2LinkOpen, Text: "https://example.com/a_(b)"},
+				text("a "), open("*"), text("b"), closing("*"),
+				{Kind: MarkdownV2LinkClose, Text: "https://example.com/a_(b)"},
+			},
+		},
+		{name: "not a link", text: `[a] (b)`, want: []MarkdownV2Token{text("[a] (b)")}},
+		{
+			name: "unbalanced",
+			text: `*bold _italic`,
+			want: []MarkdownV2Token{open("*"), text("bold "), open("_"), text("italic"), closing("_"), closing("*")},
+		},
+		{
+			name: "overlapping",
+			text: `*a _b* c_`,
+			want: []MarkdownV2Token{
+				open("*"), text("a "), open("_"), text("b"), closing("_"), closing("*"),
+				open("_"), text(" c"), closing("_"),
+			},
+		},
+		{
+			name: "open in link",
+			text: `[*a](u) b`,
+			want: []MarkdownV2Token{
+				{Kind: MarkdownV2LinkOpen, Text: "u"}, open("*"), text("a"), closing("*"),
+				{Kind: MarkdownV2LinkClose, Text: "u"}, text(" b"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// offsets are checked in TestTokenizeMarkdownV2_Offsets
+			tokens := TokenizeMarkdownV2(tt.text)
+			for i := range tokens {
+				tokens[i].Start, tokens[i].End = 0, 0
+			}
+			assert.Equal(t, tt.want, tokens)
+		})
+	}
+}
+
+func TestTokenizeMarkdownV2_Offsets(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string // source of each token
+	}{
+		{name: "escapes", text: `a \* b\.`, want: []string{`a \* b\.`}},
+		{name: "nested", text: `*b __u__*`, want: []string{"*", "b ", "__", "u", "__", "*"}},
+		{name: "code", text: "`a\\`b` ```c```", want: []string{"`", "a\\`b", "`", " ", "```", "c", "```"}},
+		{name: "empty code", text: "``", want: []string{"`", "`"}},
+		{name: "unclosed code", text: "x `y", want: []string{"x ", "`", "y", ""}},
+		{
+			name: "link",
+			text: `[a *b*](https://example.com/a_(b\)) c`,
+			want: []string{"[", "a ", "*", "b", "*", `](https://example.com/a_(b\))`, " c"},
+		},
+		{name: "unbalanced", text: `*bold _it`, want: []string{"*", "bold ", "_", "it", "", ""}},
+		{name: "overlapping", text: `*a _b* c_`, want: []string{"*", "a ", "_", "b", "", "*", "", " c", "_"}},
+		{name: "open in link", text: `[*a](u)`, want: []string{"[", "*", "a", "", "](u)"}},
+		{name: "unicode", text: `*привет* мир`, want: []string{"*", "привет", "*", " мир"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range TokenizeMarkdownV2(tt.text) {
+				got = append(got, tt.text[tok.Start:tok.End])
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package botx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Mux is an API, that combines APIs of several platforms into one.
+// Updates of all platforms are received from one channel, IDs of chats,
+// callbacks and inline queries of all platforms but the primary one are
+// qualified with the name of the platform, e.g. "slack:C123", so that
+// responses are sent to the platform, the request came from.
+// IDs of the primary platform are left as is, so that the IDs, that
+// were stored before other platforms were added, remain valid.
+type Mux struct {
+	primary string
+	apis    map[string]API
+	updates chan Request
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMux returns a new multiplexer of APIs by names of their platforms.
+func NewMux(primary string, apis map[string]API, bufferSize int) (*Mux, error) {
+	if _, ok := apis[primary]; !ok {
+		return nil, fmt.Errorf("primary platform %q is not set", primary)
+	}
+
+	for name := range apis {
+		if name == "" || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("invalid platform name %q", name)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Mux{
+		primary: primary,
+		apis:    apis,
+		updates: make(chan Request, bufferSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Run forwards updates of all platforms until Stop is called,
+// platforms themselves are run and stopped by the caller.
+func (m *Mux) Run() {
+	defer close(m.done)
+
+	wg := &sync.WaitGroup{}
+	for name, api := range m.apis {
+		wg.Add(1)
+		go func(name string, api API) {
+			defer wg.Done()
+			m.forward(name, api)
+		}(name, api)
+	}
+
+	wg.Wait()
+}
+
+func (m *Mux) forward(name string, api API) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case req, ok := <-api.Updates():
+			if !ok {
+				return
+			}
+
+			select {
+			case m.updates <- m.qualify(name, req):
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// qualify sets the platform of the request and prefixes its IDs with it.
+func (m *Mux) qualify(name string, req Request) Request {
+	req.Chat.Platform = name
+	if name == m.primary {
+		return req
+	}
+
+	req.Chat.ID = name + ":" + req.Chat.ID
+	if req.CallbackID != "" {
+		req.CallbackID = name + ":" + req.CallbackID
+	}
+	if req.InlineQueryID != "" {
+		req.InlineQueryID = name + ":" + req.InlineQueryID
+	}
+
+	return req
+}
+
+// route returns the API of the platform, the ID belongs to,
+// and the ID without the platform prefix.
+func (m *Mux) route(id string) (API, string) {
+	if name, rest, ok := strings.Cut(id, ":"); ok && name != m.primary {
+		if api, ok := m.apis[name]; ok {
+			return api, rest
+		}
+	}
+
+	return m.apis[m.primary], id
+}
+
+// Stop stops forwarding updates.
+func (m *Mux) Stop() {
+	m.cancel()
+	<-m.done
+	close(m.updates)
+}
+
+// Updates returns updates of all platforms.
+func (m *Mux) Updates() <-chan Request {
+	return m.updates
+}
+
+// SendMessage sends the message to the platform of the chat.
+func (m *Mux) SendMessage(ctx context.Context, resp Response) (string, error) {
+	api, chatID := m.route(resp.ChatID)
+	resp.ChatID = chatID
+	return api.SendMessage(ctx, resp)
+}
+
+// EditMessage edits the message on the platform of the chat.
+func (m *Mux) EditMessage(ctx context.Context, msgID string, resp Response) error {
+	api, chatID := m.route(resp.ChatID)
+	resp.ChatID = chatID
+	return api.EditMessage(ctx, msgID, resp)
+}
+
+// DeleteMessage deletes the message on the platform of the chat.
+func (m *Mux) DeleteMessage(ctx context.Context, chatID, msgID string) error {
+	api, chatID := m.route(chatID)
+	return api.DeleteMessage(ctx, chatID, msgID)
+}
+
+// AnswerCallback answers the callback on the platform, it came from.
+func (m *Mux) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	api, callbackID := m.route(callbackID)
+	return api.AnswerCallback(ctx, callbackID, text)
+}
+
+// AnswerInline answers the inline query on the platform, it came from.
+func (m *Mux) AnswerInline(ctx context.Context, resp Response) error {
+	api, queryID := m.route(resp.InlineQueryID)
+	resp.InlineQueryID = queryID
+	return api.AnswerInline(ctx, resp)
+}
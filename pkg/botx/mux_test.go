@@ -0,0 +1,107 @@
+package botx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMux(t *testing.T) {
+	tg, slack := newFakeAPI(), newFakeAPI()
+
+	_, err := NewMux("matrix", map[string]API{"telegram": tg}, 10)
+	require.Error(t, err)
+
+	m, err := NewMux("telegram", map[string]API{"telegram": tg, "slack": slack}, 10)
+	require.NoError(t, err)
+
+	go m.Run()
+	defer m.Stop()
+
+	receive := func(t *testing.T) Request {
+		select {
+		case req := <-m.Updates():
+			return req
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no request received")
+			return Request{}
+		}
+	}
+
+	ctx := context.Background()
+
+	t.Run("primary platform", func(t *testing.T) {
+		tg.updates <- Request{Chat: Chat{ID: "1"}, CallbackID: "cb1"}
+		assert.Equal(t, Request{Chat: Chat{ID: "1", Platform: "telegram"}, CallbackID: "cb1"}, receive(t))
+
+		_, err := m.SendMessage(ctx, Response{ChatID: "1", Text: "hi"})
+		require.NoError(t, err)
+		require.NoError(t, m.AnswerCallback(ctx, "cb1", ""))
+
+		assert.Equal(t, []string{"send 1", "answer cb1"}, tg.calls)
+		assert.Empty(t, slack.calls)
+	})
+
+	t.Run("other platform", func(t *testing.T) {
+		slack.updates <- Request{Chat: Chat{ID: "C1"}, CallbackID: "cb2", InlineQueryID: "q1"}
+		assert.Equal(t, Request{
+			Chat:          Chat{ID: "slack:C1", Platform: "slack"},
+			CallbackID:    "slack:cb2",
+			InlineQueryID: "slack:q1",
+		}, receive(t))
+
+		_, err := m.SendMessage(ctx, Response{ChatID: "slack:C1", Text: "hi"})
+		require.NoError(t, err)
+		require.NoError(t, m.EditMessage(ctx, "10", Response{ChatID: "slack:C1"}))
+		require.NoError(t, m.DeleteMessage(ctx, "slack:C1", "10"))
+		require.NoError(t, m.AnswerCallback(ctx, "slack:cb2", ""))
+		require.NoError(t, m.AnswerInline(ctx, Response{InlineQueryID: "slack:q1"}))
+
+		assert.Equal(t, []string{"send C1", "edit C1 10", "delete C1 10", "answer cb2", "inline q1"}, slack.calls)
+	})
+
+	t.Run("unknown prefix goes to primary", func(t *testing.T) {
+		tg.calls = nil
+		_, err := m.SendMessage(ctx, Response{ChatID: "!room:example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"send !room:example.com"}, tg.calls)
+	})
+}
+
+// fakeAPI records calls to the API.
+type fakeAPI struct {
+	updates chan Request
+	calls   []string
+}
+
+func newFakeAPI() *fakeAPI { return &fakeAPI{updates: make(chan Request)} }
+
+func (f *fakeAPI) Updates() <-chan Request { return f.updates }
+
+func (f *fakeAPI) SendMessage(_ context.Context, resp Response) (string, error) {
+	f.calls = append(f.calls, "send "+resp.ChatID)
+	return "10", nil
+}
+
+func (f *fakeAPI) EditMessage(_ context.Context, msgID string, resp Response) error {
+	f.calls = append(f.calls, "edit "+resp.ChatID+" "+msgID)
+	return nil
+}
+
+func (f *fakeAPI) DeleteMessage(_ context.Context, chatID, msgID string) error {
+	f.calls = append(f.calls, "delete "+chatID+" "+msgID)
+	return nil
+}
+
+func (f *fakeAPI) AnswerCallback(_ context.Context, callbackID, _ string) error {
+	f.calls = append(f.calls, "answer "+callbackID)
+	return nil
+}
+
+func (f *fakeAPI) AnswerInline(_ context.Context, resp Response) error {
+	f.calls = append(f.calls, "inline "+resp.InlineQueryID)
+	return nil
+}
@@ -2,6 +2,7 @@ package botx
 
 import (
 	"context"
+	"regexp"
 	"strings"
 )
 
@@ -9,14 +10,20 @@ import (
 type Router struct {
 	notFound    Handler
 	handlers    map[string]Handler
+	callbacks   map[string]Handler
+	inline      Handler
+	unaddressed Handler
+	usernames   []string
+	mention     *regexp.Regexp
 	middlewares []Middleware
 }
 
 // NewRouter returns a multiplexer for handlers.
 func NewRouter() *Router {
 	return &Router{
-		handlers: make(map[string]Handler),
-		notFound: NotFound,
+		handlers:  make(map[string]Handler),
+		callbacks: make(map[string]Handler),
+		notFound:  NotFound,
 	}
 }
 
@@ -25,6 +32,42 @@ func (r *Router) Add(prefix string, h Handler) {
 	r.handlers[prefix] = h
 }
 
+// AddCallback adds a handler for callbacks, which data starts with the prefix.
+func (r *Router) AddCallback(prefix string, h Handler) {
+	r.callbacks[prefix] = h
+}
+
+// Inline sets a handler for inline queries, they are ignored if it is not set.
+func (r *Router) Inline(h Handler) {
+	r.inline = h
+}
+
+// Unaddressed sets a handler for messages in groups, that are neither
+// commands nor mention the bot, they are ignored if it is not set.
+func (r *Router) Unaddressed(h Handler) {
+	r.unaddressed = h
+}
+
+// Username sets usernames of the bot, to recognise commands addressed
+// to the bot, e.g. /start@newsfeedbot, and mentions of the bot in groups,
+// the bot has a username on each platform it runs on.
+// Commands addressed to other bots are ignored.
+func (r *Router) Username(names ...string) {
+	r.usernames, r.mention = nil, nil
+
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimPrefix(name, "@"); name != "" {
+			r.usernames = append(r.usernames, name)
+			quoted = append(quoted, regexp.QuoteMeta(name))
+		}
+	}
+
+	if len(quoted) > 0 {
+		r.mention = regexp.MustCompile(`(?i)@(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+}
+
 // Use applies middleware to all handlers.
 func (r *Router) Use(mvs ...Middleware) *Router {
 	r.middlewares = append(r.middlewares, mvs...)
@@ -45,6 +88,16 @@ func (r *Router) Clone() *Router {
 		rtr.Add(prefix, h)
 	}
 
+	rtr.callbacks = make(map[string]Handler, len(r.callbacks))
+	for prefix, h := range r.callbacks {
+		rtr.AddCallback(prefix, h)
+	}
+
+	rtr.inline = r.inline
+	rtr.unaddressed = r.unaddressed
+	rtr.usernames = r.usernames
+	rtr.mention = r.mention
+
 	rtr.middlewares = make([]Middleware, len(r.middlewares))
 	copy(rtr.middlewares, r.middlewares)
 
@@ -56,12 +109,28 @@ func (r *Router) Group(f func(rtr *Router)) {
 	nested := NewRouter()
 	f(nested)
 
-	for prefix, h := range nested.handlers {
-		// wrap handler with middlewares
+	// wrap handlers with middlewares
+	wrap := func(h Handler) Handler {
 		for i := len(nested.middlewares) - 1; i >= 0; i-- {
 			h = nested.middlewares[i](h)
 		}
-		r.Add(prefix, h)
+		return h
+	}
+
+	for prefix, h := range nested.handlers {
+		r.Add(prefix, wrap(h))
+	}
+
+	for prefix, h := range nested.callbacks {
+		r.AddCallback(prefix, wrap(h))
+	}
+
+	if nested.inline != nil {
+		r.Inline(wrap(nested.inline))
+	}
+
+	if nested.unaddressed != nil {
+		r.Unaddressed(wrap(nested.unaddressed))
 	}
 }
 
@@ -78,18 +147,33 @@ func (r *Router) Handle(ctx context.Context, req Request) ([]Response, error) {
 
 	var h Handler
 
-	for prefix, candidate := range r.handlers {
-		if prefix == "" {
-			continue
+	switch req.Kind {
+	case RequestInline:
+		if h = r.inline; h == nil {
+			return nil, nil
+		}
+	case RequestCallback:
+		// callbacks are made by our own buttons, so unknown
+		// callbacks are most likely from the outdated messages
+		if h = r.match(r.callbacks, req.Text); h == nil {
+			return nil, nil
+		}
+	default:
+		var ok bool
+		if req, ok = r.address(req); !ok {
+			return nil, nil
 		}
-		if strings.HasPrefix(req.Text, prefix) {
-			h = candidate
+
+		if !req.Addressed {
+			if h = r.unaddressed; h == nil {
+				return nil, nil
+			}
 			break
 		}
-	}
 
-	if h == nil {
-		h = r.notFound
+		if h = r.match(r.handlers, req.Text); h == nil {
+			h = r.notFound
+		}
 	}
 
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
@@ -98,3 +182,61 @@ func (r *Router) Handle(ctx context.Context, req Request) ([]Response, error) {
 
 	return h(ctx, req)
 }
+
+// address strips the username of the bot from the command or the mention
+// and marks the message as addressed to the bot, returns false if the
+// command is addressed to another bot.
+func (r *Router) address(req Request) (Request, bool) {
+	if strings.HasPrefix(req.Text, "/") {
+		end := strings.IndexAny(req.Text, " \n")
+		if end < 0 {
+			end = len(req.Text)
+		}
+
+		if cmd, to, found := strings.Cut(req.Text[:end], "@"); found {
+			if !r.isUsername(to) {
+				return req, false
+			}
+			req.Text = cmd + req.Text[end:]
+		}
+
+		req.Addressed = true
+		return req, true
+	}
+
+	if !req.Chat.IsGroup() {
+		req.Addressed = true
+		return req, true
+	}
+
+	if r.mention != nil && r.mention.MatchString(req.Text) {
+		req.Text = strings.TrimSpace(r.mention.ReplaceAllString(req.Text, ""))
+		req.Addressed = true
+	}
+
+	return req, true
+}
+
+// isUsername returns true if the name is one of the usernames of the bot.
+func (r *Router) isUsername(name string) bool {
+	for _, u := range r.usernames {
+		if strings.EqualFold(name, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the handler with the longest prefix of the text, or nil.
+func (r *Router) match(handlers map[string]Handler, text string) Handler {
+	var h Handler
+	longest := 0
+
+	for prefix, candidate := range handlers {
+		if prefix != "" && len(prefix) > longest && strings.HasPrefix(text, prefix) {
+			h, longest = candidate, len(prefix)
+		}
+	}
+
+	return h
+}
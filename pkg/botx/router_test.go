@@ -0,0 +1,64 @@
+package botx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Handle(t *testing.T) {
+	// echo responds with the name of the handler and the request it has received
+	echo := func(name string) Handler {
+		return func(_ context.Context, req Request) ([]Response, error) {
+			return []Response{{ChatID: req.Chat.ID, Text: name + ": " + req.Text}}, nil
+		}
+	}
+
+	rtr := NewRouter()
+	rtr.Username("newsfeedbot", "@U100")
+	rtr.Add("/start", echo("start"))
+	rtr.Add("/stats", echo("stats"))
+	rtr.AddCallback("fb:", echo("feedback"))
+	rtr.NotFound(echo("not found"))
+	rtr.Unaddressed(echo("unaddressed"))
+
+	private := Chat{ID: "1", Type: ChatPrivate}
+	group := Chat{ID: "-1", Type: ChatGroup, Title: "group"}
+
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{name: "command", req: Request{Chat: private, Text: "/start token"}, want: "start: /start token"},
+		{name: "longest prefix", req: Request{Chat: private, Text: "/stats"}, want: "stats: /stats"},
+		{name: "private message", req: Request{Chat: private, Text: "hello"}, want: "not found: hello"},
+		{name: "command to the bot", req: Request{Chat: group, Text: "/start@NewsfeedBot token"}, want: "start: /start token"},
+		{name: "command without username", req: Request{Chat: group, Text: "/start"}, want: "start: /start"},
+		{name: "command to another bot", req: Request{Chat: group, Text: "/start@otherbot"}},
+		{name: "mention", req: Request{Chat: group, Text: "@newsfeedbot https://example.com"}, want: "not found: https://example.com"},
+		{name: "mention on another platform", req: Request{Chat: group, Text: "@U100 https://example.com"}, want: "not found: https://example.com"},
+		{name: "mention of another bot", req: Request{Chat: group, Text: "@newsfeedbot2 hi"}, want: "unaddressed: @newsfeedbot2 hi"},
+		{name: "group message", req: Request{Chat: group, Text: "hello"}, want: "unaddressed: hello"},
+		{name: "callback", req: Request{Kind: RequestCallback, Chat: group, Text: "fb:up:1"}, want: "feedback: fb:up:1"},
+		{name: "unknown callback", req: Request{Kind: RequestCallback, Chat: group, Text: "unknown"}},
+		{name: "inline without handler", req: Request{Kind: RequestInline, Chat: private, Text: "query"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resps, err := rtr.Handle(context.Background(), tt.req)
+			require.NoError(t, err)
+
+			if tt.want == "" {
+				assert.Empty(t, resps)
+				return
+			}
+
+			require.Len(t, resps, 1)
+			assert.Equal(t, tt.want, resps[0].Text)
+		})
+	}
+}